@@ -0,0 +1,55 @@
+// Command bridge-server is the reference server of the bridge protocol. It serves one of the
+// built-in environments on stdin and stdout, so clients and the protocol can be tested without an
+// external simulator. With -http it serves the environment over HTTP instead (see package remote).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/bridge"
+	"github.com/jackkenney/evolve-rl/internal/remote"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// newEnv returns a new environment of the named kind, or nil if there is no such kind.
+func newEnv(name string) internal.Environment {
+	rng := mathlib.NewRandom(0)
+	switch name {
+	case "gridworld":
+		return internal.NewGridworld(rng)
+	case "cliff":
+		return internal.NewCliffWalking(rng)
+	case "frozenlake":
+		return internal.NewFrozenLake(internal.FrozenLake4x4, true, rng)
+	case "taxi":
+		return internal.NewTaxi(rng)
+	}
+	return nil
+}
+
+func main() {
+	name := flag.String("env", "gridworld", "environment to serve: gridworld, cliff, frozenlake or taxi")
+	addr := flag.String("http", "", "serve sessions over HTTP at this address (e.g. :8080) instead of stdin and stdout")
+	flag.Parse()
+
+	env := newEnv(*name)
+	if env == nil {
+		fmt.Fprintln(os.Stderr, "unknown environment "+*name)
+		os.Exit(2)
+	}
+
+	var err error
+	if *addr != "" {
+		err = http.ListenAndServe(*addr, remote.NewServer(func() internal.Environment { return newEnv(*name) }))
+	} else {
+		err = bridge.Serve(env, os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
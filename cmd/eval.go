@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/evolution"
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/internal/wrappers"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// evalCommand scores a saved policy without learning, either in a single-agent environment or
+// in seat 0 of a game against an opponent policy.
+func evalCommand(fs *flag.FlagSet, args []string) error {
+	policyPath := fs.String("policy", "", "saved policy: a genome of softmax action preferences for every one-hot state, like the champions written by evolve (agents trained by run are not saved)")
+	envName := fs.String("env", "", "environment to play: "+strings.Join(experiment.EnvironmentNames(), ", "))
+	game := fs.String("game", "", "game to play instead of an environment: "+strings.Join(experiment.GameNames(), ", "))
+	var params listFlag
+	fs.Var(&params, "param", "hyperparameter of the environment or game as <name>=<value> (repeatable)")
+	opponentPath := fs.String("opponent", "", "saved policy of the opponent in a game (default uniform random)")
+	timeLimit := fs.Int("time-limit", 1000, "cut environment episodes off after this many steps, 0 for no limit")
+	episodes := fs.Int("episodes", 100, "episodes to play")
+	seed := fs.Int64("seed", 0, "seed of the random number generator")
+	record := fs.String("record", "", "record the first environment episode to this file, for replay")
+	fs.Parse(args)
+
+	if *policyPath == "" {
+		return fmt.Errorf("no -policy given")
+	}
+	if (*envName == "") == (*game == "") {
+		return fmt.Errorf("need exactly one of -env and -game")
+	}
+	if *episodes < 1 {
+		return fmt.Errorf("need at least one episode")
+	}
+	policy, err := evolution.LoadGenome(*policyPath)
+	if err != nil {
+		return err
+	}
+	p, err := parseParams(params)
+	if err != nil {
+		return err
+	}
+	rng := mathlib.NewRandom(*seed)
+	if *game != "" {
+		if *record != "" {
+			return fmt.Errorf("only environment episodes can be recorded")
+		}
+		return evalGame(policy, *opponentPath, *game, p, *episodes, rng)
+	}
+
+	e := experiment.EnvSpec{Name: *envName, Params: p}
+	if *timeLimit <= 0 && experiment.NeverTerminates(*envName) {
+		return fmt.Errorf("%s never ends its episodes; give a positive -time-limit", *envName)
+	}
+	if *timeLimit > 0 {
+		e.Wrappers = []experiment.WrapperSpec{{Name: "time_limit", Params: experiment.Params{"steps": *timeLimit}}}
+	}
+	env, err := experiment.NewEnvironment(e, rng)
+	if err != nil {
+		return err
+	}
+	agt := policy.Agent()
+	if err := internal.CheckSpaces(agt, env); err != nil {
+		return err
+	}
+
+	returns := make([]float64, *episodes)
+	recorder := wrappers.NewRecorder(env)
+	for i := range returns {
+		if i == 0 && *record != "" {
+			returns[i] = internal.RunEpisode(agt, recorder, env.GetGamma(), rng)
+			ep := recorder.Episode()
+			ep.Environment = *envName
+			if err := saveEpisode(*record, ep); err != nil {
+				return err
+			}
+			continue
+		}
+		returns[i] = internal.RunEpisode(agt, env, env.GetGamma(), rng)
+	}
+	fmt.Printf("Mean return over %d episodes: %g (standard error %g)\n", *episodes, mathlib.Mean(returns), mathlib.StdError(returns))
+	return nil
+}
+
+// evalGame plays the policy in seat 0 of the game against the opponent and prints both returns
+// and the policy's score: 1 per win and 0.5 per draw, divided by the number of episodes.
+func evalGame(policy *evolution.Genome, opponentPath string, game string, p experiment.Params, episodes int, rng *mathlib.Random) error {
+	env, err := experiment.NewGame(game, p, rng)
+	if err != nil {
+		return err
+	}
+	if env.GetNumAgents() != 2 {
+		return fmt.Errorf("%s has %d seats, not two", game, env.GetNumAgents())
+	}
+	opponent := evolution.NewGenome(env.GetStateDim(), env.GetNumActions())
+	if opponentPath != "" {
+		if opponent, err = evolution.LoadGenome(opponentPath); err != nil {
+			return err
+		}
+	}
+	agents := []internal.Agent{policy.Agent(), opponent.Agent()}
+	if err := internal.CheckMultiAgentSpaces(agents, env); err != nil {
+		return err
+	}
+
+	returns := mathlib.Matrix(2, episodes, 0)
+	score := 0.0
+	for i := 0; i < episodes; i++ {
+		r := internal.RunMultiAgentEpisode(agents, env, env.GetGamma(), rng)
+		returns[0][i], returns[1][i] = r[0], r[1]
+		if r[0] > r[1] {
+			score++
+		} else if r[0] == r[1] {
+			score += 0.5
+		}
+	}
+	fmt.Printf("Mean returns over %d games: %g (policy), %g (opponent)\n", episodes, mathlib.Mean(returns[0]), mathlib.Mean(returns[1]))
+	fmt.Printf("Score of the policy: %g\n", score/float64(episodes))
+	return nil
+}
+
+// saveEpisode writes a recorded episode to path.
+func saveEpisode(path string, ep wrappers.Episode) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := wrappers.WriteEpisode(file, ep); err != nil {
+		return err
+	}
+	fmt.Println("Recorded " + path)
+	return nil
+}
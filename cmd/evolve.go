@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/internal/evolution"
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// evolveCommand co-evolves two populations in a game and saves the final champions.
+func evolveCommand(fs *flag.FlagSet, args []string) error {
+	cfg := evolution.DefaultCoevolutionConfig()
+	game := fs.String("game", "prisoners_dilemma", "game to play: "+strings.Join(experiment.GameNames(), ", "))
+	var gameParams listFlag
+	fs.Var(&gameParams, "param", "hyperparameter of the game as <name>=<value>, e.g. rounds=10 (repeatable)")
+	name := fs.String("name", "", "prefix of the output files (default the game)")
+	seed := fs.Int64("seed", 0, "seed of the random number generator")
+	outputDir := fs.String("out", "data", "output directory")
+	checkpoint := fs.Int("checkpoint", 0, "save the progress to <out>/<name>_checkpoint.json this often, in generations, and resume from it")
+	fs.IntVar(&cfg.Generations, "generations", cfg.Generations, "generations to run")
+	fs.IntVar(&cfg.PopulationSize, "population", cfg.PopulationSize, "genomes in each population")
+	fs.IntVar(&cfg.Elites, "elites", cfg.Elites, "best genomes kept unchanged every generation")
+	fs.Float64Var(&cfg.MutationStd, "mutation-std", cfg.MutationStd, "standard deviation of the mutation noise")
+	fs.IntVar(&cfg.GamesPerPair, "games", cfg.GamesPerPair, "games played by every pair of opponents")
+	fs.IntVar(&cfg.HallOfFameSize, "hall-of-fame", cfg.HallOfFameSize, "champions kept in each hall of fame")
+	fs.Float64Var(&cfg.EloK, "elo-k", cfg.EloK, "update size of the Elo ratings")
+	fs.Parse(args)
+
+	rng := mathlib.NewRandom(*seed)
+	params, err := parseParams(gameParams)
+	if err != nil {
+		return err
+	}
+	env, err := experiment.NewGame(*game, params, rng)
+	if err != nil {
+		return err
+	}
+	c, err := evolution.NewCoevolution(env, cfg)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = *game
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return err
+	}
+
+	checkpointPath := filepath.Join(*outputDir, *name+"_checkpoint.json")
+	if *checkpoint > 0 {
+		if _, err := os.Stat(checkpointPath); err == nil {
+			if err := c.LoadCheckpoint(checkpointPath, rng); err != nil {
+				return err
+			}
+			fmt.Println("Resuming after generation ", c.Generation())
+		}
+	}
+
+	// Ctrl-C stops after the current generation and writes the results so far
+	ctx, cancel := interruptContext()
+	defer cancel()
+	for c.Generation() < cfg.Generations && ctx.Err() == nil {
+		c.Step(rng)
+		g := c.Generation()
+		if g%10 == 0 {
+			fmt.Println("Finished generation ", g, " of ", cfg.Generations)
+		}
+		if *checkpoint > 0 && (g%*checkpoint == 0 || g == cfg.Generations || ctx.Err() != nil) {
+			if err := c.SaveCheckpoint(checkpointPath, rng); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Generation() == 0 {
+		return fmt.Errorf("interrupted before the first generation")
+	}
+	if err := c.WriteResults(*outputDir, *name, rng); err != nil {
+		return err
+	}
+	for p := 0; p < 2; p++ {
+		champions := c.Champions(p)
+		if len(champions) == 0 {
+			continue
+		}
+		path := filepath.Join(*outputDir, fmt.Sprintf("%s_champion_p%d.json", *name, p))
+		if err := evolution.SaveGenome(path, champions[len(champions)-1]); err != nil {
+			return err
+		}
+		fmt.Println("Saved " + path)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted after %d of %d generations", c.Generation(), cfg.Generations)
+	}
+	return nil
+}
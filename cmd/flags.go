@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"gopkg.in/yaml.v3"
+)
+
+// specFlags are the flags that override the settings of an experiment spec.
+type specFlags struct {
+	seed        int64
+	trials      int
+	episodes    int
+	parallelism int
+	outputDir   string
+	checkpoint  int
+	fs          *flag.FlagSet
+}
+
+// addSpecFlags defines the spec overrides on fs.
+func addSpecFlags(fs *flag.FlagSet) *specFlags {
+	f := specFlags{fs: fs}
+	fs.Int64Var(&f.seed, "seed", 0, "seed of the random number generator (overrides the spec)")
+	fs.IntVar(&f.trials, "trials", 0, "trials per environment and agent (overrides the spec)")
+	fs.IntVar(&f.episodes, "episodes", 0, "episodes per trial (overrides the spec)")
+	fs.IntVar(&f.parallelism, "parallelism", 0, "trials run at once, 0 for one per CPU (overrides the spec)")
+	fs.StringVar(&f.outputDir, "out", "", "output directory (overrides the spec)")
+	fs.IntVar(&f.checkpoint, "checkpoint", 0, "save the progress of every trial this often, in episodes, and resume from it (overrides the spec)")
+	return &f
+}
+
+// apply overrides the settings of the spec with the flags that were set.
+func (f *specFlags) apply(spec *experiment.Spec) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "seed":
+			spec.Seed = f.seed
+		case "trials":
+			spec.Trials = f.trials
+		case "episodes":
+			spec.Episodes = f.episodes
+		case "parallelism":
+			spec.Parallelism = f.parallelism
+		case "out":
+			spec.OutputDir = f.outputDir
+		case "checkpoint":
+			spec.Checkpoint = f.checkpoint
+		}
+	})
+}
+
+// loadSpec loads the spec named by the only positional argument, or the default spec if there is none.
+func loadSpec(fs *flag.FlagSet, f *specFlags) (*experiment.Spec, error) {
+	path := "experiments/gridworld.yaml"
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	spec, err := experiment.LoadSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	f.apply(spec)
+	return spec, spec.Validate()
+}
+
+// listFlag is a flag that may be repeated, collecting every value.
+type listFlag []string
+
+// String returns the values joined by spaces.
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+// Set adds a value.
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseParams parses <name>=<value> hyperparameters, reading each value as YAML as spec files do.
+func parseParams(list []string) (experiment.Params, error) {
+	params := experiment.Params{}
+	for _, p := range list {
+		eq := strings.Index(p, "=")
+		if eq < 1 {
+			return nil, fmt.Errorf("parameter %q is not <name>=<value>", p)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(p[eq+1:]), &value); err != nil || value == nil {
+			return nil, fmt.Errorf("parameter %q has a bad value", p)
+		}
+		params[p[:eq]] = value
+	}
+	return params, nil
+}
@@ -1,67 +1,96 @@
+// Command main is the command-line interface of evolve-rl. It runs experiment specs, hyperparameter
+// sweeps and co-evolution, scores and replays saved policies, and plots results. Run it without
+// arguments for a list of subcommands, and with "<subcommand> -help" for their flags.
 package main
 
 import (
-	"os/exec"
-	"sync"
-
-	"github.com/jackkenney/evolve-rl/internal"
-	"github.com/jackkenney/evolve-rl/mathlib"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
-func runner(fileName string) {
-	numTrials := 1
-
-	// Hyperparameters
-	bboN := 10
-	alphaSarsa := 0.001
-	alphaReinforce := 0.001
-	optimisticValue := 10.0
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	args    string // Positional arguments, for the usage line
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
 
-	rng := mathlib.NewRandom(0)
-	env := internal.NewGridworld(rng)
+// commands are the subcommands, in the order they are listed in the help.
+var commands = []command{
+	{"run", "[spec]", "run an experiment spec (default experiments/gridworld.yaml)", runCommand},
+	{"sweep", "[spec]", "search the hyperparameters of an agent of a spec and rank the configurations", sweepCommand},
+	{"evolve", "", "co-evolve two populations of policies in a two-player game", evolveCommand},
+	{"eval", "", "score a saved policy in an environment or game", evalCommand},
+	{"plot", "[returns files]", "render learning curves as SVG (default data/*_out.csv)", plotCommand},
+	{"replay", "<episode file>", "print an episode recorded by eval -record", replayCommand},
+}
 
-	// Get some values once so we don't have to keep looking them up (i.e., so we can type less later)
-	stateDim := env.GetStateDim()
-	numActions := env.GetNumActions()
-	gamma := env.GetGamma()
+// program returns the name the tool was run as.
+func program() string {
+	return filepath.Base(os.Args[0])
+}
 
-	// Constructors used by parallelized trials
-	envConstructor := func() internal.Environment {
-		return internal.NewGridworld(rng)
-	}
-	agtConstructor := func() internal.Agent {
-		if fileName == "bbo" {
-			return internal.NewTabularBBO(stateDim, numActions, gamma, bboN)
-		} else if fileName == "sarsa" {
-			return internal.NewSarsa(stateDim, numActions, gamma, alphaSarsa, optimisticValue)
-			// } else if fileName == "q-learning" {
-			// 	return internal.NewTabularBBO(stateDim, numActions, gamma, bboN)
-		} else if fileName == "reinforce" {
-			return internal.NewREINFORCE(stateDim, numActions, gamma, alphaReinforce)
-		} else {
-			panic("No algorithm selected")
-		}
+// usage prints the list of subcommands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [arguments]\n\nCommands:\n", program())
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun \"%s <command> -help\" for the flags of a command.\n", program())
+}
 
-	// Run parallel trials
-	internal.RunTrials(rng, agtConstructor, envConstructor, numTrials, fileName)
+// interruptContext returns a context that is cancelled by the first Ctrl-C or SIGTERM, so that a
+// long run can stop cleanly and write what it has. A second Ctrl-C kills the tool as usual.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			fmt.Fprintln(os.Stderr, "Interrupted: finishing the current episodes and writing the results so far")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
 }
 
 func main() {
-	algorithms := []string{"sarsa", "reinforce", "bbo"} //, "q-learning"}
-
-	// Run algorithms in parallel
-	var wg sync.WaitGroup
-	for _, fileName := range algorithms {
-		wg.Add(1)
-		go func(f string) {
-			runner(f)
-			wg.Done()
-		}(fileName)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return
 	}
-	wg.Wait()
 
-	// Plot results
-	cmd := exec.Command("sh", "plotResults.sh")
-	cmd.Run()
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		c := c
+		fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, "usage: %s %s [flags] %s\n\n%s.\n\nFlags:\n", program(), c.name, c.args, c.summary)
+			fs.PrintDefaults()
+		}
+		if err := c.run(fs, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, c.name+": "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
 }
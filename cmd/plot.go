@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+// plotCommand renders returns files as SVG learning curves.
+func plotCommand(fs *flag.FlagSet, args []string) error {
+	out := fs.String("o", "plots/returns.svg", "SVG file to write")
+	title := fs.String("title", "", "title of the plot")
+	smooth := fs.Int("smooth", 1, "average the returns over this many episodes")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		var err error
+		files, err = filepath.Glob("data/*_out.csv")
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no returns files in data/")
+		}
+	}
+	return plotFiles(*out, *title, files, *smooth)
+}
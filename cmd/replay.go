@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackkenney/evolve-rl/internal/wrappers"
+)
+
+// replayCommand prints an episode recorded by eval, one step per line.
+func replayCommand(fs *flag.FlagSet, args []string) error {
+	delay := fs.Duration("delay", 0, "pause between steps, e.g. 200ms, to watch the episode unfold")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("need one episode file")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ep, err := wrappers.ReadEpisode(file)
+	if err != nil {
+		return err
+	}
+
+	if ep.Environment != "" {
+		fmt.Println("Environment: " + ep.Environment)
+	}
+	fmt.Printf("%6s  %-30s %6s %10s %10s\n", "Step", "State", "Action", "Reward", "Return")
+	total := 0.0
+	for t, step := range ep.Steps {
+		total += step.Reward
+		fmt.Printf("%6d  %-30s %6d %10g %10g\n", t, formatState(step.State), step.Action, step.Reward, total)
+		if *delay > 0 {
+			time.Sleep(*delay)
+		}
+	}
+	end := "reached a terminal state"
+	if ep.Truncated {
+		end = "was truncated"
+	}
+	fmt.Printf("The episode %s after %d steps with return %g.\n", end, len(ep.Steps), ep.Return)
+	return nil
+}
+
+// formatState returns the index of a one-hot state, or the values of any other state.
+func formatState(s []float64) string {
+	hot, ones := -1, 0
+	for i, x := range s {
+		if x == 1 {
+			hot, ones = i, ones+1
+		} else if x != 0 {
+			ones = 2
+		}
+	}
+	if ones == 1 {
+		return "state " + strconv.Itoa(hot)
+	}
+	values := make([]string, len(s))
+	for i, x := range s {
+		values[i] = strconv.FormatFloat(x, 'f', 3, 64)
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/internal/plot"
+)
+
+// runCommand runs an experiment spec and plots its results.
+func runCommand(fs *flag.FlagSet, args []string) error {
+	f := addSpecFlags(fs)
+	plotPath := fs.String("plot", "", "also plot the results to this SVG file (default plots/<spec name>.svg)")
+	noPlot := fs.Bool("no-plot", false, "don't plot the results")
+	fs.Parse(args)
+
+	spec, err := loadSpec(fs, f)
+	if err != nil {
+		return err
+	}
+	spec.Log = os.Stdout
+	ctx, cancel := interruptContext()
+	defer cancel()
+	if err := experiment.RunContext(ctx, spec); err != nil {
+		return err
+	}
+	if *noPlot {
+		return nil
+	}
+	if *plotPath == "" {
+		name := spec.Name
+		if name == "" {
+			name = "returns"
+		}
+		*plotPath = filepath.Join("plots", name+".svg")
+	}
+	return plotFiles(*plotPath, spec.Name, spec.OutputFiles(), 1)
+}
+
+// plotFiles plots the returns files to an SVG file.
+func plotFiles(path string, title string, files []string, smooth int) error {
+	var series []plot.Series
+	for _, file := range files {
+		s, err := plot.ReadReturns(file)
+		if err != nil {
+			return err
+		}
+		series = append(series, plot.Smooth(s, smooth))
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := plot.WriteSVG(out, title, series); err != nil {
+		return err
+	}
+	fmt.Println("Wrote " + path)
+	return nil
+}
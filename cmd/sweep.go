@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jackkenney/evolve-rl/internal/sweep"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// sweepCommand searches the hyperparameters of an agent of a spec, writes the returns of every
+// configuration to one table and ranks the configurations by their mean return over the last episodes.
+func sweepCommand(fs *flag.FlagSet, args []string) error {
+	f := addSpecFlags(fs)
+	var params listFlag
+	fs.Var(&params, "param", "hyperparameter to search, as <param>=<value>,<value>,... or <param>=<min>:<max>[:log][:int] (repeatable)")
+	method := fs.String("method", "grid", "how to pick configurations: grid, random, lhs (Latin hypercube), bayes (Bayesian optimization), halving (successive halving of lhs) or hyperband")
+	n := fs.Int("n", 20, "configurations to try with -method random, lhs, bayes or halving")
+	kernel := fs.String("kernel", "matern", "kernel of the Gaussian process of -method bayes: matern or rbf")
+	points := fs.Int("points", 3, "values to try from each range with -method grid")
+	minEpisodes := fs.Int("min-episodes", 10, "episodes of the first rung of -method halving or hyperband, which run up to -episodes")
+	eta := fs.Int("eta", 3, "-method halving and hyperband keep the best 1/eta of the configurations at each rung")
+	agent := fs.String("agent", "", "label of the agent to tune, if the spec has several")
+	env := fs.String("env", "", "label of the environment to tune on, if the spec has several")
+	window := fs.Int("window", 100, "rank configurations by their mean return over this many final episodes")
+	fs.Parse(args)
+
+	spec, err := loadSpec(fs, f)
+	if err != nil {
+		return err
+	}
+	s, err := sweep.New(spec, *env, *agent)
+	if err != nil {
+		return err
+	}
+	s.Log = os.Stdout
+	var space sweep.Space
+	for _, p := range params {
+		d, err := sweep.ParseDimension(p)
+		if err != nil {
+			return err
+		}
+		space = append(space, d)
+	}
+	if err := space.Validate(); err != nil {
+		return fmt.Errorf("%v; give -param", err)
+	}
+
+	// Sampling draws from its own generator, so a sweep run again tries the same configurations and
+	// resumes from their checkpoints
+	rng := mathlib.NewRandom(spec.Seed)
+	ctx, cancel := interruptContext()
+	defer cancel()
+	var results []sweep.Result
+	var runErr error
+	h := sweep.Hyperband{MinEpisodes: *minEpisodes, MaxEpisodes: spec.Episodes, Eta: *eta, Window: *window}
+	switch {
+	case *method == "bayes":
+		k, ok := map[string]sweep.Kernel{"matern": sweep.Matern52, "rbf": sweep.RBF}[*kernel]
+		if !ok {
+			return fmt.Errorf("unknown kernel %q", *kernel)
+		}
+		results, runErr = s.Optimize(ctx, sweep.NewBayesianOptimizer(space, k, rng), *n, *window)
+	case (*method == "halving" || *method == "hyperband") && spec.Episodes == 0:
+		return fmt.Errorf("-method %s needs -episodes, the episodes of the configurations that survive every rung", *method)
+	case *method == "halving":
+		results, runErr = s.SuccessiveHalving(ctx, sweep.LatinHypercube(space, *n, rng), h)
+	case *method == "hyperband":
+		results, runErr = s.Hyperband(ctx, space, h, rng)
+	default:
+		configs, err := sampleConfigs(*method, space, *n, *points, rng)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Sweeping %d configurations\n", len(configs))
+		results, runErr = s.Run(ctx, configs)
+	}
+	if err := writeSweep(s, results, *window); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// sampleConfigs returns the configurations of a grid, random or Latin hypercube search.
+func sampleConfigs(method string, space sweep.Space, n int, points int, rng *mathlib.Random) ([]sweep.Config, error) {
+	switch method {
+	case "grid":
+		return sweep.Grid(space, points), nil
+	case "random":
+		return sweep.Random(space, n, rng), nil
+	case "lhs":
+		return sweep.LatinHypercube(space, n, rng), nil
+	}
+	return nil, fmt.Errorf("unknown method %q", method)
+}
+
+// writeSweep writes the table of returns and the summary of a sweep's results, which may be
+// partial, to the spec's output directory, and prints the summary.
+func writeSweep(s *sweep.Sweep, results []sweep.Result, window int) error {
+	if len(results) == 0 {
+		return nil
+	}
+	outputDir := s.Spec.OutputDir
+	if outputDir == "" {
+		outputDir = "data"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	summaries := sweep.Summarize(results, window)
+	tablePath := filepath.Join(outputDir, s.Name()+"_sweep.csv")
+	summaryPath := filepath.Join(outputDir, s.Name()+"_sweep_summary.csv")
+	if err := writeFile(tablePath, func(w io.Writer) error { return sweep.WriteTable(w, results) }); err != nil {
+		return err
+	}
+	if err := writeFile(summaryPath, func(w io.Writer) error { return sweep.WriteSummary(w, summaries) }); err != nil {
+		return err
+	}
+
+	fmt.Printf("%-6s %-7s %-9s %-20s %s\n", "Rank", "Config", "Episodes", "Final mean return", "95% interval")
+	for rank, sum := range summaries {
+		fmt.Printf("%-6d %-7d %-9d %-20g [%g, %g]\n", rank+1, sum.Config.ID, sum.Episodes, sum.Mean, sum.Low, sum.High)
+	}
+	fmt.Printf("Wrote %s and %s\n", tablePath, summaryPath)
+	return nil
+}
+
+// writeFile creates the file at path and writes it with write.
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -12,12 +12,16 @@ type Agent interface {
 	NewEpisode()
 	// Reset the agent entirely - to a blank slate prior to learning.
 	Reset(rng *mathlib.Random)
+	// Supports returns an error describing why the agent cannot learn with these observation and action spaces, or nil.
+	Supports(observation Space, action Space) error
 	// Update given a (s,a,r,s') tuple, if UpdateBeforeNextAction returns true.
 	UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random)
 	// Update given a (s,a,r,s',a') tuple, if UpdateBeforeNextAction returns false.
 	UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random)
 	// LastUpdate lets the agent update/learn when sPrime would be the terminal absorbing state.
 	LastUpdate(s []float64, a int, r float64, rng *mathlib.Random)
+	// TruncatedUpdate lets the agent update/learn when the episode was cut off in the non-terminal state sPrime.
+	TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random)
 	// // EpisodicUpdate the agent after N episodes (specified in constructor)
 	// episodicUpdate(rng *mathlib.Random)
 	// // Clear agent's memory
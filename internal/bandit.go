@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+type armKind int
+
+const (
+	gaussianArms  armKind = iota // Rewards ~ N(mean, 1)
+	bernoulliArms                // Rewards ~ Bernoulli(mean)
+)
+
+// KArmedBandit is an Environment where every episode is a single pull of one of k arms.
+type KArmedBandit struct {
+	kind     armKind
+	means    []float64 // Expected reward of each arm
+	walkStd  float64   // Standard deviation of the random walk of the means after every pull (0 for stationary arms)
+	numPulls int       // How many pulls (episodes) should be run?
+	tas      bool      // Has the arm been pulled this episode?
+}
+
+// NewGaussianBandit returns the k-armed testbed: arm means ~ N(0,1) and rewards ~ N(mean,1).
+func NewGaussianBandit(k int, numPulls int, rng *mathlib.Random) Environment {
+	env := KArmedBandit{kind: gaussianArms, numPulls: numPulls}
+	env.means = make([]float64, k)
+	for a := 0; a < k; a++ {
+		env.means[a] = rng.NormFloat64()
+	}
+	return &env
+}
+
+// NewBernoulliBandit returns a k-armed bandit with success probabilities ~ U(0,1) and rewards in {0,1}.
+func NewBernoulliBandit(k int, numPulls int, rng *mathlib.Random) Environment {
+	env := KArmedBandit{kind: bernoulliArms, numPulls: numPulls}
+	env.means = make([]float64, k)
+	for a := 0; a < k; a++ {
+		env.means[a] = rng.Float64()
+	}
+	return &env
+}
+
+// NewRandomWalkBandit returns a non-stationary k-armed bandit. All arm means start at zero and
+// take independent N(0, walkStd^2) steps after every pull. Rewards ~ N(mean,1).
+func NewRandomWalkBandit(k int, numPulls int, walkStd float64) Environment {
+	env := KArmedBandit{kind: gaussianArms, numPulls: numPulls, walkStd: walkStd}
+	env.means = make([]float64, k)
+	return &env
+}
+
+// GetMaxEps returns how many pulls should be made
+func (env *KArmedBandit) GetMaxEps() int {
+	return env.numPulls
+}
+
+// GetStateDim returns the dimension (length) of state vectors. Bandits have a single state.
+func (env *KArmedBandit) GetStateDim() int {
+	return 1
+}
+
+// GetNumActions returns the number of arms.
+func (env *KArmedBandit) GetNumActions() int {
+	return len(env.means)
+}
+
+// GetGamma returns \gamma, which does not matter for one-step episodes.
+func (env *KArmedBandit) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition pulls arm a and returns the sampled reward. The episode is then over.
+func (env *KArmedBandit) Transition(a int, rng *mathlib.Random) float64 {
+	var reward float64
+	if env.kind == bernoulliArms {
+		if rng.Float64() < env.means[a] {
+			reward = 1
+		}
+	} else {
+		reward = env.means[a] + rng.NormFloat64()
+	}
+
+	// Non-stationary arms drift after every pull
+	if env.walkStd > 0 {
+		for i := range env.means {
+			env.means[i] += env.walkStd * rng.NormFloat64()
+		}
+	}
+
+	env.tas = true
+	return reward
+}
+
+// GetState returns the only state of the bandit.
+func (env *KArmedBandit) GetState() []float64 {
+	return []float64{1.0}
+}
+
+// InTAS returns whether an arm has been pulled this episode.
+func (env *KArmedBandit) InTAS() bool {
+	return env.tas
+}
+
+// NewEpisode gets ready for the next pull.
+func (env *KArmedBandit) NewEpisode(rng *mathlib.Random) {
+	env.tas = false
+}
+
+// Snapshot returns the arm means, which drift between episodes if the bandit is non-stationary.
+func (env *KArmedBandit) Snapshot() ([]byte, error) {
+	return json.Marshal(env.means)
+}
+
+// Restore sets the arm means to a snapshot.
+func (env *KArmedBandit) Restore(data []byte) error {
+	var means []float64
+	if err := json.Unmarshal(data, &means); err != nil {
+		return err
+	}
+	if err := checkVector("means", means, len(env.means)); err != nil {
+		return err
+	}
+	env.means = means
+	return nil
+}
+
+// ArmMean returns the expected reward of arm a.
+func (env *KArmedBandit) ArmMean(a int) float64 {
+	return env.means[a]
+}
+
+// OptimalArm returns the arm with the largest expected reward.
+func (env *KArmedBandit) OptimalArm() int {
+	return argmax(env.means, nil)
+}
+
+// LinearContextualBandit is an Environment where each episode draws a context x ~ N(0,I) and
+// pulling arm a pays x . theta_a plus Gaussian noise.
+type LinearContextualBandit struct {
+	theta    [][]float64 // Parameter vector of each arm
+	noiseStd float64     // Standard deviation of the reward noise
+	numPulls int         // How many pulls (episodes) should be run?
+	context  []float64   // The context of the current episode
+	tas      bool        // Has the arm been pulled this episode?
+}
+
+// NewLinearContextualBandit returns a k-armed linear contextual bandit with contextDim-dimensional
+// contexts. Arm parameters are drawn from N(0, I/contextDim) so that expected rewards have unit variance.
+func NewLinearContextualBandit(k int, contextDim int, noiseStd float64, numPulls int, rng *mathlib.Random) Environment {
+	env := LinearContextualBandit{noiseStd: noiseStd, numPulls: numPulls}
+	env.theta = mathlib.Matrix(k, contextDim, 0)
+	for a := 0; a < k; a++ {
+		for i := 0; i < contextDim; i++ {
+			env.theta[a][i] = rng.NormFloat64() / math.Sqrt(float64(contextDim))
+		}
+	}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many pulls should be made
+func (env *LinearContextualBandit) GetMaxEps() int {
+	return env.numPulls
+}
+
+// GetStateDim returns the dimension of the context.
+func (env *LinearContextualBandit) GetStateDim() int {
+	return len(env.context)
+}
+
+// ObservationSpace returns the space of contexts, which are Gaussian.
+func (env *LinearContextualBandit) ObservationSpace() Space {
+	return NewUnboundedBoxSpace(len(env.context))
+}
+
+// GetNumActions returns the number of arms.
+func (env *LinearContextualBandit) GetNumActions() int {
+	return len(env.theta)
+}
+
+// GetGamma returns \gamma, which does not matter for one-step episodes.
+func (env *LinearContextualBandit) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition pulls arm a and returns the sampled reward. The episode is then over.
+func (env *LinearContextualBandit) Transition(a int, rng *mathlib.Random) float64 {
+	env.tas = true
+	return env.ArmMean(a) + env.noiseStd*rng.NormFloat64()
+}
+
+// GetState returns a copy of the current context.
+func (env *LinearContextualBandit) GetState() []float64 {
+	result := make([]float64, len(env.context))
+	copy(result, env.context)
+	return result
+}
+
+// InTAS returns whether an arm has been pulled this episode.
+func (env *LinearContextualBandit) InTAS() bool {
+	return env.tas
+}
+
+// NewEpisode draws the context for the next pull.
+func (env *LinearContextualBandit) NewEpisode(rng *mathlib.Random) {
+	env.context = make([]float64, len(env.theta[0]))
+	for i := range env.context {
+		env.context[i] = rng.NormFloat64()
+	}
+	env.tas = false
+}
+
+// ArmMean returns the expected reward of arm a in the current context.
+func (env *LinearContextualBandit) ArmMean(a int) float64 {
+	return mathlib.Dot(env.context, env.theta[a])
+}
+
+// OptimalArm returns the arm with the largest expected reward in the current context.
+func (env *LinearContextualBandit) OptimalArm() int {
+	means := make([]float64, len(env.theta))
+	for a := range means {
+		means[a] = env.ArmMean(a)
+	}
+	return argmax(means, nil)
+}
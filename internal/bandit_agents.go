@@ -0,0 +1,429 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// BanditAgent keeps sample-average estimates of each arm's value and picks arms with an exploration
+// policy. It ignores the state, so it is meant for (non-contextual) bandits.
+type BanditAgent struct {
+	numActions int
+	q          []float64 // Sample-average value of each arm
+	n          []float64 // Number of pulls of each arm
+
+	policy ExplorationPolicy // Selects arms from the value estimates
+}
+
+// NewBanditAgent returns a sample-average bandit agent that selects arms with the passed policy.
+func NewBanditAgent(numActions int, policy ExplorationPolicy) Agent {
+	agt := BanditAgent{}
+	agt.numActions = numActions
+	agt.policy = policy
+	agt.q = mathlib.Vector(numActions, 0)
+	agt.n = mathlib.Vector(numActions, 0)
+	return &agt
+}
+
+// NewEpsilonGreedyBandit returns a sample-average bandit agent with epsilon-greedy arm selection.
+func NewEpsilonGreedyBandit(numActions int, epsilon Schedule) Agent {
+	return NewBanditAgent(numActions, NewEpsilonGreedy(epsilon))
+}
+
+// NewUCB1Bandit returns a sample-average bandit agent with UCB1 arm selection.
+func NewUCB1Bandit(numActions int, c float64) Agent {
+	return NewBanditAgent(numActions, NewUCB1(1, numActions, c))
+}
+
+// UpdateBeforeNextAction makes an update to the agent's policy before selecting the next action.
+func (agt *BanditAgent) UpdateBeforeNextAction() bool {
+	return false
+}
+
+// GetAction returns the arm selected by the exploration policy.
+func (agt *BanditAgent) GetAction(s []float64, rng *mathlib.Random) int {
+	return agt.policy.SelectAction(0, agt.q, rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *BanditAgent) NewEpisode() {}
+
+// Supports returns an error unless actions are discrete. The state is ignored.
+func (agt *BanditAgent) Supports(observation Space, action Space) error {
+	return supportsDiscreteActions(agt.numActions, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *BanditAgent) Reset(rng *mathlib.Random) {
+	mathlib.ZeroVec(&agt.q)
+	mathlib.ZeroVec(&agt.n)
+	agt.policy.Reset()
+}
+
+// banditAgentSnapshot is the learned state of a BanditAgent.
+type banditAgentSnapshot struct {
+	Q      []float64       `json:"q"`
+	N      []float64       `json:"n"`
+	Policy json.RawMessage `json:"policy,omitempty"`
+}
+
+// Snapshot returns the value estimates, the pull counts and the state of the exploration policy.
+func (agt *BanditAgent) Snapshot() ([]byte, error) {
+	policy, err := SnapshotOf(agt.policy)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(banditAgentSnapshot{Q: agt.q, N: agt.n, Policy: policy})
+}
+
+// Restore sets the value estimates, the pull counts and the state of the exploration policy to a snapshot.
+func (agt *BanditAgent) Restore(data []byte) error {
+	snap := banditAgentSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkVector("q", snap.Q, agt.numActions); err != nil {
+		return err
+	}
+	if err := checkVector("n", snap.N, agt.numActions); err != nil {
+		return err
+	}
+	if err := RestoreSnapshot(agt.policy, snap.Policy); err != nil {
+		return err
+	}
+	agt.q, agt.n = snap.Q, snap.N
+	return nil
+}
+
+// UpdateSARS is unimplemented for this class.
+func (agt *BanditAgent) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	panic("UpdateSARS is not implemented for BanditAgent.")
+}
+
+// UpdateSARSA is unimplemented for this class since bandit episodes last one step.
+func (agt *BanditAgent) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+	panic("UpdateSARSA is not implemented for BanditAgent.")
+}
+
+// TruncatedUpdate is the same as LastUpdate since bandit episodes last one step.
+func (agt *BanditAgent) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
+// LastUpdate updates the sample average of the pulled arm.
+func (agt *BanditAgent) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {
+	agt.n[a]++
+	agt.q[a] += (r - agt.q[a]) / agt.n[a]
+}
+
+// ThompsonBeta is Thompson sampling with a Beta(1,1) prior for bandits with rewards in [0,1].
+type ThompsonBeta struct {
+	numActions int
+	alpha      []float64 // Posterior successes + 1 of each arm
+	beta       []float64 // Posterior failures + 1 of each arm
+}
+
+// NewThompsonBeta returns a Beta-Bernoulli Thompson sampling agent.
+func NewThompsonBeta(numActions int) Agent {
+	agt := ThompsonBeta{}
+	agt.numActions = numActions
+	agt.alpha = mathlib.Vector(numActions, 1)
+	agt.beta = mathlib.Vector(numActions, 1)
+	return &agt
+}
+
+// UpdateBeforeNextAction makes an update to the agent's policy before selecting the next action.
+func (agt *ThompsonBeta) UpdateBeforeNextAction() bool {
+	return false
+}
+
+// GetAction samples a success probability for every arm from its posterior and returns the best arm.
+func (agt *ThompsonBeta) GetAction(s []float64, rng *mathlib.Random) int {
+	samples := make([]float64, agt.numActions)
+	for a := 0; a < agt.numActions; a++ {
+		samples[a] = rng.Beta(agt.alpha[a], agt.beta[a])
+	}
+	return argmax(samples, rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *ThompsonBeta) NewEpisode() {}
+
+// Supports returns an error unless actions are discrete. The state is ignored.
+func (agt *ThompsonBeta) Supports(observation Space, action Space) error {
+	return supportsDiscreteActions(agt.numActions, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *ThompsonBeta) Reset(rng *mathlib.Random) {
+	for a := 0; a < agt.numActions; a++ {
+		agt.alpha[a] = 1
+		agt.beta[a] = 1
+	}
+}
+
+// thompsonBetaSnapshot is the posterior of a ThompsonBeta agent.
+type thompsonBetaSnapshot struct {
+	Alpha []float64 `json:"alpha"`
+	Beta  []float64 `json:"beta"`
+}
+
+// Snapshot returns the posterior of every arm.
+func (agt *ThompsonBeta) Snapshot() ([]byte, error) {
+	return json.Marshal(thompsonBetaSnapshot{Alpha: agt.alpha, Beta: agt.beta})
+}
+
+// Restore sets the posterior of every arm to a snapshot.
+func (agt *ThompsonBeta) Restore(data []byte) error {
+	snap := thompsonBetaSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkVector("alpha", snap.Alpha, agt.numActions); err != nil {
+		return err
+	}
+	if err := checkVector("beta", snap.Beta, agt.numActions); err != nil {
+		return err
+	}
+	agt.alpha, agt.beta = snap.Alpha, snap.Beta
+	return nil
+}
+
+// UpdateSARS is unimplemented for this class.
+func (agt *ThompsonBeta) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	panic("UpdateSARS is not implemented for ThompsonBeta.")
+}
+
+// UpdateSARSA is unimplemented for this class since bandit episodes last one step.
+func (agt *ThompsonBeta) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+	panic("UpdateSARSA is not implemented for ThompsonBeta.")
+}
+
+// TruncatedUpdate is the same as LastUpdate since bandit episodes last one step.
+func (agt *ThompsonBeta) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
+// LastUpdate updates the posterior of the pulled arm. Rewards in (0,1) count as fractional successes.
+func (agt *ThompsonBeta) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {
+	r = math.Max(0, math.Min(1, r))
+	agt.alpha[a] += r
+	agt.beta[a] += 1 - r
+}
+
+// ThompsonGaussian is Thompson sampling with a N(0, 1) prior on each arm's mean and Gaussian
+// rewards of known variance.
+type ThompsonGaussian struct {
+	numActions    int
+	noiseVariance float64   // Known variance of the rewards
+	precision     []float64 // Posterior precision of each arm's mean
+	weightedSum   []float64 // Sum of the rewards of each arm divided by noiseVariance
+}
+
+// NewThompsonGaussian returns a Gaussian Thompson sampling agent for rewards with the passed variance.
+func NewThompsonGaussian(numActions int, noiseVariance float64) Agent {
+	agt := ThompsonGaussian{}
+	agt.numActions = numActions
+	agt.noiseVariance = noiseVariance
+	agt.precision = mathlib.Vector(numActions, 1)
+	agt.weightedSum = mathlib.Vector(numActions, 0)
+	return &agt
+}
+
+// UpdateBeforeNextAction makes an update to the agent's policy before selecting the next action.
+func (agt *ThompsonGaussian) UpdateBeforeNextAction() bool {
+	return false
+}
+
+// GetAction samples a mean for every arm from its posterior and returns the best arm.
+func (agt *ThompsonGaussian) GetAction(s []float64, rng *mathlib.Random) int {
+	samples := make([]float64, agt.numActions)
+	for a := 0; a < agt.numActions; a++ {
+		mean := agt.weightedSum[a] / agt.precision[a]
+		samples[a] = mean + rng.NormFloat64()/math.Sqrt(agt.precision[a])
+	}
+	return argmax(samples, rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *ThompsonGaussian) NewEpisode() {}
+
+// Supports returns an error unless actions are discrete. The state is ignored.
+func (agt *ThompsonGaussian) Supports(observation Space, action Space) error {
+	return supportsDiscreteActions(agt.numActions, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *ThompsonGaussian) Reset(rng *mathlib.Random) {
+	for a := 0; a < agt.numActions; a++ {
+		agt.precision[a] = 1
+		agt.weightedSum[a] = 0
+	}
+}
+
+// thompsonGaussianSnapshot is the posterior of a ThompsonGaussian agent.
+type thompsonGaussianSnapshot struct {
+	Precision   []float64 `json:"precision"`
+	WeightedSum []float64 `json:"weighted_sum"`
+}
+
+// Snapshot returns the posterior of every arm.
+func (agt *ThompsonGaussian) Snapshot() ([]byte, error) {
+	return json.Marshal(thompsonGaussianSnapshot{Precision: agt.precision, WeightedSum: agt.weightedSum})
+}
+
+// Restore sets the posterior of every arm to a snapshot.
+func (agt *ThompsonGaussian) Restore(data []byte) error {
+	snap := thompsonGaussianSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkVector("precision", snap.Precision, agt.numActions); err != nil {
+		return err
+	}
+	if err := checkVector("weighted sum", snap.WeightedSum, agt.numActions); err != nil {
+		return err
+	}
+	agt.precision, agt.weightedSum = snap.Precision, snap.WeightedSum
+	return nil
+}
+
+// UpdateSARS is unimplemented for this class.
+func (agt *ThompsonGaussian) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	panic("UpdateSARS is not implemented for ThompsonGaussian.")
+}
+
+// UpdateSARSA is unimplemented for this class since bandit episodes last one step.
+func (agt *ThompsonGaussian) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+	panic("UpdateSARSA is not implemented for ThompsonGaussian.")
+}
+
+// TruncatedUpdate is the same as LastUpdate since bandit episodes last one step.
+func (agt *ThompsonGaussian) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
+// LastUpdate updates the posterior of the pulled arm.
+func (agt *ThompsonGaussian) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {
+	agt.precision[a] += 1 / agt.noiseVariance
+	agt.weightedSum[a] += r / agt.noiseVariance
+}
+
+// LinUCB is the disjoint linear UCB agent of Li et al. (2010) for linear contextual bandits.
+type LinUCB struct {
+	numActions int
+	contextDim int
+	alpha      float64 // Width of the confidence bound
+
+	aInv [][][]float64 // Inverse of each arm's ridge regression design matrix A = I + sum x x^T
+	b    [][]float64   // Sum of reward-weighted contexts of each arm
+}
+
+// NewLinUCB returns a LinUCB agent for contextDim-dimensional contexts.
+func NewLinUCB(contextDim int, numActions int, alpha float64) Agent {
+	agt := LinUCB{}
+	agt.numActions = numActions
+	agt.contextDim = contextDim
+	agt.alpha = alpha
+	agt.Reset(nil)
+	return &agt
+}
+
+// UpdateBeforeNextAction makes an update to the agent's policy before selecting the next action.
+func (agt *LinUCB) UpdateBeforeNextAction() bool {
+	return false
+}
+
+// GetAction returns the arm with the largest upper confidence bound on its reward in context s.
+func (agt *LinUCB) GetAction(s []float64, rng *mathlib.Random) int {
+	scores := make([]float64, agt.numActions)
+	for a := 0; a < agt.numActions; a++ {
+		theta := mathlib.MatVec(agt.aInv[a], agt.b[a])
+		width := math.Sqrt(mathlib.Dot(s, mathlib.MatVec(agt.aInv[a], s)))
+		scores[a] = mathlib.Dot(theta, s) + agt.alpha*width
+	}
+	return argmax(scores, rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *LinUCB) NewEpisode() {}
+
+// Supports returns an error unless observations are contextDim-dimensional and actions are discrete.
+func (agt *LinUCB) Supports(observation Space, action Space) error {
+	if observation.Dim() != agt.contextDim {
+		return fmt.Errorf("need %d-dimensional contexts", agt.contextDim)
+	}
+	return supportsDiscreteActions(agt.numActions, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *LinUCB) Reset(rng *mathlib.Random) {
+	agt.aInv = make([][][]float64, agt.numActions)
+	agt.b = mathlib.Matrix(agt.numActions, agt.contextDim, 0)
+	for a := 0; a < agt.numActions; a++ {
+		agt.aInv[a] = mathlib.Identity(agt.contextDim, 1)
+	}
+}
+
+// linUCBSnapshot is the state of the regressions of a LinUCB agent.
+type linUCBSnapshot struct {
+	AInv [][][]float64 `json:"a_inv"`
+	B    [][]float64   `json:"b"`
+}
+
+// Snapshot returns the state of every arm's regression.
+func (agt *LinUCB) Snapshot() ([]byte, error) {
+	return json.Marshal(linUCBSnapshot{AInv: agt.aInv, B: agt.b})
+}
+
+// Restore sets the state of every arm's regression to a snapshot.
+func (agt *LinUCB) Restore(data []byte) error {
+	snap := linUCBSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if len(snap.AInv) != agt.numActions {
+		return fmt.Errorf("snapshot has %d arms, expected %d", len(snap.AInv), agt.numActions)
+	}
+	for _, aInv := range snap.AInv {
+		if err := checkMatrix("inverse design matrix", aInv, agt.contextDim, agt.contextDim); err != nil {
+			return err
+		}
+	}
+	if err := checkMatrix("b", snap.B, agt.numActions, agt.contextDim); err != nil {
+		return err
+	}
+	agt.aInv, agt.b = snap.AInv, snap.B
+	return nil
+}
+
+// UpdateSARS is unimplemented for this class.
+func (agt *LinUCB) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	panic("UpdateSARS is not implemented for LinUCB.")
+}
+
+// UpdateSARSA is unimplemented for this class since bandit episodes last one step.
+func (agt *LinUCB) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+	panic("UpdateSARSA is not implemented for LinUCB.")
+}
+
+// TruncatedUpdate is the same as LastUpdate since bandit episodes last one step.
+func (agt *LinUCB) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
+// LastUpdate adds the (context, reward) pair to the pulled arm's regression.
+func (agt *LinUCB) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {
+	// Sherman-Morrison update of A^{-1} after A += s s^T, using the symmetry of A^{-1}
+	u := mathlib.MatVec(agt.aInv[a], s)
+	denominator := 1 + mathlib.Dot(s, u)
+	for i := 0; i < agt.contextDim; i++ {
+		for j := 0; j < agt.contextDim; j++ {
+			agt.aInv[a][i][j] -= u[i] * u[j] / denominator
+		}
+		agt.b[a][i] += r * s[i]
+	}
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// optimalFraction runs the agent on the bandit and returns how often the optimal arm was pulled in
+// the last quarter of the pulls.
+func optimalFraction(agt Agent, env Environment, optimalArm func() int, r *mathlib.Random) float64 {
+	agt.Reset(r)
+	numPulls := env.GetMaxEps()
+	hits := 0
+	for i := 0; i < numPulls; i++ {
+		env.NewEpisode(r)
+		s := env.GetState()
+		action := agt.GetAction(s, r)
+		if i >= 3*numPulls/4 && action == optimalArm() {
+			hits++
+		}
+		reward := env.Transition(action, r)
+		agt.LastUpdate(s, action, reward, r)
+	}
+	return float64(hits) / float64(numPulls/4)
+}
+
+func TestBanditOneStepEpisodes(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	bandit := NewGaussianBandit(10, 100, r)
+	bandit.NewEpisode(r)
+	assert.False(t, bandit.InTAS())
+	assert.Equal(t, []float64{1.0}, bandit.GetState())
+	bandit.Transition(0, r)
+	assert.True(t, bandit.InTAS())
+}
+
+func TestRandomWalkBanditDrifts(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	bandit := NewRandomWalkBandit(3, 100, 0.1).(*KArmedBandit)
+	assert.Equal(t, 0.0, bandit.ArmMean(0))
+	bandit.Transition(0, r)
+	assert.NotEqual(t, 0.0, bandit.ArmMean(0))
+}
+
+func TestBanditAgentsFindOptimalArm(t *testing.T) {
+	r := mathlib.NewRandom(1)
+	gaussian := NewGaussianBandit(5, 2000, r).(*KArmedBandit)
+	bernoulli := NewBernoulliBandit(5, 2000, r).(*KArmedBandit)
+
+	assert.True(t, optimalFraction(NewEpsilonGreedyBandit(5, ConstantSchedule(0.1)), gaussian, gaussian.OptimalArm, r) > 0.6)
+	assert.True(t, optimalFraction(NewUCB1Bandit(5, 2), gaussian, gaussian.OptimalArm, r) > 0.6)
+	assert.True(t, optimalFraction(NewThompsonGaussian(5, 1), gaussian, gaussian.OptimalArm, r) > 0.6)
+	assert.True(t, optimalFraction(NewThompsonBeta(5), bernoulli, bernoulli.OptimalArm, r) > 0.6)
+}
+
+func TestLinUCBFindsOptimalArm(t *testing.T) {
+	r := mathlib.NewRandom(2)
+	bandit := NewLinearContextualBandit(4, 5, 0.1, 2000, r).(*LinearContextualBandit)
+	assert.Equal(t, 5, bandit.GetStateDim())
+	assert.True(t, optimalFraction(NewLinUCB(5, 4, 1), bandit, bandit.OptimalArm, r) > 0.8)
+}
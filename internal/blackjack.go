@@ -0,0 +1,132 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// blackjackRadices are the sizes of the (player sum - 12, dealer card - 1, usable ace) state.
+var blackjackRadices = []int{10, 10, 2}
+
+// Blackjack is the blackjack game of Sutton and Barto (2018), Example 5.1, played from an infinite
+// deck. The player automatically hits below 12, then sticks (0) or hits (1). Winning pays +1, losing
+// -1 and drawing 0. A natural (21 from the first two cards) wins unless the dealer has one too, and is
+// settled by the first action. The state is (player sum, dealer's showing card, usable ace).
+type Blackjack struct {
+	playerSum    int
+	playerAce    bool // Does the player hold an ace counted as 11?
+	natural      bool // Did the player get a natural?
+	dealerCard   int  // The dealer's showing card (1 is an ace)
+	dealerHidden int  // The dealer's face-down card
+	done         bool
+}
+
+// NewBlackjack returns a new Blackjack Environment object.
+func NewBlackjack(rng *mathlib.Random) Environment {
+	env := Blackjack{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// drawCard draws from an infinite deck where face cards count as 10 and an ace as 1.
+func drawCard(rng *mathlib.Random) int {
+	card := int(rng.Float64()*13) + 1
+	if card > 10 {
+		return 10
+	}
+	return card
+}
+
+// addCard adds card to the hand (sum, usable ace) and returns the new hand.
+func addCard(sum int, usableAce bool, card int) (int, bool) {
+	sum += card
+	if card == 1 && sum+10 <= 21 {
+		sum += 10
+		usableAce = true
+	}
+	if sum > 21 && usableAce {
+		sum -= 10
+		usableAce = false
+	}
+	return sum, usableAce
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *Blackjack) GetMaxEps() int {
+	return 10000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *Blackjack) GetStateDim() int {
+	return mathlib.MixedRadixCapacity(blackjackRadices)
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *Blackjack) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *Blackjack) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *Blackjack) Transition(a int, rng *mathlib.Random) float64 {
+	if env.natural {
+		env.done = true
+		dealerSum, dealerAce := addCard(0, false, env.dealerCard)
+		dealerSum, _ = addCard(dealerSum, dealerAce, env.dealerHidden)
+		if dealerSum == 21 {
+			return 0.0
+		}
+		return 1.0
+	}
+
+	if a == 1 {
+		env.playerSum, env.playerAce = addCard(env.playerSum, env.playerAce, drawCard(rng))
+		if env.playerSum > 21 {
+			env.done = true
+			return -1.0
+		}
+		return 0.0
+	}
+
+	// The player sticks and the dealer hits until reaching 17 or more
+	env.done = true
+	dealerSum, dealerAce := addCard(0, false, env.dealerCard)
+	dealerSum, dealerAce = addCard(dealerSum, dealerAce, env.dealerHidden)
+	for dealerSum < 17 {
+		dealerSum, dealerAce = addCard(dealerSum, dealerAce, drawCard(rng))
+	}
+	if dealerSum > 21 || env.playerSum > dealerSum {
+		return 1.0
+	} else if env.playerSum < dealerSum {
+		return -1.0
+	}
+	return 0.0
+}
+
+// GetState returns the one-hot encoding of (player sum, dealer's showing card, usable ace).
+func (env *Blackjack) GetState() []float64 {
+	ace := 0
+	if env.playerAce {
+		ace = 1
+	}
+	return mathlib.ToOneHotMixedRadix([]int{env.playerSum - 12, env.dealerCard - 1, ace}, blackjackRadices)
+}
+
+// InTAS returns whether the game is over.
+func (env *Blackjack) InTAS() bool {
+	return env.done
+}
+
+// NewEpisode deals a new hand and hits the player up to at least 12.
+func (env *Blackjack) NewEpisode(rng *mathlib.Random) {
+	env.playerSum, env.playerAce = addCard(0, false, drawCard(rng))
+	env.playerSum, env.playerAce = addCard(env.playerSum, env.playerAce, drawCard(rng))
+	env.natural = env.playerSum == 21
+	for env.playerSum < 12 {
+		env.playerSum, env.playerAce = addCard(env.playerSum, env.playerAce, drawCard(rng))
+	}
+	env.dealerCard = drawCard(rng)
+	env.dealerHidden = drawCard(rng)
+	env.done = false
+}
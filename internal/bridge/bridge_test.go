@@ -0,0 +1,173 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelperProcess is not a real test. It is the protocol server run as a subprocess by the other
+// tests, which start the test binary itself with BRIDGE_HELPER_PROCESS set.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("BRIDGE_HELPER_PROCESS") != "1" {
+		return
+	}
+	env := internal.NewGridworld(mathlib.NewRandom(0))
+	if err := Serve(env, os.Stdin, os.Stdout); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// startHelper starts the helper process and connects to it.
+func startHelper(t *testing.T) *Environment {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "BRIDGE_HELPER_PROCESS=1")
+	env, err := startSubprocess(cmd)
+	assert.NoError(t, err)
+	return env
+}
+
+func TestSpaceRoundTrip(t *testing.T) {
+	for _, s := range []internal.Space{
+		internal.NewDiscreteSpace(4),
+		internal.NewOneHotSpace(23),
+		internal.NewMultiDiscreteSpace([]int{2, 3}),
+		internal.NewBoxSpace([]float64{math.Inf(-1), 0}, []float64{1, math.Inf(1)}),
+	} {
+		decoded, err := DecodeSpace(EncodeSpace(s))
+		assert.NoError(t, err)
+		assert.Equal(t, s, decoded)
+	}
+	_, err := DecodeSpace(&SpaceJSON{Kind: "Graph"})
+	assert.Error(t, err)
+}
+
+func TestServeErrors(t *testing.T) {
+	in := strings.NewReader("{\"cmd\":\"step\",\"action\":0}\nnot json\n{\"cmd\":\"reset\",\"seed\":1}\n{\"cmd\":\"step\",\"action\":9}\n{\"cmd\":\"jump\"}\n")
+	var out bytes.Buffer
+	assert.NoError(t, Serve(internal.NewGridworld(mathlib.NewRandom(0)), in, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[0], "step before reset")
+	assert.Contains(t, lines[1], "bad request")
+	assert.Contains(t, lines[2], "\"observation\"")
+	assert.Contains(t, lines[3], "action 9")
+	assert.Contains(t, lines[4], "unknown command")
+}
+
+// nopCloser is an io.WriteCloser whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestEnvironmentFailure(t *testing.T) {
+	// The server answers the spaces request and then hangs up
+	var spaces bytes.Buffer
+	assert.NoError(t, Serve(internal.NewGridworld(mathlib.NewRandom(0)), strings.NewReader("{\"cmd\":\"spaces\"}\n"), &spaces))
+	env, err := NewEnvironment(&spaces, nopCloser{&bytes.Buffer{}})
+	assert.NoError(t, err)
+	assert.NoError(t, env.Err())
+
+	// The failure is kept instead of panicking, and the episode ends at once
+	rng := mathlib.NewRandom(0)
+	assert.NotPanics(t, func() { env.NewEpisode(rng) })
+	assert.Contains(t, env.Err().Error(), "bridge reset")
+	assert.True(t, env.InTAS())
+	assert.False(t, env.Truncated())
+	assert.Equal(t, 0.0, env.Transition(0, rng))
+	assert.Equal(t, mathlib.ToOneHot(0, 23), env.GetState())
+
+	// Seed 0 is sent rather than left out
+	data, err := json.Marshal(Request{Cmd: CmdReset})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "\"seed\":0")
+}
+
+// scripted returns an Environment of a server that answers the passed requests as env would,
+// then sends the extra response lines and hangs up.
+func scripted(t *testing.T, requests string, extra string) *Environment {
+	var out bytes.Buffer
+	assert.NoError(t, Serve(internal.NewGridworld(mathlib.NewRandom(0)), strings.NewReader("{\"cmd\":\"spaces\"}\n"+requests), &out))
+	out.WriteString(extra)
+	env, err := NewEnvironment(&out, nopCloser{&bytes.Buffer{}})
+	assert.NoError(t, err)
+	return env
+}
+
+func TestTerminatedAndTruncated(t *testing.T) {
+	// A step that is both terminated and truncated ends in the terminal absorbing state
+	env := scripted(t, "{\"cmd\":\"reset\",\"seed\":1}\n", "{\"reward\":1,\"terminated\":true,\"truncated\":true}\n")
+	rng := mathlib.NewRandom(0)
+	agt := internal.NewSarsa(23, 4, 0.9, 0.1, 0, internal.NewSoftmax(1))
+	assert.NotPanics(t, func() { assert.Equal(t, 1.0, internal.RunEpisode(agt, env, 0.9, rng)) })
+	assert.True(t, env.InTAS())
+	assert.False(t, env.Truncated())
+	assert.NoError(t, env.Err())
+}
+
+func TestConformanceFailure(t *testing.T) {
+	// The server dies after the first step of an episode
+	env := scripted(t, "{\"cmd\":\"reset\",\"seed\":1}\n{\"cmd\":\"step\",\"action\":0}\n", "")
+	err := CheckConformance(env, 1, mathlib.NewRandom(0))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "step 1: bridge step")
+
+	// and before any episode
+	err = CheckConformance(scripted(t, "", ""), 1, mathlib.NewRandom(0))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bridge reset")
+}
+
+func TestSubprocessConformance(t *testing.T) {
+	env := startHelper(t)
+	defer func() { assert.NoError(t, env.Close()) }()
+
+	assert.Equal(t, 23, env.GetStateDim())
+	assert.Equal(t, 4, env.GetNumActions())
+	assert.Equal(t, 0.9, env.GetGamma())
+	assert.NoError(t, CheckConformance(env, 20, mathlib.NewRandom(1)))
+}
+
+func TestSubprocessMatchesInProcess(t *testing.T) {
+	remote := startHelper(t)
+	defer remote.Close()
+	local := internal.NewGridworld(mathlib.NewRandom(0))
+
+	// The server seeds each episode from the client's rng, so replaying the seeds locally gives the same episodes
+	clientRng := mathlib.NewRandom(2)
+	seedRng := mathlib.NewRandom(2)
+	actionRng := mathlib.NewRandom(3)
+	for ep := 0; ep < 5; ep++ {
+		remote.NewEpisode(clientRng)
+		serverRng := mathlib.NewRandom(int64(seedRng.Float64() * (1 << 53)))
+		local.NewEpisode(serverRng)
+		for !local.InTAS() {
+			assert.Equal(t, local.GetState(), remote.GetState())
+			a := int(actionRng.Float64() * 4)
+			assert.Equal(t, local.Transition(a, serverRng), remote.Transition(a, nil))
+		}
+		assert.True(t, remote.InTAS())
+	}
+}
+
+func TestRunEpisodeOverBridge(t *testing.T) {
+	env := startHelper(t)
+	defer env.Close()
+	rng := mathlib.NewRandom(0)
+	agt := internal.NewSarsa(env.GetStateDim(), env.GetNumActions(), env.GetGamma(), 0.1, 0, internal.NewSoftmax(1))
+	assert.NoError(t, internal.CheckSpaces(agt, env))
+	returns := internal.RunAgentEnvironment(agt, env, 5, env.GetGamma(), rng)
+	assert.Len(t, returns, 5)
+}
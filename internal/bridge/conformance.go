@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// maxConformanceSteps bounds the length of the episodes played by CheckConformance.
+const maxConformanceSteps = 100000
+
+// CheckConformance plays episodes with uniformly random actions and returns an error at the first
+// violation of the Environment contract: observations outside the observation space, a state
+// dimension or number of actions that disagrees with the spaces, non-finite rewards, truncated
+// episodes without a final observation, or episodes longer than maxConformanceSteps. A failure
+// reported by the Err method of an internal.ErrorReporter, such as a bridge Environment whose
+// server hung up, is returned as soon as it happens. It is meant to verify protocol servers
+// offline, but works on any Environment.
+func CheckConformance(env internal.Environment, episodes int, rng *mathlib.Random) (err error) {
+	defer func() {
+		// Environments panic on some violations, e.g. GetState in the terminal absorbing state
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	reporter, _ := env.(internal.ErrorReporter)
+	// failure returns the failure reported by env, if any
+	failure := func() error {
+		if reporter == nil {
+			return nil
+		}
+		return reporter.Err()
+	}
+
+	obs, act := internal.ObservationSpaceOf(env), internal.ActionSpaceOf(env)
+	if obs.Dim() != env.GetStateDim() {
+		return fmt.Errorf("observation space %v has dimension %d but GetStateDim is %d", obs, obs.Dim(), env.GetStateDim())
+	}
+	if act.Kind != internal.Discrete || act.N != env.GetNumActions() {
+		return fmt.Errorf("action space %v does not match %d actions", act, env.GetNumActions())
+	}
+
+	for ep := 0; ep < episodes; ep++ {
+		env.NewEpisode(rng)
+		if err := failure(); err != nil {
+			return fmt.Errorf("episode %d: %v", ep, err)
+		}
+		if s := env.GetState(); !obs.Contains(s) {
+			return fmt.Errorf("episode %d: initial observation %v is not in %v", ep, s, obs)
+		}
+		for t := 0; !env.InTAS(); t++ {
+			if t == maxConformanceSteps {
+				return fmt.Errorf("episode %d: no end after %d steps", ep, t)
+			}
+			r := env.Transition(int(rng.Float64()*float64(act.N)), rng)
+			if err := failure(); err != nil {
+				return fmt.Errorf("episode %d step %d: %v", ep, t, err)
+			}
+			if math.IsNaN(r) || math.IsInf(r, 0) {
+				return fmt.Errorf("episode %d step %d: reward %v", ep, t, r)
+			}
+			tr, ok := env.(internal.Truncator)
+			if !env.InTAS() || (ok && tr.Truncated()) {
+				if s := env.GetState(); !obs.Contains(s) {
+					return fmt.Errorf("episode %d step %d: observation %v is not in %v", ep, t, s, obs)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Transport sends a request to a protocol server and returns its response, or an error if the
+// connection broke or the server answered with an error. It carries the messages of an
+// Environment, e.g. over the pipes of a subprocess or over HTTP.
+type Transport func(req Request) (Response, error)
+
+// Environment is an internal.Environment run by a protocol server, usually a subprocess. The
+// Environment interface has no errors, so the first broken connection or error response is kept
+// and reported by Err instead. After a failure every episode ends at once: InTAS returns true,
+// Transition returns 0 and GetState returns the last observation.
+type Environment struct {
+	name       string // Prefix of the errors, e.g. "bridge"
+	call       Transport
+	disconnect func() error // Ends the connection, or nil if there is none
+
+	observationSpace internal.Space
+	actionSpace      internal.Space
+	gamma            float64
+	maxEps           int
+
+	state      []float64 // Observation after the last reset or step
+	terminated bool
+	truncated  bool
+
+	err error
+}
+
+// NewTransportEnvironment returns an Environment that sends its requests with call and ends the
+// connection with disconnect when it is closed. Its errors start with name, e.g. "remote step:
+// ...". It asks the server for its spaces straight away.
+func NewTransportEnvironment(name string, call Transport, disconnect func() error) (*Environment, error) {
+	env := Environment{name: name, call: call, disconnect: disconnect}
+	resp, err := env.call(Request{Cmd: CmdSpaces})
+	if err != nil {
+		return nil, err
+	}
+	if env.observationSpace, err = DecodeSpace(resp.ObservationSpace); err != nil {
+		return nil, fmt.Errorf("observation space: %v", err)
+	}
+	if env.actionSpace, err = DecodeSpace(resp.ActionSpace); err != nil {
+		return nil, fmt.Errorf("action space: %v", err)
+	}
+	if env.actionSpace.Kind != internal.Discrete {
+		return nil, fmt.Errorf("need Discrete actions, got %v", env.actionSpace)
+	}
+	env.gamma = resp.Gamma
+	env.maxEps = resp.MaxEps
+	return &env, nil
+}
+
+// NewFailedEnvironment returns an Environment that could not connect to its server. Err reports
+// err, and every episode ends at once.
+func NewFailedEnvironment(err error) *Environment {
+	return &Environment{err: err}
+}
+
+// NewEnvironment returns an Environment that sends requests to w and reads responses from r, one
+// JSON message per line. It asks the server for its spaces straight away.
+func NewEnvironment(r io.Reader, w io.WriteCloser) (*Environment, error) {
+	out := bufio.NewScanner(r)
+	out.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	call := lineTransport(out, json.NewEncoder(w))
+	// disconnect asks the server to stop and closes its input
+	disconnect := func() error {
+		_, err := call(Request{Cmd: CmdClose})
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return NewTransportEnvironment("bridge", call, disconnect)
+}
+
+// lineTransport returns a Transport that writes requests with encoder and reads a response from
+// every line of out.
+func lineTransport(out *bufio.Scanner, encoder *json.Encoder) Transport {
+	return func(req Request) (Response, error) {
+		var resp Response
+		if err := encoder.Encode(req); err != nil {
+			return resp, err
+		}
+		if !out.Scan() {
+			if err := out.Err(); err != nil {
+				return resp, err
+			}
+			return resp, io.ErrUnexpectedEOF
+		}
+		if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+			return resp, fmt.Errorf("bad response: %v", err)
+		}
+		if resp.Error != "" {
+			return resp, errors.New(resp.Error)
+		}
+		return resp, nil
+	}
+}
+
+// NewSubprocessEnvironment starts the program name with the passed arguments and returns an
+// Environment that talks to it over its stdin and stdout. Its stderr is passed through.
+func NewSubprocessEnvironment(name string, args ...string) (*Environment, error) {
+	cmd := exec.Command(name, args...)
+	return startSubprocess(cmd)
+}
+
+// startSubprocess starts cmd and connects to it. Closing the Environment waits for cmd to exit.
+func startSubprocess(cmd *exec.Cmd) (*Environment, error) {
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	env, err := NewEnvironment(out, in)
+	if err != nil {
+		in.Close()
+		cmd.Wait()
+		return nil, err
+	}
+	closeStream := env.disconnect
+	env.disconnect = func() error {
+		err := closeStream()
+		if waitErr := cmd.Wait(); err == nil {
+			err = waitErr
+		}
+		return err
+	}
+	return env, nil
+}
+
+// do sends a request unless an earlier call failed, and keeps the first error.
+func (env *Environment) do(req Request) (Response, bool) {
+	if env.err != nil {
+		return Response{}, false
+	}
+	resp, err := env.call(req)
+	if err != nil {
+		env.err = fmt.Errorf("%s %s: %v", env.name, req.Cmd, err)
+		return resp, false
+	}
+	return resp, true
+}
+
+// Err returns the first failure of this environment, or nil.
+func (env *Environment) Err() error {
+	return env.err
+}
+
+// Close ends the connection to the server, e.g. by asking a subprocess to stop and waiting for it
+// to exit. Closing an Environment that never connected returns its failure.
+func (env *Environment) Close() error {
+	if env.disconnect == nil {
+		return env.err
+	}
+	return env.disconnect()
+}
+
+// GetMaxEps returns how many episodes the server says should be run
+func (env *Environment) GetMaxEps() int {
+	return env.maxEps
+}
+
+// GetStateDim returns the dimension (length) of state vectors, or 0 if the server was never reached.
+func (env *Environment) GetStateDim() int {
+	if env.call == nil {
+		return 0
+	}
+	return env.observationSpace.Dim()
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *Environment) GetNumActions() int {
+	return env.actionSpace.N
+}
+
+// GetGamma returns \gamma
+func (env *Environment) GetGamma() float64 {
+	return env.gamma
+}
+
+// ObservationSpace returns the server's observation space.
+func (env *Environment) ObservationSpace() internal.Space {
+	return env.observationSpace
+}
+
+// ActionSpace returns the server's action space.
+func (env *Environment) ActionSpace() internal.Space {
+	return env.actionSpace
+}
+
+// Transition sends action a to the server and returns the reward, or 0 after a failure.
+func (env *Environment) Transition(a int, rng *mathlib.Random) float64 {
+	resp, ok := env.do(Request{Cmd: CmdStep, Action: a})
+	if !ok {
+		return 0
+	}
+	env.state = resp.Observation
+	env.terminated = resp.Terminated
+	env.truncated = resp.Truncated && !resp.Terminated // Termination wins, since there is no next state
+	return resp.Reward
+}
+
+// GetState returns the observation sent by the server after the last reset or step.
+func (env *Environment) GetState() []float64 {
+	if env.err != nil {
+		return env.placeholder()
+	}
+	if env.terminated {
+		panic("GetState called when in TAS.")
+	}
+	result := make([]float64, len(env.state))
+	copy(result, env.state)
+	return result
+}
+
+// placeholder returns the last observation, or a valid observation if there is none, so agents can
+// finish the episode after a failure.
+func (env *Environment) placeholder() []float64 {
+	if env.state != nil {
+		return append([]float64(nil), env.state...)
+	}
+	if env.observationSpace.Kind == internal.OneHot {
+		return mathlib.ToOneHot(0, env.GetStateDim())
+	}
+	return mathlib.Vector(env.GetStateDim(), 0)
+}
+
+// InTAS returns whether the server ended the episode or a call failed.
+func (env *Environment) InTAS() bool {
+	return env.terminated || env.truncated || env.err != nil
+}
+
+// Truncated returns whether the server truncated the episode without terminating it.
+func (env *Environment) Truncated() bool {
+	return env.truncated && env.err == nil
+}
+
+// NewEpisode asks the server to reset with a seed drawn from rng.
+func (env *Environment) NewEpisode(rng *mathlib.Random) {
+	seed := int64(rng.Float64() * (1 << 53))
+	resp, ok := env.do(Request{Cmd: CmdReset, Seed: seed})
+	if !ok {
+		return
+	}
+	env.state = resp.Observation
+	env.terminated = false
+	env.truncated = false
+}
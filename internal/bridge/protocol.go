@@ -0,0 +1,117 @@
+// Package bridge runs environments in other processes over a Gym-like protocol of line-delimited
+// JSON messages, so simulators written in other languages can be used without porting them.
+//
+// The client writes one Request per line to the server's stdin and reads one Response per line
+// from its stdout:
+//
+//	{"cmd":"spaces"}            -> {"observation_space":{...},"action_space":{...},"gamma":0.9,"max_eps":1000}
+//	{"cmd":"reset","seed":42}   -> {"observation":[...]}
+//	{"cmd":"step","action":2}   -> {"observation":[...],"reward":-1,"terminated":false,"truncated":false}
+//	{"cmd":"close"}             -> {}
+//
+// A step that terminates the episode may omit the observation, and one that is both terminated
+// and truncated counts as terminated. Any request can instead be answered
+// with {"error":"..."}. Spaces are {"kind":"Discrete","n":4}, {"kind":"OneHot","n":23},
+// {"kind":"MultiDiscrete","radices":[2,3]} or {"kind":"Box","low":[...],"high":[...]}, where a
+// null bound is infinite.
+package bridge
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/internal"
+)
+
+// Commands of the protocol.
+const (
+	CmdSpaces = "spaces"
+	CmdReset  = "reset"
+	CmdStep   = "step"
+	CmdClose  = "close"
+)
+
+// Request is a message from the client to the server.
+type Request struct {
+	Cmd    string `json:"cmd"`
+	Seed   int64  `json:"seed"`
+	Action int    `json:"action"`
+}
+
+// Response is a message from the server to the client.
+type Response struct {
+	Observation      []float64  `json:"observation,omitempty"`
+	Reward           float64    `json:"reward,omitempty"`
+	Terminated       bool       `json:"terminated,omitempty"`
+	Truncated        bool       `json:"truncated,omitempty"`
+	ObservationSpace *SpaceJSON `json:"observation_space,omitempty"`
+	ActionSpace      *SpaceJSON `json:"action_space,omitempty"`
+	Gamma            float64    `json:"gamma,omitempty"`
+	MaxEps           int        `json:"max_eps,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// SpaceJSON is the wire form of an internal.Space. JSON has no infinities, so infinite Box bounds are null.
+type SpaceJSON struct {
+	Kind    string     `json:"kind"`
+	N       int        `json:"n,omitempty"`
+	Low     []*float64 `json:"low,omitempty"`
+	High    []*float64 `json:"high,omitempty"`
+	Radices []int      `json:"radices,omitempty"`
+}
+
+// finite returns the bounds with infinities replaced by nil.
+func finite(bounds []float64) []*float64 {
+	result := make([]*float64, len(bounds))
+	for i := range bounds {
+		if !math.IsInf(bounds[i], 0) {
+			result[i] = &bounds[i]
+		}
+	}
+	return result
+}
+
+// infinite returns the bounds with nil replaced by the infinity of the passed sign.
+func infinite(bounds []*float64, sign int) []float64 {
+	result := make([]float64, len(bounds))
+	for i, b := range bounds {
+		if b == nil {
+			result[i] = math.Inf(sign)
+		} else {
+			result[i] = *b
+		}
+	}
+	return result
+}
+
+// EncodeSpace returns the wire form of s.
+func EncodeSpace(s internal.Space) *SpaceJSON {
+	return &SpaceJSON{
+		Kind:    s.Kind.String(),
+		N:       s.N,
+		Low:     finite(s.Low),
+		High:    finite(s.High),
+		Radices: s.Radices,
+	}
+}
+
+// DecodeSpace returns the space of the wire form.
+func DecodeSpace(s *SpaceJSON) (internal.Space, error) {
+	if s == nil {
+		return internal.Space{}, fmt.Errorf("missing space")
+	}
+	switch s.Kind {
+	case internal.Discrete.String():
+		return internal.NewDiscreteSpace(s.N), nil
+	case internal.OneHot.String():
+		return internal.NewOneHotSpace(s.N), nil
+	case internal.MultiDiscrete.String():
+		return internal.NewMultiDiscreteSpace(s.Radices), nil
+	case internal.Box.String():
+		if len(s.Low) != len(s.High) {
+			return internal.Space{}, fmt.Errorf("box bounds have lengths %d and %d", len(s.Low), len(s.High))
+		}
+		return internal.NewBoxSpace(infinite(s.Low, -1), infinite(s.High, 1)), nil
+	}
+	return internal.Space{}, fmt.Errorf("unknown space kind %q", s.Kind)
+}
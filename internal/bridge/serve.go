@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Serve answers protocol requests read from r by running env, writing responses to w, until it
+// reads a close request or r ends.
+func Serve(env internal.Environment, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	session := NewSession(env)
+
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = "bad request: " + err.Error()
+		} else {
+			resp = session.Handle(req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+		if req.Cmd == CmdClose {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Session answers the requests of one client of one environment. Every reset seeds a fresh random
+// number generator, which the following steps use, so a seed determines the whole episode.
+// Sessions are not safe for concurrent use.
+type Session struct {
+	env internal.Environment
+	rng *mathlib.Random // Seeded by the last reset, nil before the first
+}
+
+// NewSession returns a session that runs env.
+func NewSession(env internal.Environment) *Session {
+	return &Session{env: env}
+}
+
+// Handle answers a single request.
+func (s *Session) Handle(req Request) Response {
+	env := s.env
+	switch req.Cmd {
+	case CmdSpaces:
+		return Response{
+			ObservationSpace: EncodeSpace(internal.ObservationSpaceOf(env)),
+			ActionSpace:      EncodeSpace(internal.ActionSpaceOf(env)),
+			Gamma:            env.GetGamma(),
+			MaxEps:           env.GetMaxEps(),
+		}
+	case CmdReset:
+		s.rng = mathlib.NewRandom(req.Seed)
+		env.NewEpisode(s.rng)
+		return Response{Observation: env.GetState()}
+	case CmdStep:
+		if s.rng == nil {
+			return Response{Error: "step before reset"}
+		}
+		if req.Action < 0 || req.Action >= env.GetNumActions() {
+			return Response{Error: fmt.Sprintf("action %d is not in 0 to %d", req.Action, env.GetNumActions()-1)}
+		}
+		resp := Response{Reward: env.Transition(req.Action, s.rng)}
+		if env.InTAS() {
+			tr, ok := env.(internal.Truncator)
+			resp.Truncated = ok && tr.Truncated()
+			resp.Terminated = !resp.Truncated
+		}
+		// The terminal absorbing state has no observation
+		if !resp.Terminated {
+			resp.Observation = env.GetState()
+		}
+		return resp
+	case CmdClose:
+		return Response{}
+	}
+	return Response{Error: fmt.Sprintf("unknown command %q", req.Cmd)}
+}
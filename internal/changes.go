@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ChangePoint records that a non-stationary environment changed.
+type ChangePoint struct {
+	Episode     int    // Index of the first episode played in the changed environment
+	Description string // What changed
+}
+
+// ChangeLogger is implemented by environments that change over time, like the non-stationary
+// wrappers. Runners write the change points of every trial next to its returns.
+type ChangeLogger interface {
+	ChangePoints() []ChangePoint
+}
+
+// unwrapper is implemented by environments that wrap another environment.
+type unwrapper interface {
+	Unwrap() Environment
+}
+
+// ChangePointsOf returns the change points of env and of every environment it wraps, in the order
+// of their episodes.
+func ChangePointsOf(env Environment) []ChangePoint {
+	var points []ChangePoint
+	for env != nil {
+		if c, ok := env.(ChangeLogger); ok {
+			points = append(points, c.ChangePoints()...)
+		}
+		w, ok := env.(unwrapper)
+		if !ok {
+			break
+		}
+		env = w.Unwrap()
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Episode < points[j].Episode })
+	return points
+}
+
+// WriteChangePoints writes the change points of every trial as CSV, in the style of the returns
+// files in data/. Trials are numbered from 1 and trials without change points are left out.
+func WriteChangePoints(w io.Writer, trials [][]ChangePoint) error {
+	if _, err := fmt.Fprintln(w, "Trial, Episode, Change"); err != nil {
+		return err
+	}
+	for i, points := range trials {
+		for _, p := range points {
+			if _, err := fmt.Fprintf(w, "%d,%d,%q\n", i+1, p.Episode, p.Description); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
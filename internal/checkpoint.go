@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Snapshotter is implemented by agents, exploration policies and environments whose state can be
+// saved between episodes and restored later, so that a run can be checkpointed and resumed. An
+// environment only needs it if it keeps state from one episode to the next, since NewEpisode
+// rebuilds the rest.
+type Snapshotter interface {
+	// Snapshot returns the state that changes as the agent learns or the environment is played.
+	Snapshot() ([]byte, error)
+	// Restore sets the state to one returned by Snapshot of an object built with the same settings.
+	Restore(data []byte) error
+}
+
+// SnapshotOf returns the snapshot of x if it is a Snapshotter, or nil if it is not.
+func SnapshotOf(x interface{}) ([]byte, error) {
+	if s, ok := x.(Snapshotter); ok {
+		return s.Snapshot()
+	}
+	return nil, nil
+}
+
+// RestoreSnapshot restores a snapshot returned by SnapshotOf. Restoring nil does nothing.
+func RestoreSnapshot(x interface{}, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	s, ok := x.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("%T cannot restore a snapshot", x)
+	}
+	return s.Restore(data)
+}
+
+// checkMatrix returns an error unless m has the passed numbers of rows and columns.
+func checkMatrix(name string, m [][]float64, rows int, cols int) error {
+	if len(m) != rows {
+		return fmt.Errorf("%s has %d rows, expected %d", name, len(m), rows)
+	}
+	for _, row := range m {
+		if len(row) != cols {
+			return fmt.Errorf("%s has a row of length %d, expected %d", name, len(row), cols)
+		}
+	}
+	return nil
+}
+
+// checkVector returns an error unless v has the passed length.
+func checkVector(name string, v []float64, n int) error {
+	if len(v) != n {
+		return fmt.Errorf("%s has length %d, expected %d", name, len(v), n)
+	}
+	return nil
+}
+
+// trialCheckpoint is the progress of one trial of RunTrialsWithConfig.
+type trialCheckpoint struct {
+	Returns     []float64       `json:"returns"`               // Return of every finished episode
+	Agent       json.RawMessage `json:"agent,omitempty"`       // Snapshot of the agent after the last finished episode
+	Environment json.RawMessage `json:"environment,omitempty"` // Snapshot of the environment, if it is a Snapshotter
+	AgentStream *mathlib.Random `json:"agent_stream,omitempty"`
+	EnvStream   *mathlib.Random `json:"env_stream,omitempty"`
+}
+
+// runCheckpoint is the progress of every trial of RunTrialsWithConfig.
+type runCheckpoint struct {
+	Fingerprint string            `json:"fingerprint"` // TrialConfig.Fingerprint of the run
+	NumEps      int               `json:"episodes"`
+	Trials      []trialCheckpoint `json:"trials"`
+}
+
+// checkpointer saves the progress of the trials of a run to a file. Trials report their progress
+// concurrently, so every save rewrites the whole file under a lock.
+type checkpointer struct {
+	mu    sync.Mutex
+	path  string
+	every int // Episodes between saves of a trial, 0 to save finished trials only
+	run   runCheckpoint
+}
+
+// loadCheckpointer returns a checkpointer that saves to path and holds the progress already saved
+// there, if any. The saved run must have the same fingerprint and numbers of trials and episodes.
+func loadCheckpointer(path string, every int, fingerprint string, numTrials int, numEps int) (*checkpointer, error) {
+	c := checkpointer{path: path, every: every, run: runCheckpoint{
+		Fingerprint: fingerprint,
+		NumEps:      numEps,
+		Trials:      make([]trialCheckpoint, numTrials),
+	}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	saved := runCheckpoint{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	if saved.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("checkpoint %s was saved by a run with another seed or other hyperparameters; delete it to start over", path)
+	}
+	if saved.NumEps != numEps || len(saved.Trials) != numTrials {
+		return nil, fmt.Errorf("checkpoint %s is of %d trials of %d episodes, not %d trials of %d episodes",
+			path, len(saved.Trials), saved.NumEps, numTrials, numEps)
+	}
+	c.run = saved
+	return &c, nil
+}
+
+// trial returns the saved progress of trial i.
+func (c *checkpointer) trial(i int) trialCheckpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.run.Trials[i]
+}
+
+// save records the progress of trial i and writes the checkpoint. The file is replaced in one
+// rename, so a run killed while saving leaves the previous checkpoint intact.
+func (c *checkpointer) save(i int, t trialCheckpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.run.Trials[i] = t
+	data, err := json.Marshal(c.run)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// runCheckpointedTrial is runAgentEnvironment for trial i, continuing from the progress saved in
+// the checkpoint. A trial is saved every c.every episodes, when it finishes, and when ctx is
+// cancelled. An agent that is not a Snapshotter can't be saved mid-trial, so its trial is only
+// saved when it finishes, and starts over after an interruption, which gives the same returns
+// since its streams start over too. Nor is a trial saved when it is cancelled in the middle of an
+// episode, so it resumes from its last save.
+func runCheckpointedTrial(ctx context.Context, c *checkpointer, i int, agt Agent, env Environment, numEps int, gamma float64, rngs streams) ([]float64, error) {
+	progress := c.trial(i)
+	if len(progress.Returns) == numEps {
+		// The environment of a finished trial is restored for its change points
+		if err := RestoreSnapshot(env, progress.Environment); err != nil {
+			return nil, err
+		}
+		return progress.Returns, nil
+	}
+
+	result := make([]float64, 0, numEps)
+	if len(progress.Returns) > 0 {
+		if err := RestoreSnapshot(agt, progress.Agent); err != nil {
+			return nil, err
+		}
+		if err := RestoreSnapshot(env, progress.Environment); err != nil {
+			return nil, err
+		}
+		if progress.AgentStream == nil || progress.EnvStream == nil {
+			return nil, fmt.Errorf("checkpoint of trial %d has no random number streams", i+1)
+		}
+		rngs = streams{agent: progress.AgentStream, env: progress.EnvStream}
+		result = append(result, progress.Returns...)
+	} else {
+		agt.Reset(rngs.agent)
+	}
+
+	_, canSave := agt.(Snapshotter)
+	saved := len(result)
+	// save records the progress of the trial
+	save := func() error {
+		t := trialCheckpoint{Returns: append([]float64(nil), result...)}
+		var err error
+		if t.Environment, err = SnapshotOf(env); err != nil {
+			return err
+		}
+		if len(result) < numEps {
+			if t.Agent, err = SnapshotOf(agt); err != nil {
+				return err
+			}
+			t.AgentStream, t.EnvStream = rngs.agent.Clone(), rngs.env.Clone()
+		}
+		saved = len(result)
+		return c.save(i, t)
+	}
+	for len(result) < numEps {
+		if err := ctx.Err(); err != nil {
+			if canSave && len(result) > saved {
+				if err := save(); err != nil {
+					return nil, err
+				}
+			}
+			return result, err
+		}
+		ret, finished := runEpisode(ctx, agt, env, gamma, rngs)
+		if !finished {
+			// The agent learned from part of the episode, so the trial resumes from its last save
+			return result, ctx.Err()
+		}
+		result = append(result, ret)
+		if len(result) == numEps || (canSave && c.every > 0 && len(result)%c.every == 0) {
+			if err := save(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,409 @@
+package internal
+
+import (
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// uniform returns a uniform random float from [low, high)
+func uniform(rng *mathlib.Random, low float64, high float64) float64 {
+	return low + (high-low)*rng.Float64()
+}
+
+// clip bounds x to [low, high]
+func clip(x float64, low float64, high float64) float64 {
+	return math.Max(low, math.Min(high, x))
+}
+
+// wrapAngle maps an angle to [-pi, pi)
+func wrapAngle(x float64) float64 {
+	x = math.Mod(x+math.Pi, 2*math.Pi)
+	if x < 0 {
+		x += 2 * math.Pi
+	}
+	return x - math.Pi
+}
+
+// MountainCar is the mountain car task of Moore (1990) with the dynamics of Sutton and Barto (2018), Example 10.1.
+// Actions are full throttle reverse (0), zero throttle (1) and full throttle forward (2).
+// The state vector is (position, velocity) and every step costs -1 until the car reaches the goal.
+type MountainCar struct {
+	position float64
+	velocity float64
+}
+
+// NewMountainCar returns a new MountainCar Environment object.
+func NewMountainCar(rng *mathlib.Random) Environment {
+	env := MountainCar{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *MountainCar) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *MountainCar) GetStateDim() int {
+	return 2
+}
+
+// ObservationSpace returns the bounds of the position and velocity.
+func (env *MountainCar) ObservationSpace() Space {
+	return NewBoxSpace([]float64{-1.2, -0.07}, []float64{0.6, 0.07})
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *MountainCar) GetNumActions() int {
+	return 3
+}
+
+// GetGamma returns \gamma
+func (env *MountainCar) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies throttle a-1 and returns -1.
+func (env *MountainCar) Transition(a int, rng *mathlib.Random) float64 {
+	env.velocity += 0.001*float64(a-1) - 0.0025*math.Cos(3*env.position)
+	env.velocity = clip(env.velocity, -0.07, 0.07)
+	env.position += env.velocity
+	env.position = clip(env.position, -1.2, 0.6)
+	// Hitting the left wall stops the car
+	if env.position == -1.2 && env.velocity < 0 {
+		env.velocity = 0
+	}
+	return -1.0
+}
+
+// GetState returns (position, velocity)
+func (env *MountainCar) GetState() []float64 {
+	return []float64{env.position, env.velocity}
+}
+
+// InTAS returns whether the car has reached the goal at the top of the right hill.
+func (env *MountainCar) InTAS() bool {
+	return env.position >= 0.5
+}
+
+// NewEpisode places the car at rest at a uniformly random position in [-0.6, -0.4).
+func (env *MountainCar) NewEpisode(rng *mathlib.Random) {
+	env.position = uniform(rng, -0.6, -0.4)
+	env.velocity = 0
+}
+
+// Constants of the cart-pole of Barto, Sutton and Anderson (1983), as used by OpenAI Gym.
+const (
+	cartPoleGravity    = 9.8
+	cartPoleMassCart   = 1.0
+	cartPoleMassPole   = 0.1
+	cartPoleTotalMass  = cartPoleMassCart + cartPoleMassPole
+	cartPoleHalfLength = 0.5
+	cartPolePoleMassL  = cartPoleMassPole * cartPoleHalfLength
+	cartPoleForce      = 10.0
+	cartPoleTau        = 0.02 // Seconds between state updates
+	cartPoleMaxAngle   = 12 * 2 * math.Pi / 360
+	cartPoleMaxX       = 2.4
+)
+
+// CartPole is the cart-pole balancing task of Barto, Sutton and Anderson (1983) with Euler integration.
+// Actions push the cart left (0) or right (1). The state vector is (x, x_dot, theta, theta_dot) and every
+// step, including the one that drops the pole, pays +1.
+type CartPole struct {
+	x, xDot, theta, thetaDot float64
+}
+
+// NewCartPole returns a new CartPole Environment object.
+func NewCartPole(rng *mathlib.Random) Environment {
+	env := CartPole{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *CartPole) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *CartPole) GetStateDim() int {
+	return 4
+}
+
+// ObservationSpace returns the bounds of the state variables. The velocities are unbounded and
+// the position and angle may exceed the termination thresholds by one step.
+func (env *CartPole) ObservationSpace() Space {
+	inf := math.Inf(1)
+	return NewBoxSpace(
+		[]float64{-2 * cartPoleMaxX, -inf, -2 * cartPoleMaxAngle, -inf},
+		[]float64{2 * cartPoleMaxX, inf, 2 * cartPoleMaxAngle, inf},
+	)
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *CartPole) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *CartPole) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition pushes the cart and returns +1.
+func (env *CartPole) Transition(a int, rng *mathlib.Random) float64 {
+	force := -cartPoleForce
+	if a == 1 {
+		force = cartPoleForce
+	}
+	cosTheta := math.Cos(env.theta)
+	sinTheta := math.Sin(env.theta)
+
+	temp := (force + cartPolePoleMassL*env.thetaDot*env.thetaDot*sinTheta) / cartPoleTotalMass
+	thetaAcc := (cartPoleGravity*sinTheta - cosTheta*temp) /
+		(cartPoleHalfLength * (4.0/3.0 - cartPoleMassPole*cosTheta*cosTheta/cartPoleTotalMass))
+	xAcc := temp - cartPolePoleMassL*thetaAcc*cosTheta/cartPoleTotalMass
+
+	env.x += cartPoleTau * env.xDot
+	env.xDot += cartPoleTau * xAcc
+	env.theta += cartPoleTau * env.thetaDot
+	env.thetaDot += cartPoleTau * thetaAcc
+	return 1.0
+}
+
+// GetState returns (x, x_dot, theta, theta_dot)
+func (env *CartPole) GetState() []float64 {
+	return []float64{env.x, env.xDot, env.theta, env.thetaDot}
+}
+
+// InTAS returns whether the pole has fallen past 12 degrees or the cart has left the track.
+func (env *CartPole) InTAS() bool {
+	return math.Abs(env.x) > cartPoleMaxX || math.Abs(env.theta) > cartPoleMaxAngle
+}
+
+// NewEpisode draws every state variable uniformly from [-0.05, 0.05).
+func (env *CartPole) NewEpisode(rng *mathlib.Random) {
+	env.x = uniform(rng, -0.05, 0.05)
+	env.xDot = uniform(rng, -0.05, 0.05)
+	env.theta = uniform(rng, -0.05, 0.05)
+	env.thetaDot = uniform(rng, -0.05, 0.05)
+}
+
+// Constants of the acrobot of Sutton (1996), as used by OpenAI Gym.
+const (
+	acrobotDt      = 0.2
+	acrobotL1      = 1.0 // Length of link 1
+	acrobotM1      = 1.0 // Mass of link 1
+	acrobotM2      = 1.0 // Mass of link 2
+	acrobotLC1     = 0.5 // Position of the center of mass of link 1
+	acrobotLC2     = 0.5 // Position of the center of mass of link 2
+	acrobotMOI     = 1.0 // Moment of inertia of both links
+	acrobotMaxVel1 = 4 * math.Pi
+	acrobotMaxVel2 = 9 * math.Pi
+	acrobotGravity = 9.8
+)
+
+// Acrobot is the two-link underactuated swing-up task of Sutton (1996) with the dynamics of
+// Sutton and Barto (1998), integrated with RK4. Actions apply a torque of -1 (0), 0 (1) or +1 (2)
+// to the joint between the links. The state vector is
+// (cos theta1, sin theta1, cos theta2, sin theta2, theta1_dot, theta2_dot) and every step costs -1
+// until the tip swings above the bar.
+type Acrobot struct {
+	s [4]float64 // theta1, theta2, theta1_dot, theta2_dot
+}
+
+// NewAcrobot returns a new Acrobot Environment object.
+func NewAcrobot(rng *mathlib.Random) Environment {
+	env := Acrobot{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *Acrobot) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *Acrobot) GetStateDim() int {
+	return 6
+}
+
+// ObservationSpace returns the bounds of the cosines, sines and joint velocities.
+func (env *Acrobot) ObservationSpace() Space {
+	return NewBoxSpace(
+		[]float64{-1, -1, -1, -1, -acrobotMaxVel1, -acrobotMaxVel2},
+		[]float64{1, 1, 1, 1, acrobotMaxVel1, acrobotMaxVel2},
+	)
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *Acrobot) GetNumActions() int {
+	return 3
+}
+
+// GetGamma returns \gamma
+func (env *Acrobot) GetGamma() float64 {
+	return 1.0
+}
+
+// dsdt returns the time derivative of state s under the passed torque.
+func (env *Acrobot) dsdt(s [4]float64, torque float64) [4]float64 {
+	theta1, theta2, dtheta1, dtheta2 := s[0], s[1], s[2], s[3]
+	m1, m2, l1, lc1, lc2, i1, i2, g := acrobotM1, acrobotM2, acrobotL1, acrobotLC1, acrobotLC2, acrobotMOI, acrobotMOI, acrobotGravity
+
+	d1 := m1*lc1*lc1 + m2*(l1*l1+lc2*lc2+2*l1*lc2*math.Cos(theta2)) + i1 + i2
+	d2 := m2*(lc2*lc2+l1*lc2*math.Cos(theta2)) + i2
+	phi2 := m2 * lc2 * g * math.Cos(theta1+theta2-math.Pi/2)
+	phi1 := -m2*l1*lc2*dtheta2*dtheta2*math.Sin(theta2) -
+		2*m2*l1*lc2*dtheta2*dtheta1*math.Sin(theta2) +
+		(m1*lc1+m2*l1)*g*math.Cos(theta1-math.Pi/2) + phi2
+	ddtheta2 := (torque + d2/d1*phi1 - m2*l1*lc2*dtheta1*dtheta1*math.Sin(theta2) - phi2) /
+		(m2*lc2*lc2 + i2 - d2*d2/d1)
+	ddtheta1 := -(d2*ddtheta2 + phi1) / d1
+	return [4]float64{dtheta1, dtheta2, ddtheta1, ddtheta2}
+}
+
+// Transition applies torque a-1 for one RK4 step of length 0.2 and returns -1, or 0 once the goal is reached.
+func (env *Acrobot) Transition(a int, rng *mathlib.Random) float64 {
+	torque := float64(a - 1)
+	s := env.s
+	step := func(s [4]float64, k [4]float64, h float64) [4]float64 {
+		for i := range s {
+			s[i] += h * k[i]
+		}
+		return s
+	}
+	k1 := env.dsdt(s, torque)
+	k2 := env.dsdt(step(s, k1, acrobotDt/2), torque)
+	k3 := env.dsdt(step(s, k2, acrobotDt/2), torque)
+	k4 := env.dsdt(step(s, k3, acrobotDt), torque)
+	for i := range s {
+		s[i] += acrobotDt / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+	}
+
+	env.s[0] = wrapAngle(s[0])
+	env.s[1] = wrapAngle(s[1])
+	env.s[2] = clip(s[2], -acrobotMaxVel1, acrobotMaxVel1)
+	env.s[3] = clip(s[3], -acrobotMaxVel2, acrobotMaxVel2)
+
+	if env.InTAS() {
+		return 0.0
+	}
+	return -1.0
+}
+
+// GetState returns (cos theta1, sin theta1, cos theta2, sin theta2, theta1_dot, theta2_dot)
+func (env *Acrobot) GetState() []float64 {
+	return []float64{
+		math.Cos(env.s[0]), math.Sin(env.s[0]),
+		math.Cos(env.s[1]), math.Sin(env.s[1]),
+		env.s[2], env.s[3],
+	}
+}
+
+// InTAS returns whether the tip of the second link is one link length above the base.
+func (env *Acrobot) InTAS() bool {
+	return -math.Cos(env.s[0])-math.Cos(env.s[1]+env.s[0]) > 1.0
+}
+
+// NewEpisode draws every state variable uniformly from [-0.1, 0.1).
+func (env *Acrobot) NewEpisode(rng *mathlib.Random) {
+	for i := range env.s {
+		env.s[i] = uniform(rng, -0.1, 0.1)
+	}
+}
+
+// Constants of the pendulum of OpenAI Gym's Pendulum-v1.
+const (
+	pendulumMaxSpeed  = 8.0
+	pendulumMaxTorque = 2.0
+	pendulumDt        = 0.05
+	pendulumGravity   = 10.0
+	pendulumMass      = 1.0
+	pendulumLength    = 1.0
+)
+
+// Pendulum is the inverted pendulum swing-up task of OpenAI Gym's Pendulum-v1 with the torque range
+// [-2, 2] discretized into evenly spaced actions. The state vector is (cos theta, sin theta, theta_dot)
+// with theta = 0 upright. The task never terminates, so it needs a time limit.
+type Pendulum struct {
+	torques  []float64 // Torque applied by each action
+	theta    float64
+	thetaDot float64
+}
+
+// NewPendulum returns a new Pendulum Environment object with numActions evenly spaced torques (at least 2).
+func NewPendulum(numActions int, rng *mathlib.Random) Environment {
+	if numActions < 2 {
+		panic("Pendulum needs at least two actions.")
+	}
+	env := Pendulum{}
+	env.torques = make([]float64, numActions)
+	for a := 0; a < numActions; a++ {
+		env.torques[a] = -pendulumMaxTorque + 2*pendulumMaxTorque*float64(a)/float64(numActions-1)
+	}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *Pendulum) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *Pendulum) GetStateDim() int {
+	return 3
+}
+
+// ObservationSpace returns the bounds of the cosine, sine and angular velocity.
+func (env *Pendulum) ObservationSpace() Space {
+	return NewBoxSpace([]float64{-1, -1, -pendulumMaxSpeed}, []float64{1, 1, pendulumMaxSpeed})
+}
+
+// GetNumActions returns the number of discretized torques.
+func (env *Pendulum) GetNumActions() int {
+	return len(env.torques)
+}
+
+// GetGamma returns \gamma
+func (env *Pendulum) GetGamma() float64 {
+	return 0.99
+}
+
+// Transition applies the torque of action a and returns the negative cost of the state before the step.
+func (env *Pendulum) Transition(a int, rng *mathlib.Random) float64 {
+	return env.applyTorque(env.torques[a])
+}
+
+// applyTorque steps the dynamics under torque u and returns the reward.
+func (env *Pendulum) applyTorque(u float64) float64 {
+	u = clip(u, -pendulumMaxTorque, pendulumMaxTorque)
+	angle := wrapAngle(env.theta)
+	cost := angle*angle + 0.1*env.thetaDot*env.thetaDot + 0.001*u*u
+
+	env.thetaDot += (3*pendulumGravity/(2*pendulumLength)*math.Sin(env.theta) +
+		3.0/(pendulumMass*pendulumLength*pendulumLength)*u) * pendulumDt
+	env.thetaDot = clip(env.thetaDot, -pendulumMaxSpeed, pendulumMaxSpeed)
+	env.theta += env.thetaDot * pendulumDt
+	return -cost
+}
+
+// GetState returns (cos theta, sin theta, theta_dot)
+func (env *Pendulum) GetState() []float64 {
+	return []float64{math.Cos(env.theta), math.Sin(env.theta), env.thetaDot}
+}
+
+// InTAS always returns false since the pendulum never terminates.
+func (env *Pendulum) InTAS() bool {
+	return false
+}
+
+// NewEpisode draws theta uniformly from [-pi, pi) and theta_dot uniformly from [-1, 1).
+func (env *Pendulum) NewEpisode(rng *mathlib.Random) {
+	env.theta = uniform(rng, -math.Pi, math.Pi)
+	env.thetaDot = uniform(rng, -1, 1)
+}
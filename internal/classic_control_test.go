@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountainCarEnergyPumping(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	car := NewMountainCar(r)
+	assert.Len(t, car.GetState(), car.GetStateDim())
+
+	// Accelerating in the direction of motion reaches the goal well within 200 steps
+	steps := 0
+	for ; !car.InTAS() && steps < 1000; steps++ {
+		action := 2
+		if car.GetState()[1] < 0 {
+			action = 0
+		}
+		assert.Equal(t, -1.0, car.Transition(action, r))
+	}
+	assert.True(t, car.InTAS())
+	assert.True(t, steps < 200)
+}
+
+func TestCartPoleFallsUnderConstantForce(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	pole := NewCartPole(r)
+	assert.Len(t, pole.GetState(), pole.GetStateDim())
+	steps := 0
+	for ; !pole.InTAS() && steps < 500; steps++ {
+		assert.Equal(t, 1.0, pole.Transition(1, r))
+	}
+	assert.True(t, pole.InTAS())
+	assert.True(t, steps < 50)
+}
+
+func TestAcrobotStaysBounded(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	acrobot := NewAcrobot(r)
+	for i := 0; i < 500 && !acrobot.InTAS(); i++ {
+		acrobot.Transition(int(r.Float64()*3), r)
+		s := acrobot.GetState()
+		assert.Len(t, s, acrobot.GetStateDim())
+		assert.InDelta(t, 1.0, s[0]*s[0]+s[1]*s[1], 1e-9)
+		assert.True(t, math.Abs(s[4]) <= acrobotMaxVel1)
+		assert.True(t, math.Abs(s[5]) <= acrobotMaxVel2)
+	}
+}
+
+func TestPendulumDiscretizedTorques(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	pendulum := NewPendulum(5, r).(*Pendulum)
+	assert.Equal(t, 5, pendulum.GetNumActions())
+	assert.Equal(t, []float64{-2, -1, 0, 1, 2}, pendulum.torques)
+
+	// Hanging straight down at rest with no torque stays there and costs pi^2 per step
+	pendulum.theta = math.Pi
+	pendulum.thetaDot = 0
+	assert.InDelta(t, -math.Pi*math.Pi, pendulum.Transition(2, r), 1e-9)
+	assert.False(t, pendulum.InTAS())
+}
@@ -0,0 +1,75 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+const (
+	cliffWidth  = 12
+	cliffHeight = 4
+)
+
+// CliffWalking is the cliff walking task of Sutton and Barto (2018), Example 6.6. The agent walks
+// from the bottom-left to the bottom-right corner of a 4x12 grid. Every step costs -1, and stepping
+// into the cliff along the bottom edge costs -100 and sends the agent back to the start.
+// Actions are up (0), right (1), down (2) and left (3).
+type CliffWalking struct {
+	x int // Agent horizontal coordinate (0 to 11)
+	y int // Agent vertical coordinate (0 to 3)
+}
+
+// NewCliffWalking returns a new CliffWalking Environment object.
+func NewCliffWalking(rng *mathlib.Random) Environment {
+	env := CliffWalking{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *CliffWalking) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *CliffWalking) GetStateDim() int {
+	return cliffWidth * cliffHeight
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *CliffWalking) GetNumActions() int {
+	return 4
+}
+
+// GetGamma returns \gamma
+func (env *CliffWalking) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *CliffWalking) Transition(a int, rng *mathlib.Random) float64 {
+	xPrime, yPrime := gridStep(env.x, env.y, a)
+	if xPrime >= 0 && yPrime >= 0 && xPrime < cliffWidth && yPrime < cliffHeight {
+		env.x = xPrime
+		env.y = yPrime
+	}
+	if env.y == cliffHeight-1 && env.x > 0 && env.x < cliffWidth-1 {
+		// Fell off the cliff
+		env.x = 0
+		return -100.0
+	}
+	return -1.0
+}
+
+// GetState returns the one-hot encoding of the agent's position.
+func (env *CliffWalking) GetState() []float64 {
+	return mathlib.ToOneHot(env.y*cliffWidth+env.x, env.GetStateDim())
+}
+
+// InTAS returns whether the agent has reached the goal.
+func (env *CliffWalking) InTAS() bool {
+	return env.x == cliffWidth-1 && env.y == cliffHeight-1
+}
+
+// NewEpisode moves the agent to the bottom-left corner.
+func (env *CliffWalking) NewEpisode(rng *mathlib.Random) {
+	env.x = 0
+	env.y = cliffHeight - 1
+}
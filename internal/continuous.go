@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// ContinuousEnvironment is an Environment whose actions are real vectors instead of indices into a
+// finite action set. Its action space is a Box.
+type ContinuousEnvironment interface {
+	// How many episodes should be run?
+	GetMaxEps() int
+	// This function returns the dimension (length) of state vectors.
+	GetStateDim() int
+	// This function returns the Box of valid actions. Actions outside it are clipped.
+	ActionSpace() Space
+	// This function returns \gamma
+	GetGamma() float64
+	// This function applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+	Transition(a []float64, rng *mathlib.Random) float64
+	// This function returns the current state of the environment
+	GetState() []float64
+	// Check if the episode is over, as for Environment. Continuous environments may also implement Truncator.
+	InTAS() bool
+	// This function resets the environment to start a new episode (it samples the state from the initial state distribution).
+	NewEpisode(rng *mathlib.Random)
+}
+
+// ContinuousAgent learns in a ContinuousEnvironment. It is updated after every transition.
+type ContinuousAgent interface {
+	// GetAction returns the action that the agent selects from the state.
+	GetAction(s []float64, rng *mathlib.Random) []float64
+	// NewEpisode tells the agent that it is at the start of a new episode.
+	NewEpisode()
+	// Reset the agent entirely - to a blank slate prior to learning.
+	Reset(rng *mathlib.Random)
+	// Supports returns an error describing why the agent cannot learn with these observation and action spaces, or nil.
+	Supports(observation Space, action Space) error
+	// Update given a (s,a,r,s') tuple where s' is not the end of the episode.
+	Update(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random)
+	// LastUpdate lets the agent update/learn when sPrime would be the terminal absorbing state.
+	LastUpdate(s []float64, a []float64, r float64, rng *mathlib.Random)
+	// TruncatedUpdate lets the agent update/learn when the episode was cut off in the non-terminal state sPrime.
+	TruncatedUpdate(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random)
+}
+
+// ContinuousObservationSpaceOf returns the observation space of env, like ObservationSpaceOf. A
+// continuous environment that does not declare one observes an unbounded box of GetStateDim() reals.
+func ContinuousObservationSpaceOf(env ContinuousEnvironment) Space {
+	if s, ok := env.(ObservationSpacer); ok {
+		return s.ObservationSpace()
+	}
+	return NewUnboundedBoxSpace(env.GetStateDim())
+}
+
+// RunContinuousEpisode calculates the return from the episode of running the agent in the
+// continuous environment. It is RunEpisode for continuous actions.
+func RunContinuousEpisode(
+	agt ContinuousAgent,
+	env ContinuousEnvironment,
+	gamma float64,
+	rng *mathlib.Random,
+) float64 {
+	return runContinuousEpisode(agt, env, gamma, streams{agent: rng, env: rng})
+}
+
+// runContinuousEpisode is RunContinuousEpisode with the agent and the environment drawing from their own streams.
+func runContinuousEpisode(
+	agt ContinuousAgent,
+	env ContinuousEnvironment,
+	gamma float64,
+	rngs streams,
+) float64 {
+
+	// Prepare objects
+	env.NewEpisode(rngs.env)
+	agt.NewEpisode()
+
+	result := 0.0
+	curGamma := 1.0
+	curState := env.GetState()
+
+	// Loop over time
+	for {
+		action := agt.GetAction(curState, rngs.agent)
+		reward := env.Transition(action, rngs.env)
+		result += curGamma * reward
+		curGamma *= gamma
+
+		// Check if the episode is over
+		if env.InTAS() {
+			if tr, ok := env.(Truncator); ok && tr.Truncated() {
+				agt.TruncatedUpdate(curState, action, reward, env.GetState(), rngs.agent)
+			} else {
+				agt.LastUpdate(curState, action, reward, rngs.agent)
+			}
+			break
+		}
+
+		newState := env.GetState()
+		agt.Update(curState, action, reward, newState, rngs.agent)
+		curState = newState
+	}
+	return result
+}
+
+// RunContinuousAgentEnvironment runs the passed agent in the continuous environment and returns the
+// return of every episode.
+func RunContinuousAgentEnvironment(
+	agt ContinuousAgent,
+	env ContinuousEnvironment,
+	numEps int,
+	gamma float64,
+	rng *mathlib.Random,
+) []float64 {
+	return runContinuousAgentEnvironment(agt, env, numEps, gamma, streams{agent: rng, env: rng})
+}
+
+// runContinuousAgentEnvironment is RunContinuousAgentEnvironment with the agent and the
+// environment drawing from their own streams.
+func runContinuousAgentEnvironment(
+	agt ContinuousAgent,
+	env ContinuousEnvironment,
+	numEps int,
+	gamma float64,
+	rngs streams,
+) []float64 {
+	// Wipe the agent to start a new trial
+	agt.Reset(rngs.agent)
+
+	result := make([]float64, numEps)
+	for epCount := 0; epCount < numEps; epCount++ {
+		result[epCount] = runContinuousEpisode(agt, env, gamma, rngs)
+	}
+	return result
+}
+
+type continuousAgentConstructor func() ContinuousAgent
+type continuousEnvironmentConstructor func() ContinuousEnvironment
+
+// RunContinuousTrials is RunTrials for continuous agents and environments. Its results are
+// reproducible, and its failed trials reported, in the same way.
+func RunContinuousTrials(rng *mathlib.Random,
+	agentConstructor continuousAgentConstructor,
+	envConstructor continuousEnvironmentConstructor,
+	numTrials int,
+	fileName string,
+) error {
+
+	// Get environment settings
+	env := envConstructor()
+	obs, act := ContinuousObservationSpaceOf(env), env.ActionSpace()
+	numEps := env.GetMaxEps()
+	gamma := env.GetGamma()
+	closeEnvironment(env)
+	if err := agentConstructor().Supports(obs, act); err != nil {
+		return fmt.Errorf("agent does not support observations %v and actions %v: %v", obs, act, err)
+	}
+
+	returns, errs := runParallelTrials(context.Background(), numTrials, 0, nil, func(i int) func() ([]float64, error) {
+		env, agt, rngs := envConstructor(), agentConstructor(), splitStreams(rng)
+		return func() ([]float64, error) {
+			defer closeEnvironment(env)
+			return runContinuousAgentEnvironment(agt, env, numEps, gamma, rngs), nil
+		}
+	})
+	return writeFinishedReturns(returns, errs, outputPath("", fileName))
+}
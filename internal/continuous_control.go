@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// ContinuousPendulum is OpenAI Gym's Pendulum-v1: the Pendulum task with the torque in [-2, 2]
+// chosen directly. Episodes are truncated after 200 steps, as in Gym.
+type ContinuousPendulum struct {
+	pendulum  Pendulum
+	t         int // Time into the episode
+	truncated bool
+}
+
+// NewContinuousPendulum returns a new ContinuousPendulum ContinuousEnvironment object.
+func NewContinuousPendulum(rng *mathlib.Random) ContinuousEnvironment {
+	env := ContinuousPendulum{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *ContinuousPendulum) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *ContinuousPendulum) GetStateDim() int {
+	return env.pendulum.GetStateDim()
+}
+
+// ObservationSpace returns the bounds of the cosine, sine and angular velocity.
+func (env *ContinuousPendulum) ObservationSpace() Space {
+	return env.pendulum.ObservationSpace()
+}
+
+// ActionSpace returns the range of torques.
+func (env *ContinuousPendulum) ActionSpace() Space {
+	return NewBoxSpace([]float64{-pendulumMaxTorque}, []float64{pendulumMaxTorque})
+}
+
+// GetGamma returns \gamma
+func (env *ContinuousPendulum) GetGamma() float64 {
+	return env.pendulum.GetGamma()
+}
+
+// Transition applies the torque a[0] and returns the negative cost of the state before the step.
+func (env *ContinuousPendulum) Transition(a []float64, rng *mathlib.Random) float64 {
+	reward := env.pendulum.applyTorque(a[0])
+	env.t++
+	if env.t == 200 {
+		env.truncated = true
+	}
+	return reward
+}
+
+// GetState returns (cos theta, sin theta, theta_dot)
+func (env *ContinuousPendulum) GetState() []float64 {
+	return env.pendulum.GetState()
+}
+
+// InTAS returns whether the episode was truncated, since the pendulum never terminates.
+func (env *ContinuousPendulum) InTAS() bool {
+	return env.truncated
+}
+
+// Truncated returns whether the episode ran out of time, which is the only way it ends.
+func (env *ContinuousPendulum) Truncated() bool {
+	return env.truncated
+}
+
+// NewEpisode draws theta uniformly from [-pi, pi) and theta_dot uniformly from [-1, 1).
+func (env *ContinuousPendulum) NewEpisode(rng *mathlib.Random) {
+	env.pendulum.NewEpisode(rng)
+	env.t = 0
+	env.truncated = false
+}
+
+// ContinuousMountainCar is OpenAI Gym's MountainCarContinuous-v0. The action a[0] in [-1, 1] is the
+// throttle, each step costs 0.1 a[0]^2 and reaching the goal at position 0.45 gives +100.
+// Episodes are truncated after 999 steps, as in Gym.
+type ContinuousMountainCar struct {
+	position  float64
+	velocity  float64
+	t         int // Time into the episode
+	truncated bool
+}
+
+// NewContinuousMountainCar returns a new ContinuousMountainCar ContinuousEnvironment object.
+func NewContinuousMountainCar(rng *mathlib.Random) ContinuousEnvironment {
+	env := ContinuousMountainCar{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *ContinuousMountainCar) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *ContinuousMountainCar) GetStateDim() int {
+	return 2
+}
+
+// ObservationSpace returns the bounds of the position and velocity.
+func (env *ContinuousMountainCar) ObservationSpace() Space {
+	return NewBoxSpace([]float64{-1.2, -0.07}, []float64{0.6, 0.07})
+}
+
+// ActionSpace returns the range of throttles.
+func (env *ContinuousMountainCar) ActionSpace() Space {
+	return NewBoxSpace([]float64{-1}, []float64{1})
+}
+
+// GetGamma returns \gamma
+func (env *ContinuousMountainCar) GetGamma() float64 {
+	return 0.99
+}
+
+// Transition applies throttle a[0] and returns the reward.
+func (env *ContinuousMountainCar) Transition(a []float64, rng *mathlib.Random) float64 {
+	force := clip(a[0], -1, 1)
+	env.velocity += 0.0015*force - 0.0025*math.Cos(3*env.position)
+	env.velocity = clip(env.velocity, -0.07, 0.07)
+	env.position += env.velocity
+	env.position = clip(env.position, -1.2, 0.6)
+	// Hitting the left wall stops the car
+	if env.position == -1.2 && env.velocity < 0 {
+		env.velocity = 0
+	}
+	env.t++
+
+	reward := -0.1 * force * force
+	if env.atGoal() {
+		reward += 100
+	} else if env.t == 999 {
+		env.truncated = true
+	}
+	return reward
+}
+
+// atGoal returns whether the car has reached the flag.
+func (env *ContinuousMountainCar) atGoal() bool {
+	return env.position >= 0.45
+}
+
+// GetState returns (position, velocity)
+func (env *ContinuousMountainCar) GetState() []float64 {
+	return []float64{env.position, env.velocity}
+}
+
+// InTAS returns whether the car has reached the goal or the episode ran out of time.
+func (env *ContinuousMountainCar) InTAS() bool {
+	return env.atGoal() || env.truncated
+}
+
+// Truncated returns whether the episode ran out of time.
+func (env *ContinuousMountainCar) Truncated() bool {
+	return env.truncated
+}
+
+// NewEpisode places the car at rest at a uniformly random position in [-0.6, -0.4).
+func (env *ContinuousMountainCar) NewEpisode(rng *mathlib.Random) {
+	env.position = uniform(rng, -0.6, -0.4)
+	env.velocity = 0
+	env.t = 0
+	env.truncated = false
+}
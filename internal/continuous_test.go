@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// targetBandit is a one-step ContinuousEnvironment whose reward is -(a - target)^2.
+type targetBandit struct {
+	target float64
+	tas    bool
+}
+
+func (env *targetBandit) GetMaxEps() int     { return 2000 }
+func (env *targetBandit) GetStateDim() int   { return 1 }
+func (env *targetBandit) ActionSpace() Space { return NewBoxSpace([]float64{-2}, []float64{2}) }
+func (env *targetBandit) GetGamma() float64  { return 1 }
+func (env *targetBandit) GetState() []float64 {
+	return []float64{1}
+}
+func (env *targetBandit) InTAS() bool                    { return env.tas }
+func (env *targetBandit) NewEpisode(rng *mathlib.Random) { env.tas = false }
+func (env *targetBandit) Transition(a []float64, rng *mathlib.Random) float64 {
+	env.tas = true
+	return -(a[0] - env.target) * (a[0] - env.target)
+}
+
+func TestGaussianGradLogProb(t *testing.T) {
+	p := newGaussianPolicy(2, 1, 0.7)
+	p.mean[0] = []float64{0.3, -0.2, 0.1}
+	s, a := []float64{1, 2}, []float64{0.4}
+	logProb := func() float64 {
+		mu := mathlib.Dot(p.mean[0], features(s))
+		std := math.Exp(p.logStd[0])
+		return -(a[0]-mu)*(a[0]-mu)/(2*std*std) - math.Log(std)
+	}
+	gradMean, gradLogStd := p.gradLogProb(s, a)
+
+	// Compare with central differences
+	h := 1e-6
+	for j := range p.mean[0] {
+		p.mean[0][j] += h
+		up := logProb()
+		p.mean[0][j] -= 2 * h
+		down := logProb()
+		p.mean[0][j] += h
+		assert.InDelta(t, (up-down)/(2*h), gradMean[0][j], 1e-6)
+	}
+	p.logStd[0] += h
+	up := logProb()
+	p.logStd[0] -= 2 * h
+	down := logProb()
+	assert.InDelta(t, (up-down)/(2*h), gradLogStd[0], 1e-6)
+}
+
+func TestGaussianAgentsLearn(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	env := &targetBandit{target: 0.5}
+	// An environment that does not declare its observations observes real vectors
+	assert.Equal(t, NewUnboundedBoxSpace(1), ContinuousObservationSpaceOf(env))
+	for _, agt := range []ContinuousAgent{
+		NewGaussianREINFORCE(1, 1, 1, 0.01, 1),
+		NewGaussianActorCritic(1, 1, 1, 0.01, 0.1, 1),
+	} {
+		assert.NoError(t, agt.Supports(ContinuousObservationSpaceOf(env), env.ActionSpace()))
+		returns := RunContinuousAgentEnvironment(agt, env, env.GetMaxEps(), env.GetGamma(), r)
+		assert.True(t, mathlib.Mean(returns[1900:]) > mathlib.Mean(returns[:100]))
+
+		// The mean moved to the target
+		s := env.GetState()
+		mean := 0.0
+		for i := 0; i < 1000; i++ {
+			mean += agt.GetAction(s, r)[0] / 1000
+		}
+		assert.InDelta(t, 0.5, mean, 0.15)
+	}
+}
+
+func TestContinuousPendulum(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	env := NewContinuousPendulum(r)
+	assert.Error(t, NewGaussianREINFORCE(3, 2, 1, 0.1, 1).Supports(ContinuousObservationSpaceOf(env), env.ActionSpace()))
+	assert.NoError(t, NewGaussianActorCritic(3, 1, 1, 0.1, 0.1, 1).Supports(ContinuousObservationSpaceOf(env), env.ActionSpace()))
+
+	steps := 0
+	for !env.InTAS() {
+		reward := env.Transition([]float64{5}, r)
+		assert.True(t, reward <= 0)
+		steps++
+	}
+	assert.Equal(t, 200, steps)
+	assert.True(t, env.(Truncator).Truncated())
+	assert.Len(t, env.GetState(), 3)
+}
+
+func TestContinuousMountainCar(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	env := NewContinuousMountainCar(r)
+
+	// Pushing in the direction of the velocity swings the car up the hill
+	total := 0.0
+	for !env.InTAS() {
+		a := 1.0
+		if env.GetState()[1] < 0 {
+			a = -1
+		}
+		total += env.Transition([]float64{a}, r)
+	}
+	assert.False(t, env.(Truncator).Truncated())
+	assert.True(t, total > 80)
+}
@@ -1,6 +1,10 @@
 package internal
 
-import "github.com/jackkenney/evolve-rl/mathlib"
+import (
+	"io"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
 
 // Environment interface outlines abstract methods for an environment class.
 type Environment interface {
@@ -21,3 +25,35 @@ type Environment interface {
 	// This function resets the environment to start a new episode (it samples the state from the initial state distribution).
 	NewEpisode(rng *mathlib.Random)
 }
+
+// Truncator is implemented by environments that can end an episode without reaching a terminal
+// state, e.g. at a time limit. InTAS returns true for both kinds of episode end and Truncated tells
+// them apart. When Truncated returns true GetState must still return the final state, so agents can
+// bootstrap from it.
+type Truncator interface {
+	Truncated() bool
+}
+
+// ErrorReporter is implemented by environments that can fail, e.g. because they run in another
+// process. Err returns the first failure, or nil. After a failure the environment ends every
+// episode at once, so runners finish and then report the error.
+type ErrorReporter interface {
+	Err() error
+}
+
+// envError returns the failure of env if it is an ErrorReporter, or nil.
+func envError(env Environment) error {
+	if e, ok := env.(ErrorReporter); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// closeEnvironment closes env if it is an io.Closer, like the remote and subprocess environments
+// whose sessions and processes outlive it otherwise. The error is ignored, since by then the
+// environment has done its work.
+func closeEnvironment(env interface{}) {
+	if c, ok := env.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -1,5 +1,10 @@
 package internal
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // EpisodeTracker struct tracks (s,a,r) tuples for episodic agent updates
 type EpisodeTracker struct {
 	t       int           // Timestep in current episode
@@ -52,3 +57,34 @@ func (ep *EpisodeTracker) Wipe() {
 	ep.actions = make([][]int, ep.N)
 	ep.rewards = make([][]float64, ep.N)
 }
+
+// episodeTrackerSnapshot is the contents of an EpisodeTracker.
+type episodeTrackerSnapshot struct {
+	T       int           `json:"t"`
+	EpCount int           `json:"episode"`
+	States  [][][]float64 `json:"states"`
+	Actions [][]int       `json:"actions"`
+	Rewards [][]float64   `json:"rewards"`
+}
+
+// Snapshot returns the contents of the tracker, i.e. the episodes since the last update.
+func (ep *EpisodeTracker) Snapshot() ([]byte, error) {
+	return json.Marshal(episodeTrackerSnapshot{T: ep.t, EpCount: ep.epCount, States: ep.states, Actions: ep.actions, Rewards: ep.rewards})
+}
+
+// Restore sets the contents of the tracker to a snapshot of a tracker with the same N.
+func (ep *EpisodeTracker) Restore(data []byte) error {
+	snap := episodeTrackerSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if len(snap.States) != ep.N || len(snap.Actions) != ep.N || len(snap.Rewards) != ep.N || snap.EpCount >= ep.N {
+		return fmt.Errorf("snapshot is not of a tracker of %d episodes", ep.N)
+	}
+	ep.t = snap.T
+	ep.epCount = snap.EpCount
+	ep.states = snap.States
+	ep.actions = snap.Actions
+	ep.rewards = snap.Rewards
+	return nil
+}
@@ -0,0 +1,145 @@
+package evolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// coevolutionCheckpoint is everything a Coevolution needs to carry on after the last generation it ran.
+type coevolutionCheckpoint struct {
+	Generation  int                `json:"generation"`
+	Populations [2][]*Genome       `json:"populations"`
+	Champions   [2][]*Genome       `json:"champions"`
+	HallOfFame  [2][]string        `json:"hall_of_fame"` // IDs of the members, which are all champions
+	Ratings     map[string]float64 `json:"ratings"`
+	EloHistory  []EloRecord        `json:"elo_history"`
+	Environment json.RawMessage    `json:"environment,omitempty"` // Snapshot of the environment, if it is an internal.Snapshotter
+	Random      json.RawMessage    `json:"random"`
+}
+
+// Generation returns the number of generations run so far.
+func (c *Coevolution) Generation() int {
+	return c.generation
+}
+
+// SaveCheckpoint writes the state of the co-evolution and of rng to path between generations, so
+// that LoadCheckpoint can continue it exactly where it stopped. The file is replaced in one rename,
+// so a run killed while saving leaves the previous checkpoint intact.
+func (c *Coevolution) SaveCheckpoint(path string, rng *mathlib.Random) error {
+	cp := coevolutionCheckpoint{
+		Generation:  c.generation,
+		Populations: c.populations,
+		Champions:   c.champions,
+		Ratings:     c.elo.ratings,
+		EloHistory:  c.eloHistory,
+	}
+	for p := 0; p < 2; p++ {
+		cp.HallOfFame[p] = []string{}
+		for _, g := range c.hallOfFame[p].Members() {
+			cp.HallOfFame[p] = append(cp.HallOfFame[p], g.ID)
+		}
+	}
+	var err error
+	if cp.Environment, err = internal.SnapshotOf(c.env); err != nil {
+		return err
+	}
+	if cp.Random, err = json.Marshal(rng); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadCheckpoint restores a checkpoint written by SaveCheckpoint of a co-evolution with the same
+// config and environment, and sets rng to the state it had then.
+func (c *Coevolution) LoadCheckpoint(path string, rng *mathlib.Random) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cp := coevolutionCheckpoint{}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	if err := c.restore(cp, rng); err != nil {
+		return fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	return nil
+}
+
+// restore sets the state of the co-evolution and of rng to the checkpoint, or returns an error if
+// the checkpoint does not fit its config and environment.
+func (c *Coevolution) restore(cp coevolutionCheckpoint, rng *mathlib.Random) error {
+	stateDim, numActions := c.env.GetStateDim(), c.env.GetNumActions()
+	fits := func(g *Genome) bool {
+		if g == nil || len(g.Theta) != stateDim {
+			return false
+		}
+		for _, row := range g.Theta {
+			if len(row) != numActions {
+				return false
+			}
+		}
+		return true
+	}
+
+	var hallOfFame [2]*HallOfFame
+	for p := 0; p < 2; p++ {
+		if len(cp.Populations[p]) != c.cfg.PopulationSize {
+			return fmt.Errorf("population %d has %d genomes, expected %d", p, len(cp.Populations[p]), c.cfg.PopulationSize)
+		}
+		if len(cp.Champions[p]) != cp.Generation {
+			return fmt.Errorf("population %d has %d champions after %d generations", p, len(cp.Champions[p]), cp.Generation)
+		}
+		for _, g := range cp.Populations[p] {
+			if !fits(g) {
+				return fmt.Errorf("population %d has a genome that is not %d x %d", p, stateDim, numActions)
+			}
+		}
+		champions := map[string]*Genome{}
+		for _, g := range cp.Champions[p] {
+			if !fits(g) {
+				return fmt.Errorf("population %d has a champion that is not %d x %d", p, stateDim, numActions)
+			}
+			champions[g.ID] = g
+		}
+		hallOfFame[p] = NewHallOfFame(c.cfg.HallOfFameSize)
+		for _, id := range cp.HallOfFame[p] {
+			g, ok := champions[id]
+			if !ok {
+				return fmt.Errorf("hall of fame %d has %q, which is not a champion", p, id)
+			}
+			hallOfFame[p].Add(g)
+		}
+	}
+	if err := json.Unmarshal(cp.Random, &mathlib.Random{}); err != nil {
+		return err
+	}
+	if err := internal.RestoreSnapshot(c.env, cp.Environment); err != nil {
+		return err
+	}
+	json.Unmarshal(cp.Random, rng)
+
+	c.generation = cp.Generation
+	c.populations = cp.Populations
+	c.champions = cp.Champions
+	c.hallOfFame = hallOfFame
+	c.elo = NewElo(c.cfg.EloK)
+	for id, r := range cp.Ratings {
+		c.elo.ratings[id] = r
+	}
+	c.eloHistory = cp.EloHistory
+	return nil
+}
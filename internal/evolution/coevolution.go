@@ -0,0 +1,286 @@
+package evolution
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// CoevolutionConfig holds the hyperparameters of competitive co-evolution.
+type CoevolutionConfig struct {
+	PopulationSize    int     // Genomes in each population
+	Generations       int     // Generations run by Run
+	Elites            int     // Best genomes of each population kept unchanged every generation
+	MutationStd       float64 // Standard deviation of the mutation noise
+	GamesPerPair      int     // Episodes played by every pair of opponents
+	HallOfFameSize    int     // Champions kept in each hall of fame
+	HallOfFameSamples int     // Hall of fame members every genome plays each generation
+	EloK              float64 // Update size of the Elo ratings
+}
+
+// DefaultCoevolutionConfig returns the hyperparameters used unless told otherwise.
+func DefaultCoevolutionConfig() CoevolutionConfig {
+	return CoevolutionConfig{
+		PopulationSize:    20,
+		Generations:       50,
+		Elites:            4,
+		MutationStd:       0.5,
+		GamesPerPair:      5,
+		HallOfFameSize:    20,
+		HallOfFameSamples: 5,
+		EloK:              16,
+	}
+}
+
+// EloRecord is the rating of a population's champion after the generation it won.
+type EloRecord struct {
+	Generation int
+	Population int
+	Champion   string
+	Rating     float64
+}
+
+// Coevolution evolves two populations of genomes, one for each seat of a two-player environment,
+// by evaluating each against the other and against a hall of fame of the other's past champions.
+// Fitness is the mean score against those opponents, where a game is won by the player with the
+// higher return. The champion of every generation is rated with Elo in a round robin against the
+// opposing hall of fame.
+type Coevolution struct {
+	cfg         CoevolutionConfig
+	env         internal.MultiAgentEnvironment
+	populations [2][]*Genome
+	hallOfFame  [2]*HallOfFame
+	champions   [2][]*Genome // Champion of every generation so far
+	elo         *Elo
+	eloHistory  []EloRecord
+	generation  int
+}
+
+// NewCoevolution returns a co-evolution of two populations of uniform random policies in env,
+// which must have two seats.
+func NewCoevolution(env internal.MultiAgentEnvironment, cfg CoevolutionConfig) (*Coevolution, error) {
+	if env.GetNumAgents() != 2 {
+		return nil, fmt.Errorf("co-evolution needs a two-player environment, got %d seats", env.GetNumAgents())
+	}
+	if cfg.Elites < 1 || cfg.Elites > cfg.PopulationSize {
+		return nil, fmt.Errorf("need between 1 and %d elites, got %d", cfg.PopulationSize, cfg.Elites)
+	}
+	c := Coevolution{cfg: cfg, env: env, elo: NewElo(cfg.EloK)}
+	for p := 0; p < 2; p++ {
+		c.populations[p] = make([]*Genome, cfg.PopulationSize)
+		for i := range c.populations[p] {
+			c.populations[p][i] = NewGenome(env.GetStateDim(), env.GetNumActions())
+		}
+		c.hallOfFame[p] = NewHallOfFame(cfg.HallOfFameSize)
+	}
+	return &c, nil
+}
+
+// Play returns the score of g0 in seat 0 against g1 in seat 1 over the passed number of games:
+// 1 per win, 0.5 per draw, divided by the number of games.
+func (c *Coevolution) Play(g0 *Genome, g1 *Genome, games int, rng *mathlib.Random) float64 {
+	score := 0.0
+	for i := 0; i < games; i++ {
+		returns := internal.RunMultiAgentEpisode([]internal.Agent{g0.Agent(), g1.Agent()}, c.env, 1, rng)
+		if returns[0] > returns[1] {
+			score++
+		} else if returns[0] == returns[1] {
+			score += 0.5
+		}
+	}
+	return score / float64(games)
+}
+
+// evaluate returns the fitness of every genome of both populations.
+func (c *Coevolution) evaluate(rng *mathlib.Random) [2][]float64 {
+	var fitness, opponents [2][]float64
+	for p := 0; p < 2; p++ {
+		fitness[p] = mathlib.Vector(c.cfg.PopulationSize, 0)
+		opponents[p] = mathlib.Vector(c.cfg.PopulationSize, 0)
+	}
+
+	// Every genome plays every genome of the other population
+	for i, g0 := range c.populations[0] {
+		for j, g1 := range c.populations[1] {
+			score := c.Play(g0, g1, c.cfg.GamesPerPair, rng)
+			fitness[0][i] += score
+			fitness[1][j] += 1 - score
+			opponents[0][i]++
+			opponents[1][j]++
+		}
+	}
+
+	// And a sample of the other population's past champions
+	for i, g := range c.populations[0] {
+		for _, past := range c.hallOfFame[1].Sample(c.cfg.HallOfFameSamples, rng) {
+			fitness[0][i] += c.Play(g, past, c.cfg.GamesPerPair, rng)
+			opponents[0][i]++
+		}
+	}
+	for j, g := range c.populations[1] {
+		for _, past := range c.hallOfFame[0].Sample(c.cfg.HallOfFameSamples, rng) {
+			fitness[1][j] += 1 - c.Play(past, g, c.cfg.GamesPerPair, rng)
+			opponents[1][j]++
+		}
+	}
+
+	for p := 0; p < 2; p++ {
+		for i := range fitness[p] {
+			fitness[p][i] /= opponents[p][i]
+		}
+	}
+	return fitness
+}
+
+// rate plays the new champions against the opposing halls of fame and updates their Elo ratings.
+func (c *Coevolution) rate(champions [2]*Genome, rng *mathlib.Random) {
+	for _, past := range c.hallOfFame[1].Members() {
+		c.elo.Update(champions[0].ID, past.ID, c.Play(champions[0], past, c.cfg.GamesPerPair, rng))
+	}
+	for _, past := range c.hallOfFame[0].Members() {
+		if past != champions[0] {
+			c.elo.Update(past.ID, champions[1].ID, c.Play(past, champions[1], c.cfg.GamesPerPair, rng))
+		}
+	}
+	for p := 0; p < 2; p++ {
+		c.eloHistory = append(c.eloHistory, EloRecord{
+			Generation: c.generation,
+			Population: p,
+			Champion:   champions[p].ID,
+			Rating:     c.elo.Rating(champions[p].ID),
+		})
+	}
+}
+
+// Step runs one generation: evaluation, hall of fame and rating updates, then selection of the
+// elites and mutation of them to refill the populations.
+func (c *Coevolution) Step(rng *mathlib.Random) {
+	fitness := c.evaluate(rng)
+
+	var champions [2]*Genome
+	var ranked [2][]int
+	for p := 0; p < 2; p++ {
+		ranked[p] = make([]int, c.cfg.PopulationSize)
+		for i := range ranked[p] {
+			ranked[p][i] = i
+		}
+		f := fitness[p]
+		sort.SliceStable(ranked[p], func(a, b int) bool { return f[ranked[p][a]] > f[ranked[p][b]] })
+
+		champions[p] = c.populations[p][ranked[p][0]].Clone()
+		champions[p].ID = fmt.Sprintf("p%d-g%d", p, c.generation)
+		c.champions[p] = append(c.champions[p], champions[p])
+		c.hallOfFame[p].Add(champions[p])
+	}
+	c.rate(champions, rng)
+
+	for p := 0; p < 2; p++ {
+		next := make([]*Genome, c.cfg.PopulationSize)
+		for i := 0; i < c.cfg.Elites; i++ {
+			next[i] = c.populations[p][ranked[p][i]]
+		}
+		for i := c.cfg.Elites; i < c.cfg.PopulationSize; i++ {
+			parent := next[int(rng.Float64()*float64(c.cfg.Elites))]
+			next[i] = parent.Mutate(c.cfg.MutationStd, rng)
+		}
+		c.populations[p] = next
+	}
+	c.generation++
+}
+
+// Run runs generations until the configured number have been run, so a co-evolution restored
+// from a checkpoint runs the rest of them.
+func (c *Coevolution) Run(rng *mathlib.Random) {
+	for c.generation < c.cfg.Generations {
+		c.Step(rng)
+	}
+}
+
+// Champions returns the champion of every generation so far of population p.
+func (c *Coevolution) Champions(p int) []*Genome {
+	return c.champions[p]
+}
+
+// EloHistory returns the rating of both champions after every generation so far.
+func (c *Coevolution) EloHistory() []EloRecord {
+	return c.eloHistory
+}
+
+// WinRates returns the cross-generation win-rate matrix, where result(i,j) = the score of the
+// champion of population 0 from generation i against the champion of population 1 from generation j.
+func (c *Coevolution) WinRates(rng *mathlib.Random) [][]float64 {
+	result := mathlib.Matrix(len(c.champions[0]), len(c.champions[1]), 0)
+	for i, g0 := range c.champions[0] {
+		for j, g1 := range c.champions[1] {
+			result[i][j] = c.Play(g0, g1, c.cfg.GamesPerPair, rng)
+		}
+	}
+	return result
+}
+
+// WriteEloHistory writes the Elo history as CSV, in the style of the returns files in data/.
+func WriteEloHistory(w io.Writer, history []EloRecord) error {
+	if _, err := fmt.Fprintln(w, "Generation, Population, Champion, Elo"); err != nil {
+		return err
+	}
+	for _, r := range history {
+		if _, err := fmt.Fprintf(w, "%d,%d,%s,%s\n", r.Generation, r.Population, r.Champion,
+			strconv.FormatFloat(r.Rating, 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteWinRates writes a win-rate matrix as CSV with a row per generation of population 0 and a
+// column per generation of population 1.
+func WriteWinRates(w io.Writer, winRates [][]float64) error {
+	header := "Generation"
+	if len(winRates) > 0 {
+		for j := range winRates[0] {
+			header += ", " + strconv.Itoa(j)
+		}
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+	for i, row := range winRates {
+		line := strconv.Itoa(i)
+		for _, x := range row {
+			line += "," + strconv.FormatFloat(x, 'g', -1, 64)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteResults writes the Elo history to <dir>/<fileName>_elo.csv and the cross-generation win-rate
+// matrix to <dir>/<fileName>_winrate.csv, where dir defaults to data.
+func (c *Coevolution) WriteResults(dir string, fileName string, rng *mathlib.Random) error {
+	if dir == "" {
+		dir = "data"
+	}
+	elo, err := os.Create(filepath.Join(dir, fileName+"_elo.csv"))
+	if err != nil {
+		return err
+	}
+	defer elo.Close()
+	if err := WriteEloHistory(elo, c.eloHistory); err != nil {
+		return err
+	}
+
+	winRate, err := os.Create(filepath.Join(dir, fileName+"_winrate.csv"))
+	if err != nil {
+		return err
+	}
+	defer winRate.Close()
+	return WriteWinRates(winRate, c.WinRates(rng))
+}
@@ -0,0 +1,39 @@
+package evolution
+
+import "math"
+
+// InitialElo is the rating of a player that has not played yet.
+const InitialElo = 1500.0
+
+// Elo keeps Elo ratings of players identified by name.
+type Elo struct {
+	K       float64 // Largest change of a rating from one game
+	ratings map[string]float64
+}
+
+// NewElo returns an empty rating table with update size k.
+func NewElo(k float64) *Elo {
+	return &Elo{K: k, ratings: map[string]float64{}}
+}
+
+// Rating returns the rating of player id.
+func (e *Elo) Rating(id string) float64 {
+	if r, ok := e.ratings[id]; ok {
+		return r
+	}
+	return InitialElo
+}
+
+// ExpectedScore returns the expected score of a player rated ra against a player rated rb.
+func ExpectedScore(ra float64, rb float64) float64 {
+	return 1 / (1 + math.Pow(10, (rb-ra)/400))
+}
+
+// Update rates the result of a game between a and b, where scoreA is 1 if a won, 0.5 for a draw
+// and 0 if a lost.
+func (e *Elo) Update(a string, b string, scoreA float64) {
+	ra, rb := e.Rating(a), e.Rating(b)
+	expected := ExpectedScore(ra, rb)
+	e.ratings[a] = ra + e.K*(scoreA-expected)
+	e.ratings[b] = rb - e.K*(scoreA-expected)
+}
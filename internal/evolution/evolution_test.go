@@ -0,0 +1,165 @@
+package evolution
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElo(t *testing.T) {
+	assert.Equal(t, 0.5, ExpectedScore(1500, 1500))
+	assert.InDelta(t, 0.909, ExpectedScore(1800, 1400), 1e-3)
+
+	e := NewElo(32)
+	e.Update("a", "b", 1)
+	assert.Equal(t, 1516.0, e.Rating("a"))
+	assert.Equal(t, 1484.0, e.Rating("b"))
+	assert.Equal(t, InitialElo, e.Rating("c"))
+}
+
+func TestHallOfFame(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	h := NewHallOfFame(2)
+	assert.Nil(t, h.Sample(3, rng))
+	a, b, c := NewGenome(1, 2), NewGenome(1, 2), NewGenome(1, 2)
+	h.Add(a)
+	h.Add(b)
+	h.Add(c)
+	assert.Equal(t, []*Genome{b, c}, h.Members())
+	for _, g := range h.Sample(10, rng) {
+		assert.True(t, g == b || g == c)
+	}
+}
+
+func TestMutateCopies(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	parent := NewGenome(2, 2)
+	child := parent.Mutate(1, rng)
+	assert.Equal(t, [][]float64{{0, 0}, {0, 0}}, parent.Theta)
+	assert.NotEqual(t, parent.Theta, child.Theta)
+}
+
+func TestCoevolutionFindsDominantAction(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	// Each player scores 1 for playing action 0, whatever the other does
+	env := internal.NewMatrixGame([][][2]float64{
+		{{1, 1}, {1, 0}},
+		{{0, 1}, {0, 0}},
+	}, 1, 1)
+	cfg := DefaultCoevolutionConfig()
+	cfg.PopulationSize = 8
+	cfg.Elites = 2
+	cfg.Generations = 15
+	c, err := NewCoevolution(env, cfg)
+	assert.NoError(t, err)
+	c.Run(rng)
+
+	start := env.GetStateDim() - 1
+	for p := 0; p < 2; p++ {
+		champions := c.Champions(p)
+		assert.Len(t, champions, 15)
+		last := champions[len(champions)-1]
+		assert.True(t, last.Theta[start][0] > last.Theta[start][1])
+	}
+	assert.Len(t, c.EloHistory(), 30)
+}
+
+func TestCoevolutionResults(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	cfg := DefaultCoevolutionConfig()
+	cfg.PopulationSize = 4
+	cfg.Elites = 1
+	c, err := NewCoevolution(internal.NewRockPaperScissors(3), cfg)
+	assert.NoError(t, err)
+	for g := 0; g < 3; g++ {
+		c.Step(rng)
+	}
+
+	winRates := c.WinRates(rng)
+	assert.Len(t, winRates, 3)
+	assert.Len(t, winRates[0], 3)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteEloHistory(&buf, c.EloHistory()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 7)
+	assert.True(t, strings.HasPrefix(lines[1], "0,0,p0-g0,"))
+
+	buf.Reset()
+	assert.NoError(t, WriteWinRates(&buf, winRates))
+	assert.True(t, strings.HasPrefix(buf.String(), "Generation, 0, 1, 2\n0,"))
+
+	_, err = NewCoevolution(internal.NewPredatorPrey(3, 3, rng), cfg)
+	assert.Error(t, err)
+}
+
+func TestGenomeRoundTrip(t *testing.T) {
+	g := NewGenome(2, 3).Mutate(1, mathlib.NewRandom(0))
+	g.ID = "p0-g1"
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGenome(&buf, g))
+	read, err := ReadGenome(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, g, read)
+
+	_, err = ReadGenome(strings.NewReader(`{"theta": [[1, 2], [3]]}`))
+	assert.Error(t, err)
+	_, err = ReadGenome(strings.NewReader(`{"theta": []}`))
+	assert.Error(t, err)
+}
+
+func TestCoevolutionCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coevolution")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := DefaultCoevolutionConfig()
+	cfg.PopulationSize = 4
+	cfg.Elites = 2
+	cfg.Generations = 8
+	cfg.HallOfFameSize = 3
+	run := func(name string, stop int) *Coevolution {
+		rng := mathlib.NewRandom(0)
+		c, err := NewCoevolution(internal.NewRockPaperScissors(3), cfg)
+		assert.NoError(t, err)
+		if stop > 0 {
+			// Stop after some generations and carry on in a new co-evolution with another generator
+			for c.Generation() < stop {
+				c.Step(rng)
+			}
+			path := filepath.Join(dir, name+"_checkpoint.json")
+			assert.NoError(t, c.SaveCheckpoint(path, rng))
+			rng = mathlib.NewRandom(1)
+			c, err = NewCoevolution(internal.NewRockPaperScissors(3), cfg)
+			assert.NoError(t, err)
+			assert.NoError(t, c.LoadCheckpoint(path, rng))
+			assert.Equal(t, stop, c.Generation())
+		}
+		c.Run(rng)
+		assert.NoError(t, c.WriteResults(dir, name, rng))
+		return c
+	}
+	want, got := run("uninterrupted", 0), run("resumed", 5)
+	assert.Equal(t, want.Champions(0), got.Champions(0))
+	assert.Equal(t, want.Champions(1), got.Champions(1))
+	for _, suffix := range []string{"_elo.csv", "_winrate.csv"} {
+		a, err := ioutil.ReadFile(filepath.Join(dir, "uninterrupted"+suffix))
+		assert.NoError(t, err)
+		b, err := ioutil.ReadFile(filepath.Join(dir, "resumed"+suffix))
+		assert.NoError(t, err)
+		assert.Equal(t, string(a), string(b))
+	}
+
+	// A checkpoint only fits a co-evolution with the same population size
+	cfg.PopulationSize = 5
+	c, err := NewCoevolution(internal.NewRockPaperScissors(3), cfg)
+	assert.NoError(t, err)
+	assert.Error(t, c.LoadCheckpoint(filepath.Join(dir, "resumed_checkpoint.json"), mathlib.NewRandom(0)))
+}
@@ -0,0 +1,147 @@
+// Package evolution evolves populations of policies, e.g. by competitive co-evolution.
+package evolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Genome is a tabular softmax policy: a matrix of action preferences for every one-hot state.
+type Genome struct {
+	Theta [][]float64 `json:"theta"`
+	ID    string      `json:"id"` // Name used for ratings, e.g. "p0-g3" for the champion of population 0 in generation 3
+}
+
+// NewGenome returns a genome with all preferences zero, i.e. the uniform random policy.
+func NewGenome(stateDim int, numActions int) *Genome {
+	return &Genome{Theta: mathlib.Matrix(stateDim, numActions, 0)}
+}
+
+// WriteGenome writes g as JSON, which is how policies are saved.
+func WriteGenome(w io.Writer, g *Genome) error {
+	return json.NewEncoder(w).Encode(g)
+}
+
+// ReadGenome reads a genome written by WriteGenome. It returns an error unless the preferences
+// are a non-empty matrix.
+func ReadGenome(r io.Reader) (*Genome, error) {
+	g := Genome{}
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	if len(g.Theta) == 0 || len(g.Theta[0]) == 0 {
+		return nil, fmt.Errorf("genome has no preferences")
+	}
+	for _, row := range g.Theta {
+		if len(row) != len(g.Theta[0]) {
+			return nil, fmt.Errorf("genome preferences are not a matrix")
+		}
+	}
+	return &g, nil
+}
+
+// SaveGenome writes g to the file at path.
+func SaveGenome(path string, g *Genome) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteGenome(file, g)
+}
+
+// LoadGenome reads the genome saved at path.
+func LoadGenome(path string) (*Genome, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	g, err := ReadGenome(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return g, nil
+}
+
+// Clone returns a deep copy of the genome.
+func (g *Genome) Clone() *Genome {
+	theta := make([][]float64, len(g.Theta))
+	for s := range theta {
+		theta[s] = append([]float64(nil), g.Theta[s]...)
+	}
+	return &Genome{Theta: theta, ID: g.ID}
+}
+
+// Mutate returns a copy of the genome with N(0, std^2) noise added to every preference.
+func (g *Genome) Mutate(std float64, rng *mathlib.Random) *Genome {
+	child := g.Clone()
+	child.ID = ""
+	for s := range child.Theta {
+		for a := range child.Theta[s] {
+			child.Theta[s][a] += std * rng.NormFloat64()
+		}
+	}
+	return child
+}
+
+// Agent returns an internal.Agent that acts with the genome's softmax policy and does not learn,
+// so a genome can take a seat in internal.RunMultiAgentEpisode.
+func (g *Genome) Agent() internal.Agent {
+	return &genomeAgent{genome: g, policy: internal.NewSoftmax(1)}
+}
+
+// genomeAgent is a fixed policy. Its update methods do nothing.
+type genomeAgent struct {
+	genome *Genome
+	policy internal.ExplorationPolicy
+}
+
+// UpdateBeforeNextAction makes an update to the agent's policy before selecting the next action.
+func (agt *genomeAgent) UpdateBeforeNextAction() bool {
+	return false
+}
+
+// GetAction samples an action from the softmax of the state's preferences.
+func (agt *genomeAgent) GetAction(s []float64, rng *mathlib.Random) int {
+	state := mathlib.FromOneHot(s)
+	return agt.policy.SelectAction(state, agt.genome.Theta[state], rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *genomeAgent) NewEpisode() {}
+
+// Reset does nothing since the genome is not learned within an episode.
+func (agt *genomeAgent) Reset(rng *mathlib.Random) {}
+
+// Supports returns an error unless observations are one-hot states and actions are discrete.
+func (agt *genomeAgent) Supports(observation internal.Space, action internal.Space) error {
+	stateDim, numActions := len(agt.genome.Theta), len(agt.genome.Theta[0])
+	if observation.Kind != internal.OneHot || observation.N != stateDim {
+		return fmt.Errorf("need %v observations", internal.NewOneHotSpace(stateDim))
+	}
+	if action.Kind != internal.Discrete || action.N != numActions {
+		return fmt.Errorf("need %v actions", internal.NewDiscreteSpace(numActions))
+	}
+	return nil
+}
+
+// UpdateSARS does nothing.
+func (agt *genomeAgent) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+}
+
+// UpdateSARSA does nothing.
+func (agt *genomeAgent) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+}
+
+// LastUpdate does nothing.
+func (agt *genomeAgent) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {}
+
+// TruncatedUpdate does nothing.
+func (agt *genomeAgent) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+}
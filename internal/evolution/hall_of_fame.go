@@ -0,0 +1,41 @@
+package evolution
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// HallOfFame keeps the most recent champions of a population so later generations keep being
+// tested against them (Rosin and Belew, 1997). Without it, co-evolving populations can forget how to
+// beat strategies that are no longer in the opposing population and cycle.
+type HallOfFame struct {
+	capacity int
+	members  []*Genome
+}
+
+// NewHallOfFame returns an empty hall of fame that keeps at most capacity champions.
+func NewHallOfFame(capacity int) *HallOfFame {
+	return &HallOfFame{capacity: capacity}
+}
+
+// Add inducts a champion, dropping the oldest member if the hall of fame is full.
+func (h *HallOfFame) Add(g *Genome) {
+	h.members = append(h.members, g)
+	if len(h.members) > h.capacity {
+		h.members = h.members[1:]
+	}
+}
+
+// Members returns every member, oldest first.
+func (h *HallOfFame) Members() []*Genome {
+	return h.members
+}
+
+// Sample returns k members drawn uniformly with replacement, or none if the hall of fame is empty.
+func (h *HallOfFame) Sample(k int, rng *mathlib.Random) []*Genome {
+	if len(h.members) == 0 {
+		return nil
+	}
+	sample := make([]*Genome, k)
+	for i := range sample {
+		sample[i] = h.members[int(rng.Float64()*float64(len(h.members)))]
+	}
+	return sample
+}
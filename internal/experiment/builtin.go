@@ -0,0 +1,421 @@
+package experiment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/bridge"
+	"github.com/jackkenney/evolve-rl/internal/remote"
+	"github.com/jackkenney/evolve-rl/internal/wrappers"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// noParams returns an error if p sets any parameter, for constructors that take none.
+func noParams(p Params) error {
+	return newParamReader(p).done()
+}
+
+// simpleEnvironment registers an environment that takes no hyperparameters.
+func simpleEnvironment(name string, newEnv func(rng *mathlib.Random) internal.Environment) {
+	RegisterEnvironment(name, func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		if err := noParams(p); err != nil {
+			return nil, err
+		}
+		return newEnv(rng), nil
+	})
+}
+
+// readPolicy reads the exploration policy of a tabular agent: "policy" is one of softmax
+// (temperature), epsilon_greedy (epsilon), ucb1 (c) or count_bonus (beta, temperature).
+func readPolicy(r *paramReader, numStates int, numActions int) internal.ExplorationPolicy {
+	switch policy := r.string("policy", "softmax"); policy {
+	case "softmax":
+		return internal.NewSoftmax(r.float("temperature", 1))
+	case "epsilon_greedy":
+		return internal.NewEpsilonGreedy(internal.ConstantSchedule(r.float("epsilon", 0.1)))
+	case "ucb1":
+		return internal.NewUCB1(numStates, numActions, r.float("c", 2))
+	case "count_bonus":
+		return internal.NewCountBonus(numStates, numActions, r.float("beta", 1), r.float("temperature", 1))
+	default:
+		r.keep(fmt.Errorf("unknown policy %q", policy))
+		return nil
+	}
+}
+
+// parseSlipSettings parses the settings of a slip_switch: semicolon-separated triples of the
+// probabilities of staying, veering right and veering left, e.g. "0.1,0.05,0.05; 0.4,0.1,0.1".
+func parseSlipSettings(text string) ([]wrappers.SlipSettings, error) {
+	var settings []wrappers.SlipSettings
+	for _, triple := range strings.Split(text, ";") {
+		fields := strings.Split(triple, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("slip settings %q need stay,veer_right,veer_left", strings.TrimSpace(triple))
+		}
+		var probs [3]float64
+		for i, field := range fields {
+			var err error
+			if probs[i], err = strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+				return nil, fmt.Errorf("slip settings %q: %v", strings.TrimSpace(triple), err)
+			}
+		}
+		if probs[0] < 0 || probs[1] < 0 || probs[2] < 0 || probs[0]+probs[1]+probs[2] > 1 {
+			return nil, fmt.Errorf("slip settings %q are not probabilities that sum to at most one", strings.TrimSpace(triple))
+		}
+		settings = append(settings, wrappers.SlipSettings{Stay: probs[0], VeerRight: probs[1], VeerLeft: probs[2]})
+	}
+	return settings, nil
+}
+
+func init() {
+	// Environments
+	simpleEnvironment("gridworld", internal.NewGridworld)
+	simpleEnvironment("cliff_walking", internal.NewCliffWalking)
+	simpleEnvironment("taxi", internal.NewTaxi)
+	simpleEnvironment("blackjack", internal.NewBlackjack)
+	simpleEnvironment("mountain_car", internal.NewMountainCar)
+	simpleEnvironment("cart_pole", internal.NewCartPole)
+	simpleEnvironment("acrobot", internal.NewAcrobot)
+	RegisterEnvironment("map_gridworld", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		path := r.string("path", "")
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("need the path of a map file")
+		}
+		return internal.LoadMapGridworld(path, rng)
+	})
+	RegisterEnvironment("wall_sensing_gridworld", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		path := r.string("path", "")
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("need the path of a map file")
+		}
+		cfg, err := internal.LoadMapConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		return internal.NewWallSensingGridworld(cfg, rng)
+	})
+	RegisterEnvironment("windy_gridworld", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		stochastic := r.bool("stochastic", false)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewWindyGridworld(stochastic, rng), nil
+	})
+	RegisterEnvironment("frozen_lake", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		size := r.int("size", 4)
+		slippery := r.bool("slippery", true)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		switch size {
+		case 4:
+			return internal.NewFrozenLake(internal.FrozenLake4x4, slippery, rng), nil
+		case 8:
+			return internal.NewFrozenLake(internal.FrozenLake8x8, slippery, rng), nil
+		}
+		return nil, fmt.Errorf("size must be 4 or 8, not %d", size)
+	})
+	RegisterEnvironment("pig", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		target := r.int("target", 100)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewPig(target, rng), nil
+	})
+	RegisterNonterminatingEnvironment("pendulum", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		numActions := r.int("actions", 3)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewPendulum(numActions, rng), nil
+	})
+	RegisterNonterminatingEnvironment("nchain", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		n := r.int("n", 5)
+		slip := r.float("slip", 0.2)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewNChain(n, slip, rng), nil
+	})
+	RegisterNonterminatingEnvironment("riverswim", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		n := r.int("n", 6)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewRiverSwim(n, rng), nil
+	})
+	RegisterEnvironment("deepsea", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		n := r.int("n", 10)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewDeepSea(n, rng), nil
+	})
+	RegisterEnvironment("combination_lock", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		length := r.int("length", 10)
+		numActions := r.int("actions", 2)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewCombinationLock(length, numActions, rng), nil
+	})
+	RegisterEnvironment("gaussian_bandit", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("arms", 10)
+		pulls := r.int("pulls", 1000)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewGaussianBandit(k, pulls, rng), nil
+	})
+	RegisterEnvironment("bernoulli_bandit", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("arms", 10)
+		pulls := r.int("pulls", 1000)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewBernoulliBandit(k, pulls, rng), nil
+	})
+	RegisterEnvironment("random_walk_bandit", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("arms", 10)
+		pulls := r.int("pulls", 1000)
+		walkStd := r.float("walk_std", 0.01)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewRandomWalkBandit(k, pulls, walkStd), nil
+	})
+	RegisterEnvironment("linear_contextual_bandit", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("arms", 10)
+		contextDim := r.int("context_dim", 5)
+		noiseStd := r.float("noise_std", 0.1)
+		pulls := r.int("pulls", 1000)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewLinearContextualBandit(k, contextDim, noiseStd, pulls, rng), nil
+	})
+
+	// External environments, which speak the bridge protocol
+	RegisterEnvironment("subprocess", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		command := r.string("command", "")
+		args := r.string("args", "") // Separated by spaces
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		if command == "" {
+			return nil, fmt.Errorf("need the command of the environment server")
+		}
+		return bridge.NewSubprocessEnvironment(command, strings.Fields(args)...)
+	})
+	RegisterEnvironment("remote", func(p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		url := r.string("url", "")
+		timeout := r.float("timeout", 10) // Seconds per request, or 0 for none
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		if url == "" {
+			return nil, fmt.Errorf("need the url of the environment server")
+		}
+		if timeout < 0 {
+			return nil, fmt.Errorf("timeout %v is negative", timeout)
+		}
+		return remote.NewClient(url, time.Duration(timeout*float64(time.Second))).Dial()
+	})
+
+	// Wrappers
+	RegisterWrapper("time_limit", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		steps := r.int("steps", 100)
+		penalty := r.float("penalty", 0)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewPenalizedTimeLimit(env, steps, penalty), nil
+	})
+	RegisterWrapper("reward_scale", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		scale := r.float("scale", 1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewRewardScale(env, scale), nil
+	})
+	RegisterWrapper("reward_clip", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		low := r.float("low", -1)
+		high := r.float("high", 1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewRewardClip(env, low, high), nil
+	})
+	RegisterWrapper("action_repeat", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("k", 4)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewActionRepeat(env, k), nil
+	})
+	RegisterWrapper("sticky_actions", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		prob := r.float("p", 0.25)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewStickyActions(env, prob), nil
+	})
+	RegisterWrapper("normalize_observation", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		clip := r.float("clip", 5)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewNormalizeObservation(env, clip), nil
+	})
+	RegisterWrapper("moving_goal", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		every := r.int("every", 100)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewMovingGoal(env, every), nil
+	})
+	RegisterWrapper("slip_switch", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		every := r.int("every", 100)
+		settings, err := parseSlipSettings(r.string("settings", "0.1,0.05,0.05; 0.4,0.1,0.1"))
+		r.keep(err)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewSlipSwitch(env, every, settings), nil
+	})
+	RegisterWrapper("reward_drift", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		every := r.int("every", 100)
+		std := r.float("std", 1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewRewardDrift(env, every, std), nil
+	})
+	RegisterWrapper("gaussian_observation_noise", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		std := r.float("std", 0.1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewGaussianObservationNoise(env, std, rng), nil
+	})
+	RegisterWrapper("one_hot_observation_noise", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		prob := r.float("p", 0.1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewOneHotObservationNoise(env, prob, rng), nil
+	})
+	RegisterWrapper("frame_stack", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("k", 4)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewFrameStack(env, k), nil
+	})
+	RegisterWrapper("one_hot_history", func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error) {
+		r := newParamReader(p)
+		k := r.int("k", 2)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return wrappers.NewOneHotHistory(env, k), nil
+	})
+
+	// Agents. Tabular agents use the environment's discount.
+	RegisterAgent("sarsa", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		alpha := r.float("alpha", 0.001)
+		optimistic := r.float("optimistic", 0)
+		policy := readPolicy(r, env.GetStateDim(), env.GetNumActions())
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewSarsa(env.GetStateDim(), env.GetNumActions(), env.GetGamma(), alpha, optimistic, policy), nil
+	})
+	RegisterAgent("reinforce", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		alpha := r.float("alpha", 0.001)
+		temperature := r.float("temperature", 1) // The policy is always a softmax
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewREINFORCE(env.GetStateDim(), env.GetNumActions(), env.GetGamma(), alpha, temperature), nil
+	})
+	RegisterAgent("bbo", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		n := r.int("n", 10)
+		policy := readPolicy(r, env.GetStateDim(), env.GetNumActions())
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewTabularBBO(env.GetStateDim(), env.GetNumActions(), env.GetGamma(), n, policy), nil
+	})
+	RegisterAgent("bandit", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		policy := readPolicy(r, 1, env.GetNumActions())
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewBanditAgent(env.GetNumActions(), policy), nil
+	})
+	RegisterAgent("thompson_beta", func(p Params, env internal.Environment) (internal.Agent, error) {
+		if err := noParams(p); err != nil {
+			return nil, err
+		}
+		return internal.NewThompsonBeta(env.GetNumActions()), nil
+	})
+	RegisterAgent("thompson_gaussian", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		noiseVariance := r.float("noise_variance", 1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewThompsonGaussian(env.GetNumActions(), noiseVariance), nil
+	})
+	RegisterAgent("linucb", func(p Params, env internal.Environment) (internal.Agent, error) {
+		r := newParamReader(p)
+		alpha := r.float("alpha", 1)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		contextDim := internal.ObservationSpaceOf(env).Dim()
+		return internal.NewLinUCB(contextDim, env.GetNumActions(), alpha), nil
+	})
+}
@@ -0,0 +1,264 @@
+package experiment
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/remote"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSpec = `
+name: test
+seed: 3
+trials: 2
+episodes: 5
+environments:
+  - name: gridworld
+    wrappers:
+      - name: time_limit
+        params: {steps: 20}
+  - name: nchain
+    label: chain
+    params: {n: 4}
+    wrappers: [{name: time_limit, params: {steps: 20}}]
+agents:
+  - name: sarsa
+    params: {alpha: 0.1, policy: epsilon_greedy, epsilon: 0.2}
+  - name: bbo
+    params: {n: 2}
+`
+
+func TestParams(t *testing.T) {
+	p := Params{"a": 2, "b": 0.5, "c": true, "d": "x"}
+	f, err := p.Float("a", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, f)
+	_, err = p.Int("b", 0)
+	assert.Error(t, err)
+	n, err := p.Int("missing", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+	_, err = p.Bool("d", false)
+	assert.Error(t, err)
+
+	r := newParamReader(p)
+	r.float("a", 0)
+	r.bool("c", false)
+	r.string("d", "")
+	assert.EqualError(t, r.done(), "unknown parameters b")
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), spec.Seed)
+	assert.Equal(t, "chain", spec.Environments[1].LabelOrName())
+	assert.Equal(t, "chain_sarsa", spec.fileName(spec.Environments[1], spec.Agents[0]))
+
+	// Environments that never terminate are run with a time limit
+	_, err = ParseSpec(strings.NewReader("environments: [{name: riverswim, wrappers: [{name: time_limit}]}]\nagents: [{name: sarsa}]"))
+	assert.NoError(t, err)
+
+	// JSON is YAML too
+	_, err = ParseSpec(strings.NewReader(`{"environments": [{"name": "taxi"}], "agents": [{"name": "reinforce"}]}`))
+	assert.NoError(t, err)
+
+	for _, bad := range []string{
+		"environments: [{name: taxi}]\nagents: [{name: nope}]",
+		"environments: [{name: nope}]\nagents: [{name: sarsa}]",
+		"environments: [{name: taxi, wrappers: [{name: nope}]}]\nagents: [{name: sarsa}]",
+		"environments: [{name: taxi}, {name: taxi}]\nagents: [{name: sarsa}]",
+		"environments: [{name: taxi}]\nagents: []",
+		"environments: [{name: taxi}]\nagents: [{name: sarsa}]\ntypo: 1",
+		"environments: [{name: pendulum}]\nagents: [{name: sarsa}]", // Never ends without a time_limit
+	} {
+		_, err := ParseSpec(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env, err := NewEnvironment(EnvSpec{Name: "frozen_lake", Params: Params{"size": 8},
+		Wrappers: []WrapperSpec{{Name: "one_hot_history", Params: Params{"k": 2}}}}, rng)
+	assert.NoError(t, err)
+	assert.Equal(t, 64*64, env.GetStateDim())
+
+	agt, err := NewAgent(AgentSpec{Name: "linucb"}, internal.NewLinearContextualBandit(3, 4, 0.1, 10, rng))
+	assert.NoError(t, err)
+	assert.NotNil(t, agt)
+
+	// Bad hyperparameters and panicking constructors are errors
+	_, err = NewEnvironment(EnvSpec{Name: "frozen_lake", Params: Params{"size": 5}}, rng)
+	assert.Error(t, err)
+	_, err = NewEnvironment(EnvSpec{Name: "cart_pole", Wrappers: []WrapperSpec{{Name: "one_hot_history"}}}, rng)
+	assert.Error(t, err)
+	_, err = NewAgent(AgentSpec{Name: "sarsa", Params: Params{"policy": "greedy"}}, env)
+	assert.Error(t, err)
+	_, err = NewAgent(AgentSpec{Name: "sarsa", Params: Params{"temperature": 0}}, env)
+	assert.Error(t, err)
+	_, err = NewAgent(AgentSpec{Name: "reinforce", Params: Params{"policy": "epsilon_greedy"}}, env)
+	assert.Error(t, err)
+	_, err = NewEnvironment(EnvSpec{Name: "taxi", Wrappers: []WrapperSpec{{Name: "slip_switch"}}}, rng)
+	assert.Error(t, err)
+
+	// slip_switch switches between slip settings of a map gridworld
+	path := filepath.Join("..", "..", "maps", "obstructed.txt")
+	_, err = NewEnvironment(EnvSpec{Name: "map_gridworld", Params: Params{"path": path},
+		Wrappers: []WrapperSpec{{Name: "slip_switch", Params: Params{"every": 10, "settings": "0.1,0.05,0.05; 0.5,0,0"}}}}, rng)
+	assert.NoError(t, err)
+	for _, bad := range []string{"0.1,0.05", "0.5,0.5,0.5", "a,0,0"} {
+		_, err = NewEnvironment(EnvSpec{Name: "map_gridworld", Params: Params{"path": path},
+			Wrappers: []WrapperSpec{{Name: "slip_switch", Params: Params{"settings": bad}}}}, rng)
+		assert.Error(t, err, bad)
+	}
+
+	// Every built-in environment builds with its defaults
+	for _, name := range EnvironmentNames() {
+		if name == "map_gridworld" || name == "wall_sensing_gridworld" || name == "subprocess" || name == "remote" {
+			continue
+		}
+		_, err := NewEnvironment(EnvSpec{Name: name}, rng)
+		assert.NoError(t, err, name)
+	}
+}
+
+// closingGridworld is a gridworld that counts how many times it is closed.
+type closingGridworld struct {
+	internal.Environment
+	closed *int32
+}
+
+func (env closingGridworld) Close() error {
+	atomic.AddInt32(env.closed, 1)
+	return nil
+}
+
+func TestExternalEnvironments(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	var opened, closed int32
+	srv := httptest.NewServer(remote.NewServer(func() internal.Environment {
+		atomic.AddInt32(&opened, 1)
+		return closingGridworld{Environment: internal.NewGridworld(mathlib.NewRandom(0)), closed: &closed}
+	}))
+	defer srv.Close()
+
+	e := EnvSpec{Name: "remote", Params: Params{"url": srv.URL, "timeout": 1}}
+	env, err := NewEnvironment(e, rng)
+	assert.NoError(t, err)
+	assert.Equal(t, 23, env.GetStateDim())
+	closeEnvironment(env)
+
+	// Checking the hyperparameters leaves no environment open, nor does building an agent
+	newAgt, newEnv, err := constructors(e, AgentSpec{Name: "sarsa"}, rng)
+	assert.NoError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&opened), atomic.LoadInt32(&closed))
+	assert.NotNil(t, newAgt())
+	assert.Equal(t, atomic.LoadInt32(&opened), atomic.LoadInt32(&closed))
+	closeEnvironment(newEnv())
+	assert.Equal(t, int32(4), atomic.LoadInt32(&closed))
+
+	for _, bad := range []EnvSpec{
+		{Name: "remote"},
+		{Name: "remote", Params: Params{"url": srv.URL, "timeout": -1}},
+		{Name: "remote", Params: Params{"url": "http://127.0.0.1:1"}},
+		{Name: "subprocess"},
+		{Name: "subprocess", Params: Params{"command": "no-such-environment-server"}},
+	} {
+		_, err = NewEnvironment(bad, rng)
+		assert.Error(t, err, "%v", bad)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "experiment")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spec, err := ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+	spec.OutputDir = dir
+	var log bytes.Buffer
+	spec.Log = &log
+	assert.NoError(t, Run(spec))
+	assert.Len(t, spec.OutputFiles(), 4)
+	assert.Equal(t, 4, strings.Count(log.String(), "Starting trial 1 of 2\n"))
+	for _, path := range append(spec.OutputFiles(), filepath.Join(dir, "test_spec.yaml")) {
+		assert.FileExists(t, path)
+	}
+	assert.Equal(t, filepath.Join(dir, "chain_bbo_out.csv"), spec.OutputFiles()[3])
+
+	// The saved spec reproduces the experiment, however many trials run at once
+	first, err := ioutil.ReadFile(filepath.Join(dir, "chain_bbo_out.csv"))
+	assert.NoError(t, err)
+	saved, err := LoadSpec(filepath.Join(dir, "test_spec.yaml"))
+	assert.NoError(t, err)
+	saved.Parallelism = 1
+	assert.NoError(t, Run(saved))
+	second, err := ioutil.ReadFile(filepath.Join(dir, "chain_bbo_out.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	// A checkpoint is only resumed by a run with the same seed and hyperparameters
+	saved.Checkpoint = 5
+	assert.NoError(t, Run(saved))
+	assert.NoError(t, Run(saved))
+	saved.Seed++
+	assert.Error(t, Run(saved))
+	saved.Seed--
+	saved.Agents[1].Params["n"] = 3
+	assert.Error(t, Run(saved))
+
+	// Hyperparameter errors are found before anything runs
+	spec.Agents[0].Params["alpha"] = "fast"
+	assert.Error(t, Run(spec))
+}
+
+func TestGrid(t *testing.T) {
+	spec, err := ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+
+	alpha, err := ParseGridAxis("sarsa.alpha=0.1,0.5")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{0.1, 0.5}, alpha.Values)
+	policy, err := ParseGridAxis("sarsa.policy=softmax,ucb1")
+	assert.NoError(t, err)
+	grid, err := spec.Grid([]GridAxis{alpha, policy})
+	assert.NoError(t, err)
+	assert.Len(t, grid.Agents, 5)
+	assert.Equal(t, "sarsa_alpha=0.1_policy=softmax", grid.Agents[0].LabelOrName())
+	assert.Equal(t, "ucb1", grid.Agents[3].Params["policy"])
+	assert.Equal(t, "bbo", grid.Agents[4].LabelOrName())
+	// The original spec is unchanged
+	assert.Equal(t, 0.1, spec.Agents[0].Params["alpha"])
+	assert.Len(t, spec.Agents, 2)
+
+	_, err = ParseGridAxis("alpha=0.1")
+	assert.Error(t, err)
+	bad, err := ParseGridAxis("q.alpha=0.1")
+	assert.NoError(t, err)
+	_, err = spec.Grid([]GridAxis{bad})
+	assert.Error(t, err)
+}
+
+func TestGames(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	for _, name := range GameNames() {
+		_, err := NewGame(name, nil, rng)
+		assert.NoError(t, err, name)
+	}
+	game, err := NewGame("stag_hunt", Params{"rounds": 3}, rng)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, game.GetNumAgents())
+	_, err = NewGame("predator_prey", Params{"predators": 9, "size": 3}, rng)
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package experiment
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// GameConstructor builds a multi-agent environment from its hyperparameters.
+type GameConstructor func(p Params, rng *mathlib.Random) (internal.MultiAgentEnvironment, error)
+
+// gameRegistry holds the multi-agent environments, like the registries of registry.go.
+var gameRegistry = map[string]GameConstructor{}
+
+// RegisterGame makes the multi-agent environment available under name. It panics if the name is taken.
+func RegisterGame(name string, c GameConstructor) {
+	if _, ok := gameRegistry[name]; ok {
+		panic("experiment: game " + name + " registered twice")
+	}
+	gameRegistry[name] = c
+}
+
+// GameNames returns the names of the registered multi-agent environments in sorted order.
+func GameNames() []string {
+	names := make([]string, 0, len(gameRegistry))
+	for name := range gameRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewGame builds the named multi-agent environment.
+func NewGame(name string, p Params, rng *mathlib.Random) (internal.MultiAgentEnvironment, error) {
+	c, ok := gameRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown game %q", name)
+	}
+	var env internal.MultiAgentEnvironment
+	err := construct("game "+name, func() (err error) {
+		env, err = c(p, rng)
+		return err
+	})
+	return env, err
+}
+
+// matrixGame registers an iterated matrix game whose only hyperparameter is the number of rounds.
+func matrixGame(name string, newGame func(rounds int) internal.MultiAgentEnvironment) {
+	RegisterGame(name, func(p Params, rng *mathlib.Random) (internal.MultiAgentEnvironment, error) {
+		r := newParamReader(p)
+		rounds := r.int("rounds", 10)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return newGame(rounds), nil
+	})
+}
+
+func init() {
+	matrixGame("prisoners_dilemma", internal.NewPrisonersDilemma)
+	matrixGame("stag_hunt", internal.NewStagHunt)
+	matrixGame("rock_paper_scissors", internal.NewRockPaperScissors)
+	RegisterGame("two_agent_gridworld", func(p Params, rng *mathlib.Random) (internal.MultiAgentEnvironment, error) {
+		if err := noParams(p); err != nil {
+			return nil, err
+		}
+		return internal.NewTwoAgentGridworld(rng), nil
+	})
+	RegisterGame("predator_prey", func(p Params, rng *mathlib.Random) (internal.MultiAgentEnvironment, error) {
+		r := newParamReader(p)
+		size := r.int("size", 5)
+		predators := r.int("predators", 2)
+		if err := r.done(); err != nil {
+			return nil, err
+		}
+		return internal.NewPredatorPrey(size, predators, rng), nil
+	})
+}
@@ -0,0 +1,76 @@
+package experiment
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GridAxis is a hyperparameter of one of a spec's agents and the values a grid search tries.
+type GridAxis struct {
+	Agent  string // Label of the agent
+	Param  string
+	Values []interface{}
+}
+
+// ParseGridAxis parses "<agent>.<param>=<value>,<value>,...". Each value is read as YAML, so
+// numbers, booleans and strings can all be searched over.
+func ParseGridAxis(s string) (GridAxis, error) {
+	eq := strings.Index(s, "=")
+	dot := strings.Index(s, ".")
+	if eq < 0 || dot < 0 || dot > eq {
+		return GridAxis{}, fmt.Errorf("grid axis %q is not <agent>.<param>=<value>,<value>,...", s)
+	}
+	axis := GridAxis{Agent: s[:dot], Param: s[dot+1 : eq]}
+	for _, v := range strings.Split(s[eq+1:], ",") {
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(v), &value); err != nil || value == nil {
+			return GridAxis{}, fmt.Errorf("grid axis %q has a bad value %q", s, v)
+		}
+		axis.Values = append(axis.Values, value)
+	}
+	return axis, nil
+}
+
+// Grid returns a copy of the spec in which every agent that an axis names is replaced by one
+// agent per combination of the values of its axes, labelled <label>_<param>=<value>_... in the
+// order of the axes. Other agents are kept as they are.
+func (spec *Spec) Grid(axes []GridAxis) (*Spec, error) {
+	byAgent := map[string][]GridAxis{}
+	for _, axis := range axes {
+		byAgent[axis.Agent] = append(byAgent[axis.Agent], axis)
+	}
+	for label := range byAgent {
+		found := false
+		for _, a := range spec.Agents {
+			found = found || a.LabelOrName() == label
+		}
+		if !found {
+			return nil, fmt.Errorf("grid names agent %q, which is not in the spec", label)
+		}
+	}
+
+	grid := *spec
+	grid.Agents = nil
+	for _, a := range spec.Agents {
+		points := []AgentSpec{{Name: a.Name, Label: a.LabelOrName(), Params: a.Params}}
+		for _, axis := range byAgent[a.LabelOrName()] {
+			var next []AgentSpec
+			for _, p := range points {
+				for _, v := range axis.Values {
+					params := Params{}
+					for k, x := range p.Params {
+						params[k] = x
+					}
+					params[axis.Param] = v
+					label := fmt.Sprintf("%s_%s=%v", p.Label, axis.Param, v)
+					next = append(next, AgentSpec{Name: a.Name, Label: label, Params: params})
+				}
+			}
+			points = next
+		}
+		grid.Agents = append(grid.Agents, points...)
+	}
+	return &grid, grid.Validate()
+}
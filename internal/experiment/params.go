@@ -0,0 +1,134 @@
+package experiment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Params are the hyperparameters of a registered environment, wrapper or agent, as written in a spec file.
+type Params map[string]interface{}
+
+// Float returns the number name, or def if it is not set.
+func (p Params) Float(name string, def float64) (float64, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	}
+	return 0, fmt.Errorf("parameter %s is %v, not a number", name, v)
+}
+
+// Int returns the whole number name, or def if it is not set.
+func (p Params) Int(name string, def int) (int, error) {
+	f, err := p.Float(name, float64(def))
+	if err != nil {
+		return 0, err
+	}
+	if f != float64(int(f)) {
+		return 0, fmt.Errorf("parameter %s is %v, not a whole number", name, f)
+	}
+	return int(f), nil
+}
+
+// Bool returns the boolean name, or def if it is not set.
+func (p Params) Bool(name string, def bool) (bool, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("parameter %s is %v, not true or false", name, v)
+}
+
+// String returns the string name, or def if it is not set.
+func (p Params) String(name string, def string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("parameter %s is %v, not a string", name, v)
+}
+
+// paramReader reads several parameters and keeps the first error, so constructors can read all
+// their parameters and check for an error once.
+type paramReader struct {
+	p    Params
+	used map[string]bool
+	err  error
+}
+
+// newParamReader returns a reader of p.
+func newParamReader(p Params) *paramReader {
+	return &paramReader{p: p, used: map[string]bool{}}
+}
+
+// keep records the first error.
+func (r *paramReader) keep(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// float reads a number parameter, or def if it is not set.
+func (r *paramReader) float(name string, def float64) float64 {
+	r.used[name] = true
+	v, err := r.p.Float(name, def)
+	r.keep(err)
+	return v
+}
+
+// int reads a whole number parameter, or def if it is not set.
+func (r *paramReader) int(name string, def int) int {
+	r.used[name] = true
+	v, err := r.p.Int(name, def)
+	r.keep(err)
+	return v
+}
+
+// bool reads a boolean parameter, or def if it is not set.
+func (r *paramReader) bool(name string, def bool) bool {
+	r.used[name] = true
+	v, err := r.p.Bool(name, def)
+	r.keep(err)
+	return v
+}
+
+// string reads a string parameter, or def if it is not set.
+func (r *paramReader) string(name string, def string) string {
+	r.used[name] = true
+	v, err := r.p.String(name, def)
+	r.keep(err)
+	return v
+}
+
+// done returns the first error, or an error naming the parameters that were never read, which are
+// most likely typos.
+func (r *paramReader) done() error {
+	if r.err != nil {
+		return r.err
+	}
+	var unknown []string
+	for name := range r.p {
+		if !r.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown parameters %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
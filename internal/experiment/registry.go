@@ -0,0 +1,157 @@
+package experiment
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// EnvironmentConstructor builds an environment from its hyperparameters.
+type EnvironmentConstructor func(p Params, rng *mathlib.Random) (internal.Environment, error)
+
+// WrapperConstructor wraps env according to its hyperparameters.
+type WrapperConstructor func(env internal.Environment, p Params, rng *mathlib.Random) (internal.Environment, error)
+
+// AgentConstructor builds an agent from its hyperparameters for the (wrapped) environment env,
+// which it may query for its dimensions and discount.
+type AgentConstructor func(p Params, env internal.Environment) (internal.Agent, error)
+
+// The registries. They are filled in init and should only be changed from init functions, since
+// they are not safe for concurrent use.
+var (
+	envRegistry     = map[string]EnvironmentConstructor{}
+	wrapperRegistry = map[string]WrapperConstructor{}
+	agentRegistry   = map[string]AgentConstructor{}
+
+	// Environments whose episodes never end on their own
+	nonterminating = map[string]bool{}
+)
+
+// RegisterEnvironment makes the environment available to specs under name. It panics if the name is taken.
+func RegisterEnvironment(name string, c EnvironmentConstructor) {
+	if _, ok := envRegistry[name]; ok {
+		panic("experiment: environment " + name + " registered twice")
+	}
+	envRegistry[name] = c
+}
+
+// RegisterNonterminatingEnvironment is RegisterEnvironment for an environment whose episodes never
+// end on their own, like pendulum. Validate rejects specs that don't wrap it in a time_limit.
+func RegisterNonterminatingEnvironment(name string, c EnvironmentConstructor) {
+	RegisterEnvironment(name, c)
+	nonterminating[name] = true
+}
+
+// NeverTerminates returns whether the episodes of the environment registered under name never end on
+// their own, so it must be run with a time limit.
+func NeverTerminates(name string) bool {
+	return nonterminating[name]
+}
+
+// RegisterWrapper makes the wrapper available to specs under name. It panics if the name is taken.
+func RegisterWrapper(name string, c WrapperConstructor) {
+	if _, ok := wrapperRegistry[name]; ok {
+		panic("experiment: wrapper " + name + " registered twice")
+	}
+	wrapperRegistry[name] = c
+}
+
+// RegisterAgent makes the agent available to specs under name. It panics if the name is taken.
+func RegisterAgent(name string, c AgentConstructor) {
+	if _, ok := agentRegistry[name]; ok {
+		panic("experiment: agent " + name + " registered twice")
+	}
+	agentRegistry[name] = c
+}
+
+// EnvironmentNames returns the names of the registered environments in sorted order.
+func EnvironmentNames() []string {
+	names := make([]string, 0, len(envRegistry))
+	for name := range envRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WrapperNames returns the names of the registered wrappers in sorted order.
+func WrapperNames() []string {
+	names := make([]string, 0, len(wrapperRegistry))
+	for name := range wrapperRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AgentNames returns the names of the registered agents in sorted order.
+func AgentNames() []string {
+	names := make([]string, 0, len(agentRegistry))
+	for name := range agentRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// construct calls build and turns a panic into an error. Constructors in this repository panic
+// on invalid arguments (e.g., a wrapper around an environment it can't wrap), which in a spec
+// file is a user error rather than a bug.
+func construct(what string, build func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", what, r)
+		}
+	}()
+	if err := build(); err != nil {
+		return fmt.Errorf("%s: %v", what, err)
+	}
+	return nil
+}
+
+// NewEnvironment builds the environment described by e and wraps it with its wrappers.
+func NewEnvironment(e EnvSpec, rng *mathlib.Random) (internal.Environment, error) {
+	c, ok := envRegistry[e.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown environment %q", e.Name)
+	}
+	var env internal.Environment
+	err := construct("environment "+e.Name, func() (err error) {
+		env, err = c(e.Params, rng)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range e.Wrappers {
+		c, ok := wrapperRegistry[w.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown wrapper %q", w.Name)
+		}
+		err := construct("wrapper "+w.Name, func() (err error) {
+			env, err = c(env, w.Params, rng)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// NewAgent builds the agent described by a for env.
+func NewAgent(a AgentSpec, env internal.Environment) (internal.Agent, error) {
+	c, ok := agentRegistry[a.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent %q", a.Name)
+	}
+	var agt internal.Agent
+	err := construct("agent "+a.Name, func() (err error) {
+		agt, err = c(a.Params, env)
+		return err
+	})
+	return agt, err
+}
@@ -0,0 +1,196 @@
+package experiment
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"gopkg.in/yaml.v3"
+)
+
+// outputDir returns the directory of the results.
+func (spec *Spec) outputDir() string {
+	if spec.OutputDir == "" {
+		return "data"
+	}
+	return spec.OutputDir
+}
+
+// fileName returns the name of the results of agent a on environment e.
+func (spec *Spec) fileName(e EnvSpec, a AgentSpec) string {
+	if len(spec.Environments) == 1 {
+		return a.LabelOrName()
+	}
+	return e.LabelOrName() + "_" + a.LabelOrName()
+}
+
+// OutputFiles returns the paths of the returns files Run writes, in the order it writes them.
+func (spec *Spec) OutputFiles() []string {
+	var paths []string
+	for _, e := range spec.Environments {
+		for _, a := range spec.Agents {
+			paths = append(paths, filepath.Join(spec.outputDir(), spec.fileName(e, a)+"_out.csv"))
+		}
+	}
+	return paths
+}
+
+// Run runs every agent of the spec on every environment and writes the returns, as RunTrials
+// does, to the files named in the package documentation. Each environment and agent pair gets its
+// own random number generator seeded with the spec's seed, so a pair's results don't depend on
+// which other pairs are in the spec. Run also writes the spec itself to <output_dir>/<name>_spec.yaml
+// next to the results, so they can be reproduced. It stops at the first pair that fails.
+func Run(spec *Spec) error {
+	return RunContext(context.Background(), spec)
+}
+
+// RunContext is Run that stops when ctx is cancelled, after writing the returns of the trials of the
+// current pair that finished, as internal.RunTrialsContext does.
+func RunContext(ctx context.Context, spec *Spec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(spec.outputDir(), 0755); err != nil {
+		return err
+	}
+	if spec.Name != "" {
+		if err := spec.write(filepath.Join(spec.outputDir(), spec.Name+"_spec.yaml")); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range spec.Environments {
+		for _, a := range spec.Agents {
+			if err := spec.runPair(ctx, e, a); err != nil {
+				return fmt.Errorf("%s on %s: %v", a.LabelOrName(), e.LabelOrName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns runs the trials of agent a on environment e with the spec's seed, trials, episodes and
+// parallelism, and returns the returns of every trial instead of writing them, as
+// internal.RunTrialsReturns does. If the spec checkpoints, the progress is saved to
+// <output_dir>/<name>_checkpoint.json. Neither a nor e has to be in the spec.
+func (spec *Spec) Returns(ctx context.Context, e EnvSpec, a AgentSpec, name string) ([][]float64, error) {
+	if spec.Checkpoint > 0 {
+		if err := os.MkdirAll(spec.outputDir(), 0755); err != nil {
+			return nil, err
+		}
+	}
+	rng := mathlib.NewRandom(spec.Seed)
+	agtConstructor, envConstructor, err := constructors(e, a, rng)
+	if err != nil {
+		return nil, err
+	}
+	return internal.RunTrialsReturns(ctx, rng, agtConstructor, envConstructor, spec.trialConfig(name, e, a))
+}
+
+// NewTrials returns the trials of agent a on environment e, which Returns would run, to be run a few
+// episodes at a time with internal.RunTrialsTo. Checkpoints are not used. Close the trials once
+// they are no longer run.
+func (spec *Spec) NewTrials(e EnvSpec, a AgentSpec) ([]*internal.Trial, error) {
+	rng := mathlib.NewRandom(spec.Seed)
+	agtConstructor, envConstructor, err := constructors(e, a, rng)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewTrials(rng, agtConstructor, envConstructor, spec.trialConfig("", e, a).NumTrials)
+}
+
+// runPair runs the trials of agent a on environment e and writes their returns.
+func (spec *Spec) runPair(ctx context.Context, e EnvSpec, a AgentSpec) error {
+	rng := mathlib.NewRandom(spec.Seed)
+	agtConstructor, envConstructor, err := constructors(e, a, rng)
+	if err != nil {
+		return err
+	}
+	return internal.RunTrialsContext(ctx, rng, agtConstructor, envConstructor, spec.trialConfig(spec.fileName(e, a), e, a))
+}
+
+// trialConfig returns the settings of the trials of agent a on environment e, whose results are
+// named name.
+func (spec *Spec) trialConfig(name string, e EnvSpec, a AgentSpec) internal.TrialConfig {
+	cfg := internal.TrialConfig{
+		NumTrials:   spec.Trials,
+		NumEps:      spec.Episodes,
+		Parallelism: spec.Parallelism,
+		OutputDir:   spec.outputDir(),
+		FileName:    name,
+		Fingerprint: spec.fingerprint(e, a),
+		Log:         spec.Log,
+	}
+	if cfg.NumTrials == 0 {
+		cfg.NumTrials = 1
+	}
+	if spec.Checkpoint > 0 {
+		cfg.Checkpoint = filepath.Join(spec.outputDir(), name+"_checkpoint.json")
+		cfg.CheckpointEvery = spec.Checkpoint
+	}
+	return cfg
+}
+
+// fingerprint returns the seed and a hash of the hyperparameters of agent a on environment e, which
+// tell the checkpoints of different runs apart even when their results have the same name.
+func (spec *Spec) fingerprint(e EnvSpec, a AgentSpec) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%v", e, a) // Maps are printed in key order
+	return fmt.Sprintf("seed %d, %x", spec.Seed, h.Sum(nil))
+}
+
+// constructors returns the constructors of the agents and environments of a pair, which draw from
+// rng. Both are built once first to find errors in the hyperparameters before any trial runs.
+func constructors(e EnvSpec, a AgentSpec, rng *mathlib.Random) (func() internal.Agent, func() internal.Environment, error) {
+	// Agents are sized by an environment of their own, which is closed once the agent is built
+	newAgent := func() (internal.Agent, error) {
+		env, err := NewEnvironment(e, rng)
+		if err != nil {
+			return nil, err
+		}
+		defer closeEnvironment(env)
+		return NewAgent(a, env)
+	}
+	if _, err := newAgent(); err != nil {
+		return nil, nil, err
+	}
+
+	// The constructors can't fail after that, since they get the same hyperparameters
+	envConstructor := func() internal.Environment {
+		env, err := NewEnvironment(e, rng)
+		if err != nil {
+			panic(err)
+		}
+		return env
+	}
+	agtConstructor := func() internal.Agent {
+		agt, err := newAgent()
+		if err != nil {
+			panic(err)
+		}
+		return agt
+	}
+	return agtConstructor, envConstructor, nil
+}
+
+// closeEnvironment closes env if it is an io.Closer, like the subprocess and remote environments.
+func closeEnvironment(env internal.Environment) {
+	if c, ok := env.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// write saves the spec as YAML to path.
+func (spec *Spec) write(path string) error {
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,168 @@
+// Package experiment runs experiments described by spec files. A spec names the environments and
+// agents to compare, their hyperparameters, the number of trials, the seed and where to write the
+// results, so an experiment can be changed and reproduced without recompiling. Environments,
+// wrappers and agents are looked up by name in a registry that holds the ones in this repository
+// and can be extended with RegisterEnvironment, RegisterWrapper and RegisterAgent.
+//
+// A spec is YAML (or JSON, which is also YAML):
+//
+//	name: gridworld
+//	seed: 0
+//	trials: 10
+//	episodes: 1000      # 0 or unset means the environment's GetMaxEps
+//	output_dir: data
+//	environments:
+//	  - name: gridworld
+//	    wrappers:
+//	      - name: time_limit
+//	        params: {steps: 100}
+//	agents:
+//	  - name: sarsa
+//	    params: {alpha: 0.001, policy: softmax, temperature: 1}
+//
+// Every agent is run on every environment. The returns of each pair are written to
+// <output_dir>/<agent>_out.csv when there is one environment and to
+// <output_dir>/<environment>_<agent>_out.csv otherwise, where environments and agents are named by
+// their label, or by their registered name if they have no label.
+//
+// A spec with checkpoint: n saves the progress of every trial each n episodes to
+// <output_dir>/<file>_checkpoint.json, next to the returns file, and running it again resumes from
+// there, so a run that was killed carries on and writes the same returns as if it never stopped.
+// Pairs that finished are not run again. Delete the checkpoints to start the spec over.
+package experiment
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes an experiment.
+type Spec struct {
+	Name         string      `yaml:"name" json:"name"`
+	Seed         int64       `yaml:"seed" json:"seed"`                 // Seed of the random number generator of every run
+	Trials       int         `yaml:"trials" json:"trials"`             // Trials per environment and agent, 1 if unset
+	Episodes     int         `yaml:"episodes" json:"episodes"`         // Episodes per trial, or 0 for the environment's GetMaxEps
+	Parallelism  int         `yaml:"parallelism" json:"parallelism"`   // Trials run at once, or 0 for one per CPU
+	OutputDir    string      `yaml:"output_dir" json:"output_dir"`     // Directory of the results, "data" if empty
+	Checkpoint   int         `yaml:"checkpoint" json:"checkpoint"`     // Episodes between checkpoints of each trial, 0 for no checkpoints
+	Environments []EnvSpec   `yaml:"environments" json:"environments"` // Environments to run every agent on
+	Agents       []AgentSpec `yaml:"agents" json:"agents"`             // Agents to compare
+
+	Log io.Writer `yaml:"-" json:"-"` // Where Run writes the progress of the trials, or nil to write nothing
+}
+
+// EnvSpec is a registered environment, its hyperparameters and the wrappers around it, innermost first.
+type EnvSpec struct {
+	Name     string        `yaml:"name" json:"name"`
+	Label    string        `yaml:"label,omitempty" json:"label,omitempty"`
+	Params   Params        `yaml:"params,omitempty" json:"params,omitempty"`
+	Wrappers []WrapperSpec `yaml:"wrappers,omitempty" json:"wrappers,omitempty"`
+}
+
+// WrapperSpec is a registered wrapper and its hyperparameters.
+type WrapperSpec struct {
+	Name   string `yaml:"name" json:"name"`
+	Params Params `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// AgentSpec is a registered agent and its hyperparameters.
+type AgentSpec struct {
+	Name   string `yaml:"name" json:"name"`
+	Label  string `yaml:"label,omitempty" json:"label,omitempty"`
+	Params Params `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// LabelOrName returns the label of the environment, or its name if it has none.
+func (e EnvSpec) LabelOrName() string {
+	if e.Label != "" {
+		return e.Label
+	}
+	return e.Name
+}
+
+// LabelOrName returns the label of the agent, or its name if it has none.
+func (a AgentSpec) LabelOrName() string {
+	if a.Label != "" {
+		return a.Label
+	}
+	return a.Name
+}
+
+// ParseSpec reads a YAML or JSON spec and checks it with Validate. Unknown fields are errors.
+func ParseSpec(r io.Reader) (*Spec, error) {
+	spec := Spec{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("reading spec: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// LoadSpec reads the spec file at path.
+func LoadSpec(path string) (*Spec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	spec, err := ParseSpec(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return spec, nil
+}
+
+// Validate returns an error if the spec names an unregistered environment, wrapper or agent, has
+// no environments or agents, or would write two results to the same file. It does not construct
+// anything, so bad hyperparameters are only found by Run.
+func (spec *Spec) Validate() error {
+	if spec.Trials < 0 || spec.Episodes < 0 || spec.Parallelism < 0 || spec.Checkpoint < 0 {
+		return fmt.Errorf("trials, episodes, parallelism and checkpoint can't be negative")
+	}
+	if len(spec.Environments) == 0 {
+		return fmt.Errorf("spec has no environments")
+	}
+	if len(spec.Agents) == 0 {
+		return fmt.Errorf("spec has no agents")
+	}
+
+	envLabels := map[string]bool{}
+	for _, e := range spec.Environments {
+		if _, ok := envRegistry[e.Name]; !ok {
+			return fmt.Errorf("unknown environment %q", e.Name)
+		}
+		limited := false
+		for _, w := range e.Wrappers {
+			if _, ok := wrapperRegistry[w.Name]; !ok {
+				return fmt.Errorf("unknown wrapper %q", w.Name)
+			}
+			limited = limited || w.Name == "time_limit"
+		}
+		if nonterminating[e.Name] && !limited {
+			return fmt.Errorf("environment %q never ends its episodes; wrap it in a time_limit", e.LabelOrName())
+		}
+		if envLabels[e.LabelOrName()] {
+			return fmt.Errorf("environment %q appears twice; give them different labels", e.LabelOrName())
+		}
+		envLabels[e.LabelOrName()] = true
+	}
+
+	agentLabels := map[string]bool{}
+	for _, a := range spec.Agents {
+		if _, ok := agentRegistry[a.Name]; !ok {
+			return fmt.Errorf("unknown agent %q", a.Name)
+		}
+		if agentLabels[a.LabelOrName()] {
+			return fmt.Errorf("agent %q appears twice; give them different labels", a.LabelOrName())
+		}
+		agentLabels[a.LabelOrName()] = true
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// ExplorationPolicy turns the action preferences (or action values) of a state into an action.
+type ExplorationPolicy interface {
+	// SelectAction returns the action to take in state s given the preferences for each action in s.
+	SelectAction(s int, prefs []float64, rng *mathlib.Random) int
+	// Probabilities returns the probability of selecting each action in state s given its preferences.
+	Probabilities(s int, prefs []float64) []float64
+	// Reset the policy entirely - counts, schedules etc. go back to their initial values.
+	Reset()
+}
+
+// Schedule returns a hyperparameter value (e.g., epsilon) as a function of the number of steps taken.
+type Schedule interface {
+	Value(step int) float64
+}
+
+// ConstantSchedule always returns the same value.
+type ConstantSchedule float64
+
+// Value returns the constant value.
+func (c ConstantSchedule) Value(step int) float64 {
+	return float64(c)
+}
+
+// LinearSchedule decays linearly from Start to End over Steps steps and then stays at End.
+type LinearSchedule struct {
+	Start float64
+	End   float64
+	Steps int
+}
+
+// Value returns the linearly interpolated value at step.
+func (l LinearSchedule) Value(step int) float64 {
+	if step >= l.Steps {
+		return l.End
+	}
+	frac := float64(step) / float64(l.Steps)
+	return l.Start + frac*(l.End-l.Start)
+}
+
+// ExponentialSchedule decays as Start * Decay^step, but never below Min.
+type ExponentialSchedule struct {
+	Start float64
+	Decay float64
+	Min   float64
+}
+
+// Value returns the exponentially decayed value at step.
+func (e ExponentialSchedule) Value(step int) float64 {
+	return math.Max(e.Min, e.Start*math.Pow(e.Decay, float64(step)))
+}
+
+// sampleDiscrete selects an index from the passed probability vector.
+func sampleDiscrete(probs []float64, rng *mathlib.Random) int {
+	temp := rng.Float64()
+	sum := 0.0
+	for a := 0; a < len(probs); a++ {
+		sum += probs[a]
+		if temp <= sum {
+			return a
+		}
+	}
+	return len(probs) - 1 // Rounding error
+}
+
+// argmax returns the index of the largest value, breaking ties randomly.
+func argmax(v []float64, rng *mathlib.Random) int {
+	best := []int{0}
+	for a := 1; a < len(v); a++ {
+		if v[a] > v[best[0]] {
+			best = []int{a}
+		} else if v[a] == v[best[0]] {
+			best = append(best, a)
+		}
+	}
+	if len(best) == 1 || rng == nil {
+		return best[0]
+	}
+	return best[int(rng.Float64()*float64(len(best)))]
+}
+
+// softmax returns the Boltzmann distribution over prefs at the passed temperature.
+func softmax(prefs []float64, temperature float64) []float64 {
+	probs := make([]float64, len(prefs))
+	// Subtract the max preference so large (e.g., optimistic) values don't overflow
+	max := prefs[0]
+	for a := 1; a < len(prefs); a++ {
+		max = math.Max(max, prefs[a])
+	}
+	denominator := 0.0
+	for a := 0; a < len(prefs); a++ {
+		probs[a] = math.Exp((prefs[a] - max) / temperature)
+		denominator += probs[a]
+	}
+	return mathlib.ScalarDivideVec(probs, denominator)
+}
+
+// Softmax selects actions from the Boltzmann distribution over the preferences.
+type Softmax struct {
+	temperature float64
+}
+
+// NewSoftmax returns a softmax policy with the passed temperature. A temperature of 1 is the plain softmax.
+func NewSoftmax(temperature float64) ExplorationPolicy {
+	if temperature <= 0 {
+		panic("Softmax temperature must be positive.")
+	}
+	return &Softmax{temperature: temperature}
+}
+
+// SelectAction samples from the softmax distribution.
+func (p *Softmax) SelectAction(s int, prefs []float64, rng *mathlib.Random) int {
+	return sampleDiscrete(p.Probabilities(s, prefs), rng)
+}
+
+// Probabilities returns the softmax distribution.
+func (p *Softmax) Probabilities(s int, prefs []float64) []float64 {
+	return softmax(prefs, p.temperature)
+}
+
+// Reset does nothing since softmax has no state.
+func (p *Softmax) Reset() {}
+
+// EpsilonGreedy takes the greedy action with probability 1-epsilon and a uniformly random action otherwise.
+type EpsilonGreedy struct {
+	epsilon Schedule
+	step    int // How many actions have been selected since the last reset?
+}
+
+// NewEpsilonGreedy returns an epsilon-greedy policy where epsilon follows the passed schedule.
+func NewEpsilonGreedy(epsilon Schedule) ExplorationPolicy {
+	return &EpsilonGreedy{epsilon: epsilon}
+}
+
+// SelectAction returns an epsilon-greedy action and advances the schedule.
+func (p *EpsilonGreedy) SelectAction(s int, prefs []float64, rng *mathlib.Random) int {
+	eps := p.epsilon.Value(p.step)
+	p.step++
+	if rng.Float64() < eps {
+		return int(rng.Float64() * float64(len(prefs)))
+	}
+	return argmax(prefs, rng)
+}
+
+// Probabilities returns the epsilon-greedy distribution at the current point of the schedule.
+func (p *EpsilonGreedy) Probabilities(s int, prefs []float64) []float64 {
+	eps := p.epsilon.Value(p.step)
+	probs := mathlib.Vector(len(prefs), eps/float64(len(prefs)))
+	probs[argmax(prefs, nil)] += 1 - eps
+	return probs
+}
+
+// Reset restarts the schedule.
+func (p *EpsilonGreedy) Reset() {
+	p.step = 0
+}
+
+// epsilonGreedySnapshot is the position of an EpsilonGreedy policy in its schedule.
+type epsilonGreedySnapshot struct {
+	Step int `json:"step"`
+}
+
+// Snapshot returns the position in the schedule.
+func (p *EpsilonGreedy) Snapshot() ([]byte, error) {
+	return json.Marshal(epsilonGreedySnapshot{Step: p.step})
+}
+
+// Restore sets the position in the schedule to a snapshot.
+func (p *EpsilonGreedy) Restore(data []byte) error {
+	snap := epsilonGreedySnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	p.step = snap.Step
+	return nil
+}
+
+// visitCounts tracks N(s) and N(s,a) for count-based policies.
+type visitCounts struct {
+	stateCounts  []float64
+	actionCounts [][]float64
+}
+
+func newVisitCounts(numStates int, numActions int) visitCounts {
+	return visitCounts{
+		stateCounts:  mathlib.Vector(numStates, 0),
+		actionCounts: mathlib.Matrix(numStates, numActions, 0),
+	}
+}
+
+func (c *visitCounts) visit(s int, a int) {
+	c.stateCounts[s]++
+	c.actionCounts[s][a]++
+}
+
+func (c *visitCounts) reset() {
+	mathlib.ZeroVec(&c.stateCounts)
+	mathlib.ResetMat(&c.actionCounts, 0)
+}
+
+// visitCountsSnapshot is the state of a visitCounts.
+type visitCountsSnapshot struct {
+	StateCounts  []float64   `json:"state_counts"`
+	ActionCounts [][]float64 `json:"action_counts"`
+}
+
+func (c *visitCounts) snapshot() ([]byte, error) {
+	return json.Marshal(visitCountsSnapshot{StateCounts: c.stateCounts, ActionCounts: c.actionCounts})
+}
+
+func (c *visitCounts) restore(data []byte) error {
+	snap := visitCountsSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkVector("state counts", snap.StateCounts, len(c.stateCounts)); err != nil {
+		return err
+	}
+	if err := checkMatrix("action counts", snap.ActionCounts, len(c.actionCounts), len(c.actionCounts[0])); err != nil {
+		return err
+	}
+	c.stateCounts = snap.StateCounts
+	c.actionCounts = snap.ActionCounts
+	return nil
+}
+
+// UCB1 selects argmax_a Q(s,a) + c*sqrt(ln N(s) / N(s,a)), trying every action in a state once first.
+type UCB1 struct {
+	c      float64
+	counts visitCounts
+}
+
+// NewUCB1 returns a UCB1 policy over numStates x numActions state-action counts with exploration constant c.
+func NewUCB1(numStates int, numActions int, c float64) ExplorationPolicy {
+	return &UCB1{c: c, counts: newVisitCounts(numStates, numActions)}
+}
+
+func (p *UCB1) scores(s int, prefs []float64) []float64 {
+	scores := make([]float64, len(prefs))
+	logN := math.Log(p.counts.stateCounts[s])
+	for a := 0; a < len(prefs); a++ {
+		n := p.counts.actionCounts[s][a]
+		if n == 0 {
+			scores[a] = math.Inf(1)
+		} else {
+			scores[a] = prefs[a] + p.c*math.Sqrt(logN/n)
+		}
+	}
+	return scores
+}
+
+// SelectAction returns the action with the largest upper confidence bound and counts the visit.
+func (p *UCB1) SelectAction(s int, prefs []float64, rng *mathlib.Random) int {
+	a := argmax(p.scores(s, prefs), rng)
+	p.counts.visit(s, a)
+	return a
+}
+
+// Probabilities puts all of the mass on the action with the largest upper confidence bound.
+func (p *UCB1) Probabilities(s int, prefs []float64) []float64 {
+	probs := make([]float64, len(prefs))
+	probs[argmax(p.scores(s, prefs), nil)] = 1
+	return probs
+}
+
+// Reset forgets all visit counts.
+func (p *UCB1) Reset() {
+	p.counts.reset()
+}
+
+// Snapshot returns the visit counts.
+func (p *UCB1) Snapshot() ([]byte, error) {
+	return p.counts.snapshot()
+}
+
+// Restore sets the visit counts to a snapshot.
+func (p *UCB1) Restore(data []byte) error {
+	return p.counts.restore(data)
+}
+
+// CountBonus adds an exploration bonus of beta/sqrt(N(s,a)+1) to each preference and then
+// selects from a softmax over the bonus-adjusted preferences.
+type CountBonus struct {
+	beta        float64
+	temperature float64
+	counts      visitCounts
+}
+
+// NewCountBonus returns a count-based exploration policy over numStates x numActions state-action counts.
+func NewCountBonus(numStates int, numActions int, beta float64, temperature float64) ExplorationPolicy {
+	if temperature <= 0 {
+		panic("CountBonus temperature must be positive.")
+	}
+	return &CountBonus{beta: beta, temperature: temperature, counts: newVisitCounts(numStates, numActions)}
+}
+
+// SelectAction samples from the bonus-adjusted softmax and counts the visit.
+func (p *CountBonus) SelectAction(s int, prefs []float64, rng *mathlib.Random) int {
+	a := sampleDiscrete(p.Probabilities(s, prefs), rng)
+	p.counts.visit(s, a)
+	return a
+}
+
+// Probabilities returns the softmax distribution over the bonus-adjusted preferences.
+func (p *CountBonus) Probabilities(s int, prefs []float64) []float64 {
+	adjusted := make([]float64, len(prefs))
+	for a := 0; a < len(prefs); a++ {
+		adjusted[a] = prefs[a] + p.beta/math.Sqrt(p.counts.actionCounts[s][a]+1)
+	}
+	return softmax(adjusted, p.temperature)
+}
+
+// Reset forgets all visit counts.
+func (p *CountBonus) Reset() {
+	p.counts.reset()
+}
+
+// Snapshot returns the visit counts.
+func (p *CountBonus) Snapshot() ([]byte, error) {
+	return p.counts.snapshot()
+}
+
+// Restore sets the visit counts to a snapshot.
+func (p *CountBonus) Restore(data []byte) error {
+	return p.counts.restore(data)
+}
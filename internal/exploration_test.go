@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftmaxProbabilities(t *testing.T) {
+	p := NewSoftmax(1)
+	probs := p.Probabilities(0, []float64{0, 0, 0, 0})
+	assert.InDeltaSlice(t, []float64{0.25, 0.25, 0.25, 0.25}, probs, 1e-12)
+
+	// Large preferences should not overflow
+	probs = p.Probabilities(0, []float64{1000, 0})
+	assert.InDelta(t, 1.0, probs[0], 1e-12)
+}
+
+func TestSoftmaxDoesNotModifyPreferences(t *testing.T) {
+	p := NewSoftmax(1)
+	prefs := []float64{1, 2, 3}
+	p.SelectAction(0, prefs, mathlib.NewRandom(0))
+	assert.Equal(t, []float64{1, 2, 3}, prefs)
+}
+
+func TestEpsilonGreedySchedule(t *testing.T) {
+	p := NewEpsilonGreedy(LinearSchedule{Start: 1, End: 0, Steps: 10})
+	r := mathlib.NewRandom(0)
+	for i := 0; i < 10; i++ {
+		p.SelectAction(0, []float64{0, 1}, r)
+	}
+	// Epsilon has decayed to zero, so the greedy action is always taken
+	assert.Equal(t, []float64{0, 1}, p.Probabilities(0, []float64{0, 1}))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 1, p.SelectAction(0, []float64{0, 1}, r))
+	}
+	p.Reset()
+	assert.Equal(t, []float64{0.5, 0.5}, p.Probabilities(0, []float64{0, 1}))
+}
+
+func TestUCB1TriesEveryActionFirst(t *testing.T) {
+	p := NewUCB1(1, 4, 2)
+	r := mathlib.NewRandom(0)
+	seen := map[int]bool{}
+	for i := 0; i < 4; i++ {
+		seen[p.SelectAction(0, []float64{10, 0, 0, 0}, r)] = true
+	}
+	assert.Len(t, seen, 4)
+}
+
+func TestCountBonusFavoursUnvisited(t *testing.T) {
+	p := NewCountBonus(1, 2, 5, 1).(*CountBonus)
+	for i := 0; i < 100; i++ {
+		p.counts.visit(0, 0)
+	}
+	probs := p.Probabilities(0, []float64{0, 0})
+	assert.True(t, probs[1] > probs[0])
+}
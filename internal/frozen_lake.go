@@ -0,0 +1,105 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// FrozenLake4x4 and FrozenLake8x8 are the standard OpenAI Gym lakes. S is the start, F frozen
+// (safe) ice, H a hole and G the goal.
+var (
+	FrozenLake4x4 = []string{
+		"SFFF",
+		"FHFH",
+		"FFFH",
+		"HFFG",
+	}
+	FrozenLake8x8 = []string{
+		"SFFFFFFF",
+		"FFFFFFFF",
+		"FFFHFFFF",
+		"FFFFFHFF",
+		"FFFHFFFF",
+		"FHHFFFHF",
+		"FHFFHFHF",
+		"FFFHFFFG",
+	}
+)
+
+// FrozenLake is OpenAI Gym's FrozenLake. The agent crosses a frozen lake from the start to the
+// goal without falling into a hole. Reaching the goal pays +1 and ends the episode, falling into a
+// hole ends it with 0. On slippery ice the agent moves in the intended direction or either
+// perpendicular direction with probability 1/3 each.
+// Actions are up (0), right (1), down (2) and left (3).
+type FrozenLake struct {
+	lake     []string
+	slippery bool
+	x        int // Agent horizontal coordinate
+	y        int // Agent vertical coordinate
+}
+
+// NewFrozenLake returns a new FrozenLake Environment object on the passed lake, e.g. FrozenLake4x4.
+func NewFrozenLake(lake []string, slippery bool, rng *mathlib.Random) Environment {
+	env := FrozenLake{lake: lake, slippery: slippery}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *FrozenLake) GetMaxEps() int {
+	return 2000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *FrozenLake) GetStateDim() int {
+	return len(env.lake) * len(env.lake[0])
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *FrozenLake) GetNumActions() int {
+	return 4
+}
+
+// GetGamma returns \gamma
+func (env *FrozenLake) GetGamma() float64 {
+	return 0.99
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *FrozenLake) Transition(a int, rng *mathlib.Random) float64 {
+	if env.slippery {
+		// -1, 0 or +1 quarter turns
+		a = (a + int(rng.Float64()*3) + 3) % 4
+	}
+	xPrime, yPrime := gridStep(env.x, env.y, a)
+	if xPrime >= 0 && yPrime >= 0 && xPrime < len(env.lake[0]) && yPrime < len(env.lake) {
+		env.x = xPrime
+		env.y = yPrime
+	}
+	if env.lake[env.y][env.x] == 'G' {
+		return 1.0
+	}
+	return 0.0
+}
+
+// GetState returns the one-hot encoding of the agent's position.
+func (env *FrozenLake) GetState() []float64 {
+	return mathlib.ToOneHot(env.y*len(env.lake[0])+env.x, env.GetStateDim())
+}
+
+// InTAS returns whether the agent has reached the goal or fallen into a hole.
+func (env *FrozenLake) InTAS() bool {
+	c := env.lake[env.y][env.x]
+	return c == 'G' || c == 'H'
+}
+
+// NewEpisode moves the agent to the start cell.
+func (env *FrozenLake) NewEpisode(rng *mathlib.Random) {
+	for y, row := range env.lake {
+		for x, c := range row {
+			if c == 'S' {
+				env.x = x
+				env.y = y
+				return
+			}
+		}
+	}
+	panic("FrozenLake map has no start cell.")
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddCard(t *testing.T) {
+	sum, ace := addCard(0, false, 1)
+	assert.Equal(t, 11, sum)
+	assert.True(t, ace)
+	sum, ace = addCard(sum, ace, 1)
+	assert.Equal(t, 12, sum)
+	assert.True(t, ace)
+	sum, ace = addCard(sum, ace, 10)
+	assert.Equal(t, 12, sum)
+	assert.False(t, ace)
+}
+
+func TestBlackjackStates(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	game := NewBlackjack(r).(*Blackjack)
+	assert.Equal(t, 200, game.GetStateDim())
+	for ep := 0; ep < 1000; ep++ {
+		game.NewEpisode(r)
+		for !game.InTAS() {
+			digits := mathlib.FromOneHotMixedRadix(game.GetState(), blackjackRadices)
+			assert.Equal(t, game.playerSum, digits[0]+12)
+			assert.Equal(t, game.dealerCard, digits[1]+1)
+			reward := game.Transition(int(r.Float64()*2), r)
+			assert.Contains(t, []float64{-1, 0, 1}, reward)
+		}
+	}
+}
+
+func TestBlackjackStickOn20(t *testing.T) {
+	// Sticking only on 20 or 21 loses on average (Sutton and Barto, Figure 5.1)
+	r := mathlib.NewRandom(0)
+	game := NewBlackjack(r).(*Blackjack)
+	total := 0.0
+	for ep := 0; ep < 20000; ep++ {
+		game.NewEpisode(r)
+		for !game.InTAS() {
+			action := 1
+			if game.playerSum >= 20 {
+				action = 0
+			}
+			total += game.Transition(action, r)
+		}
+	}
+	mean := total / 20000
+	assert.True(t, mean < -0.05 && mean > -0.4)
+}
+
+func TestPig(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	game := NewPig(20, r).(*Pig)
+	assert.Equal(t, 400, game.GetStateDim())
+
+	game.score, game.turnTotal = 10, 5
+	assert.Equal(t, []int{10, 5}, mathlib.FromOneHotMixedRadix(game.GetState(), game.radices()))
+	assert.Equal(t, -1.0, game.Transition(1, r))
+	assert.Equal(t, 15, game.score)
+	assert.Equal(t, 0, game.turnTotal)
+
+	// Always rolling eventually reaches the target
+	game.NewEpisode(r)
+	turns := 0.0
+	for i := 0; i < 10000 && !game.InTAS(); i++ {
+		turns -= game.Transition(0, r)
+	}
+	assert.True(t, game.InTAS())
+	assert.True(t, turns >= 1)
+}
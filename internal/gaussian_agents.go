@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Bounds on the log standard deviation of Gaussian policies, so they neither collapse nor explode.
+const (
+	minLogStd = -5.0
+	maxLogStd = 2.0
+)
+
+// gaussianPolicy is a Gaussian policy over actions whose mean is linear in the state (plus a bias)
+// and whose log standard deviation is a learned vector that does not depend on the state.
+type gaussianPolicy struct {
+	stateDim  int
+	actionDim int
+	initStd   float64
+	mean      [][]float64 // actionDim x (stateDim+1) weights of the mean
+	logStd    []float64   // Log standard deviation of each action component
+}
+
+// newGaussianPolicy returns a policy with zero mean and standard deviation initStd.
+func newGaussianPolicy(stateDim int, actionDim int, initStd float64) *gaussianPolicy {
+	p := gaussianPolicy{stateDim: stateDim, actionDim: actionDim, initStd: initStd}
+	p.reset()
+	return &p
+}
+
+// reset returns the policy to zero mean and standard deviation initStd.
+func (p *gaussianPolicy) reset() {
+	p.mean = mathlib.Matrix(p.actionDim, p.stateDim+1, 0)
+	p.logStd = mathlib.Vector(p.actionDim, math.Log(p.initStd))
+}
+
+// features returns the state with a bias term appended.
+func features(s []float64) []float64 {
+	return append(append(make([]float64, 0, len(s)+1), s...), 1)
+}
+
+// sample draws an action for state s.
+func (p *gaussianPolicy) sample(s []float64, rng *mathlib.Random) []float64 {
+	mu := mathlib.MatVec(p.mean, features(s))
+	a := make([]float64, p.actionDim)
+	for i := range a {
+		a[i] = mu[i] + math.Exp(p.logStd[i])*rng.NormFloat64()
+	}
+	return a
+}
+
+// gradLogProb returns the gradient of log pi(a|s) with respect to the mean weights and the log
+// standard deviations.
+func (p *gaussianPolicy) gradLogProb(s []float64, a []float64) ([][]float64, []float64) {
+	x := features(s)
+	mu := mathlib.MatVec(p.mean, x)
+	gradMean := mathlib.Matrix(p.actionDim, p.stateDim+1, 0)
+	gradLogStd := make([]float64, p.actionDim)
+	for i := 0; i < p.actionDim; i++ {
+		variance := math.Exp(2 * p.logStd[i])
+		z := a[i] - mu[i]
+		for j := range x {
+			gradMean[i][j] = z / variance * x[j]
+		}
+		gradLogStd[i] = z*z/variance - 1
+	}
+	return gradMean, gradLogStd
+}
+
+// step adds scale times the gradient of log pi(a|s) to the parameters.
+func (p *gaussianPolicy) step(s []float64, a []float64, scale float64) {
+	gradMean, gradLogStd := p.gradLogProb(s, a)
+	p.apply(gradMean, gradLogStd, scale)
+}
+
+// apply adds scale times the passed gradient to the parameters.
+func (p *gaussianPolicy) apply(gradMean [][]float64, gradLogStd []float64, scale float64) {
+	for i := 0; i < p.actionDim; i++ {
+		for j := range gradMean[i] {
+			p.mean[i][j] += scale * gradMean[i][j]
+		}
+		p.logStd[i] = clip(p.logStd[i]+scale*gradLogStd[i], minLogStd, maxLogStd)
+	}
+}
+
+// supportsGaussian checks that observations are stateDim-dimensional and actions are a actionDim-dimensional Box.
+func supportsGaussian(stateDim int, actionDim int, observation Space, action Space) error {
+	if observation.Dim() != stateDim {
+		return fmt.Errorf("need %d-dimensional observations", stateDim)
+	}
+	if action.Kind != Box || action.Dim() != actionDim {
+		return fmt.Errorf("need %d-dimensional Box actions", actionDim)
+	}
+	return nil
+}
+
+// GaussianREINFORCE is REINFORCE (Williams, 1992) with a linear Gaussian policy. It updates the
+// policy at the end of every episode with the Monte Carlo returns.
+type GaussianREINFORCE struct {
+	gamma  float64 // Discount parameter
+	alpha  float64 // Step size
+	policy *gaussianPolicy
+
+	states  [][]float64 // States of the current episode
+	actions [][]float64 // Actions of the current episode
+	rewards []float64   // Rewards of the current episode
+}
+
+// NewGaussianREINFORCE returns a Gaussian REINFORCE agent whose policy starts with zero mean and standard deviation initStd.
+func NewGaussianREINFORCE(stateDim int, actionDim int, gamma float64, alpha float64, initStd float64) ContinuousAgent {
+	agt := GaussianREINFORCE{}
+	agt.gamma = gamma
+	agt.alpha = alpha
+	agt.policy = newGaussianPolicy(stateDim, actionDim, initStd)
+	return &agt
+}
+
+// GetAction samples an action from the policy.
+func (agt *GaussianREINFORCE) GetAction(s []float64, rng *mathlib.Random) []float64 {
+	return agt.policy.sample(s, rng)
+}
+
+// NewEpisode forgets the previous episode.
+func (agt *GaussianREINFORCE) NewEpisode() {
+	agt.states, agt.actions, agt.rewards = nil, nil, nil
+}
+
+// Supports returns an error unless observations have the policy's dimension and actions are a Box of the policy's dimension.
+func (agt *GaussianREINFORCE) Supports(observation Space, action Space) error {
+	return supportsGaussian(agt.policy.stateDim, agt.policy.actionDim, observation, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *GaussianREINFORCE) Reset(rng *mathlib.Random) {
+	agt.policy.reset()
+	agt.NewEpisode()
+}
+
+// Update records the transition for the end of episode update.
+func (agt *GaussianREINFORCE) Update(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.states = append(agt.states, s)
+	agt.actions = append(agt.actions, a)
+	agt.rewards = append(agt.rewards, r)
+}
+
+// LastUpdate records the last transition and updates the policy along the policy gradient estimate.
+func (agt *GaussianREINFORCE) LastUpdate(s []float64, a []float64, r float64, rng *mathlib.Random) {
+	agt.Update(s, a, r, nil, rng)
+
+	// The gradients use the policy from before the update, so they are all computed before any is applied
+	L := len(agt.rewards)
+	gradMeans := make([][][]float64, L)
+	gradLogStds := make([][]float64, L)
+	for t := 0; t < L; t++ {
+		gradMeans[t], gradLogStds[t] = agt.policy.gradLogProb(agt.states[t], agt.actions[t])
+	}
+
+	// Loop backwards so the return G_t can be accumulated
+	G := 0.0
+	for t := L - 1; t >= 0; t-- {
+		G = agt.rewards[t] + agt.gamma*G
+		agt.policy.apply(gradMeans[t], gradLogStds[t], agt.alpha*G)
+	}
+	agt.NewEpisode()
+}
+
+// TruncatedUpdate ends the episode like LastUpdate. Monte Carlo returns can't bootstrap from sPrime.
+func (agt *GaussianREINFORCE) TruncatedUpdate(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
+// GaussianActorCritic is the one-step actor-critic of Sutton and Barto (2018), Section 13.5, with a
+// linear Gaussian policy and a linear state-value critic.
+type GaussianActorCritic struct {
+	gamma       float64 // Discount parameter
+	alphaActor  float64 // Step size of the policy
+	alphaCritic float64 // Step size of the critic
+	policy      *gaussianPolicy
+	w           []float64 // Weights of the critic over the state and a bias
+}
+
+// NewGaussianActorCritic returns a Gaussian actor-critic agent whose policy starts with zero mean and standard deviation initStd.
+func NewGaussianActorCritic(stateDim int, actionDim int, gamma float64, alphaActor float64, alphaCritic float64, initStd float64) ContinuousAgent {
+	agt := GaussianActorCritic{}
+	agt.gamma = gamma
+	agt.alphaActor = alphaActor
+	agt.alphaCritic = alphaCritic
+	agt.policy = newGaussianPolicy(stateDim, actionDim, initStd)
+	agt.w = mathlib.Vector(stateDim+1, 0)
+	return &agt
+}
+
+// GetAction samples an action from the policy.
+func (agt *GaussianActorCritic) GetAction(s []float64, rng *mathlib.Random) []float64 {
+	return agt.policy.sample(s, rng)
+}
+
+// NewEpisode tells the agent that it is at the start of a new episode.
+func (agt *GaussianActorCritic) NewEpisode() {}
+
+// Supports returns an error unless observations have the policy's dimension and actions are a Box of the policy's dimension.
+func (agt *GaussianActorCritic) Supports(observation Space, action Space) error {
+	return supportsGaussian(agt.policy.stateDim, agt.policy.actionDim, observation, action)
+}
+
+// Reset the agent entirely - to a blank slate prior to learning
+func (agt *GaussianActorCritic) Reset(rng *mathlib.Random) {
+	agt.policy.reset()
+	mathlib.ZeroVec(&agt.w)
+}
+
+// value returns the critic's estimate of the value of s.
+func (agt *GaussianActorCritic) value(s []float64) float64 {
+	return mathlib.Dot(agt.w, features(s))
+}
+
+// learn updates the critic and the actor with the TD error of the transition.
+func (agt *GaussianActorCritic) learn(s []float64, a []float64, target float64) {
+	x := features(s)
+	delta := target - mathlib.Dot(agt.w, x)
+	for j := range agt.w {
+		agt.w[j] += agt.alphaCritic * delta * x[j]
+	}
+	agt.policy.step(s, a, agt.alphaActor*delta)
+}
+
+// Update makes a TD update that bootstraps from sPrime.
+func (agt *GaussianActorCritic) Update(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.learn(s, a, r+agt.gamma*agt.value(sPrime))
+}
+
+// LastUpdate makes a TD update towards the reward, since the value of the terminal absorbing state is zero.
+func (agt *GaussianActorCritic) LastUpdate(s []float64, a []float64, r float64, rng *mathlib.Random) {
+	agt.learn(s, a, r)
+}
+
+// TruncatedUpdate makes a TD update that bootstraps from the final state of the cut off episode.
+func (agt *GaussianActorCritic) TruncatedUpdate(s []float64, a []float64, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.Update(s, a, r, sPrime, rng)
+}
@@ -6,8 +6,7 @@ import "github.com/jackkenney/evolve-rl/mathlib"
 type Gridworld struct {
 	x   int  // Agent horizontal coordinate (0 to 4)
 	y   int  // Agent vertical coordinate (0 to 4)
-	t   int  // Time into the episode. We terminate after 100 time steps
-	tas bool // Are we in the terminal absorbing state?
+	tas bool // Is the episode over?
 }
 
 // NewGridworld returns a new Gridworld Environment object.
@@ -37,23 +36,16 @@ func (env *Gridworld) GetGamma() float64 {
 	return 0.9
 }
 
-// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+// Transition applies action a, updating the state of the environment. It returns the reward that
+// results from the state transition. Episodes only end at the goal; the original timeout of -100
+// after 100 steps is a wrappers.TimeLimit with a penalty.
 func (env *Gridworld) Transition(a int, r *mathlib.Random) float64 {
-	// Count the next timestep
-	env.t++
-
 	// Check if we should transition to s_infty.
 	if env.x == 4 && env.y == 4 {
 		env.tas = true
 		return 0.0
 	}
 
-	// Check if we should terminate due to running too long
-	if env.t == 100 {
-		env.tas = true
-		return -100.0
-	}
-
 	// We implement the "veer" and "stay" behavior with an "effective action" that is modified from the actual action "a"
 	effectiveAction := a
 
@@ -143,17 +135,16 @@ func (env *Gridworld) GetState() []float64 {
 	return result
 }
 
-// InTAS returns whether the current state is Terminal Absorbing State (TAS)
+// InTAS returns whether the current state is Terminal Absorbing State (TAS).
 func (env *Gridworld) InTAS() bool {
 	return env.tas || (env.x == 4 && env.y == 4)
 }
 
 // NewEpisode resets the environment to start a new episode (it samples the state from the initial state distribution).
 func (env *Gridworld) NewEpisode(rng *mathlib.Random) {
-	// Start at position (0,0), with the time counter also equal to zero
+	// Start at position (0,0)
 	env.x = 0
 	env.y = 0
-	env.t = 0
 	// We do not start in the terminal absorbing state
 	env.tas = false
 }
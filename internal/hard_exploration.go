@@ -0,0 +1,328 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// NChain is the n-chain of Strens (2000). Action 0 moves one state along the chain for no reward,
+// except at the far end where it stays and pays +10. Action 1 returns to the start and pays +2.
+// With probability slip the other action is taken instead. The task never terminates, so it needs
+// a time limit; myopic exploration settles for the +2 at the start of the chain.
+type NChain struct {
+	n     int     // Length of the chain
+	slip  float64 // Probability the action is flipped
+	state int
+}
+
+// NewNChain returns a new NChain Environment object with n states (Strens used n=5, slip=0.2).
+func NewNChain(n int, slip float64, rng *mathlib.Random) Environment {
+	if n < 2 {
+		panic("NChain needs at least two states.")
+	}
+	env := NChain{n: n, slip: slip}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *NChain) GetMaxEps() int {
+	return 1000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *NChain) GetStateDim() int {
+	return env.n
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *NChain) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *NChain) GetGamma() float64 {
+	return 0.99
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *NChain) Transition(a int, rng *mathlib.Random) float64 {
+	if rng.Float64() < env.slip {
+		a = 1 - a
+	}
+	if a == 1 {
+		env.state = 0
+		return 2.0
+	}
+	if env.state == env.n-1 {
+		return 10.0
+	}
+	env.state++
+	return 0.0
+}
+
+// GetState returns the one-hot encoding of the position in the chain.
+func (env *NChain) GetState() []float64 {
+	return mathlib.ToOneHot(env.state, env.n)
+}
+
+// InTAS always returns false since the chain never terminates.
+func (env *NChain) InTAS() bool {
+	return false
+}
+
+// NewEpisode moves the agent to the start of the chain.
+func (env *NChain) NewEpisode(rng *mathlib.Random) {
+	env.state = 0
+}
+
+// RiverSwim is the RiverSwim MDP of Strehl and Littman (2008) with the rewards of Osband et al. (2013).
+// Swimming left (0) always works and pays 0.005 at the left bank. Swimming right (1) against the current
+// usually fails, but staying at the right bank while swimming right pays 1. The task never terminates,
+// so it needs a time limit.
+type RiverSwim struct {
+	n     int // Number of states
+	state int
+}
+
+// NewRiverSwim returns a new RiverSwim Environment object with n states (the original uses n=6).
+func NewRiverSwim(n int, rng *mathlib.Random) Environment {
+	if n < 2 {
+		panic("RiverSwim needs at least two states.")
+	}
+	env := RiverSwim{n: n}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *RiverSwim) GetMaxEps() int {
+	return 1000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *RiverSwim) GetStateDim() int {
+	return env.n
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *RiverSwim) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *RiverSwim) GetGamma() float64 {
+	return 0.99
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *RiverSwim) Transition(a int, rng *mathlib.Random) float64 {
+	if a == 0 {
+		reward := 0.0
+		if env.state == 0 {
+			reward = 0.005
+		}
+		if env.state > 0 {
+			env.state--
+		}
+		return reward
+	}
+
+	reward := 0.0
+	if env.state == env.n-1 {
+		reward = 1.0
+	}
+	temp := rng.Float64()
+	switch {
+	case env.state == 0:
+		if temp < 0.6 {
+			env.state++
+		}
+	case env.state == env.n-1:
+		if temp < 0.4 {
+			env.state--
+		}
+	default:
+		if temp < 0.35 {
+			env.state++
+		} else if temp < 0.4 {
+			env.state--
+		}
+	}
+	return reward
+}
+
+// GetState returns the one-hot encoding of the position in the river.
+func (env *RiverSwim) GetState() []float64 {
+	return mathlib.ToOneHot(env.state, env.n)
+}
+
+// InTAS always returns false since the river never terminates.
+func (env *RiverSwim) InTAS() bool {
+	return false
+}
+
+// NewEpisode moves the agent to the left bank.
+func (env *RiverSwim) NewEpisode(rng *mathlib.Random) {
+	env.state = 0
+}
+
+// DeepSea is the deep sea task of Osband et al. (2019). The agent starts in the top-left corner of an
+// n x n grid and every step descends one row while moving one column left or right. Moving right costs
+// 0.01/n and reaching the bottom-right corner pays +1, so a policy that dithers finds the reward with
+// probability 2^-n. Which action moves right is drawn independently for every cell.
+type DeepSea struct {
+	n         int
+	rightMove [][]int // The action that moves right in each cell
+	row, col  int
+}
+
+// NewDeepSea returns a new DeepSea Environment object of size n.
+func NewDeepSea(n int, rng *mathlib.Random) Environment {
+	if n < 1 {
+		panic("DeepSea needs a positive size.")
+	}
+	env := DeepSea{n: n}
+	env.rightMove = make([][]int, n)
+	for row := 0; row < n; row++ {
+		env.rightMove[row] = make([]int, n)
+		for col := 0; col < n; col++ {
+			if rng.Float64() < 0.5 {
+				env.rightMove[row][col] = 1
+			}
+		}
+	}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *DeepSea) GetMaxEps() int {
+	return 1000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *DeepSea) GetStateDim() int {
+	return env.n * env.n
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *DeepSea) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *DeepSea) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *DeepSea) Transition(a int, rng *mathlib.Random) float64 {
+	reward := 0.0
+	if a == env.rightMove[env.row][env.col] {
+		reward -= 0.01 / float64(env.n)
+		if env.col < env.n-1 {
+			env.col++
+		}
+	} else if env.col > 0 {
+		env.col--
+	}
+	env.row++
+	if env.row == env.n && env.col == env.n-1 {
+		reward++
+	}
+	return reward
+}
+
+// GetState returns the one-hot encoding of the (row, column) of the agent.
+func (env *DeepSea) GetState() []float64 {
+	if env.row == env.n {
+		panic("GetState called when in TAS.")
+	}
+	return mathlib.ToOneHot(env.row*env.n+env.col, env.GetStateDim())
+}
+
+// InTAS returns whether the agent has reached the bottom row.
+func (env *DeepSea) InTAS() bool {
+	return env.row == env.n
+}
+
+// NewEpisode moves the agent to the top-left corner.
+func (env *DeepSea) NewEpisode(rng *mathlib.Random) {
+	env.row = 0
+	env.col = 0
+}
+
+// CombinationLock is a chain of length states where each state has one correct action out of
+// numActions. The correct action advances the agent and any other action ends the episode with no
+// reward. Opening the lock at the end of the chain pays +1, so a uniformly random policy succeeds
+// with probability numActions^-length. The combination is drawn at construction.
+type CombinationLock struct {
+	combination []int // The correct action in each state
+	numActions  int
+	state       int
+	failed      bool
+}
+
+// NewCombinationLock returns a new CombinationLock Environment object.
+func NewCombinationLock(length int, numActions int, rng *mathlib.Random) Environment {
+	if length < 1 || numActions < 2 {
+		panic("CombinationLock needs a positive length and at least two actions.")
+	}
+	env := CombinationLock{numActions: numActions}
+	env.combination = make([]int, length)
+	for i := range env.combination {
+		env.combination[i] = int(rng.Float64() * float64(numActions))
+	}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *CombinationLock) GetMaxEps() int {
+	return 1000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *CombinationLock) GetStateDim() int {
+	return len(env.combination)
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *CombinationLock) GetNumActions() int {
+	return env.numActions
+}
+
+// GetGamma returns \gamma
+func (env *CombinationLock) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *CombinationLock) Transition(a int, rng *mathlib.Random) float64 {
+	if a != env.combination[env.state] {
+		env.failed = true
+		return 0.0
+	}
+	env.state++
+	if env.state == len(env.combination) {
+		return 1.0
+	}
+	return 0.0
+}
+
+// GetState returns the one-hot encoding of the position in the combination.
+func (env *CombinationLock) GetState() []float64 {
+	if env.InTAS() {
+		panic("GetState called when in TAS.")
+	}
+	return mathlib.ToOneHot(env.state, len(env.combination))
+}
+
+// InTAS returns whether the lock is open or a wrong action was taken.
+func (env *CombinationLock) InTAS() bool {
+	return env.failed || env.state == len(env.combination)
+}
+
+// NewEpisode moves the agent to the start of the combination.
+func (env *CombinationLock) NewEpisode(rng *mathlib.Random) {
+	env.state = 0
+	env.failed = false
+}
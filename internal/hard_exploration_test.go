@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNChain(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	chain := NewNChain(5, 0, r)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, 0.0, chain.Transition(0, r))
+	}
+	assert.Equal(t, 10.0, chain.Transition(0, r))
+	assert.Equal(t, 4, mathlib.FromOneHot(chain.GetState()))
+	assert.Equal(t, 2.0, chain.Transition(1, r))
+	assert.Equal(t, 0, mathlib.FromOneHot(chain.GetState()))
+}
+
+func TestRiverSwim(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	river := NewRiverSwim(6, r).(*RiverSwim)
+	assert.Equal(t, 0.005, river.Transition(0, r))
+	river.state = 5
+	assert.Equal(t, 1.0, river.Transition(1, r))
+	river.state = 3
+	assert.Equal(t, 0.0, river.Transition(0, r))
+	assert.Equal(t, 2, river.state)
+}
+
+func TestDeepSea(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	sea := NewDeepSea(10, r).(*DeepSea)
+
+	// Always moving right finds the treasure
+	total := 0.0
+	for !sea.InTAS() {
+		total += sea.Transition(sea.rightMove[sea.row][sea.col], r)
+	}
+	assert.InDelta(t, 0.99, total, 1e-9)
+
+	// A uniformly random policy almost never does
+	successes := 0
+	for ep := 0; ep < 1000; ep++ {
+		sea.NewEpisode(r)
+		total = 0
+		for !sea.InTAS() {
+			total += sea.Transition(int(r.Float64()*2), r)
+		}
+		if total > 0 {
+			successes++
+		}
+	}
+	assert.True(t, successes < 10)
+}
+
+func TestCombinationLock(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	lock := NewCombinationLock(4, 3, r).(*CombinationLock)
+	for i, a := range lock.combination {
+		assert.False(t, lock.InTAS())
+		reward := lock.Transition(a, r)
+		if i == 3 {
+			assert.Equal(t, 1.0, reward)
+		}
+	}
+	assert.True(t, lock.InTAS())
+
+	lock.NewEpisode(r)
+	assert.Equal(t, 0.0, lock.Transition((lock.combination[0]+1)%3, r))
+	assert.True(t, lock.InTAS())
+}
@@ -0,0 +1,427 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Map cell symbols with a built-in meaning. Every other symbol is an open cell whose reward can be
+// set with a "reward" line.
+const (
+	WallCell  = '#'
+	OpenCell  = '.'
+	StartCell = 'S'
+	GoalCell  = 'G'
+	WaterCell = 'W'
+)
+
+// MapConfig describes a MapGridworld.
+type MapConfig struct {
+	Rows      []string         // The map, one string per row. Row 0 is the top.
+	Gamma     float64          // Discount parameter
+	MaxEps    int              // How many episodes should be run?
+	Stay      float64          // Probability the agent does not move
+	VeerRight float64          // Probability the action is rotated 90 degrees clockwise
+	VeerLeft  float64          // Probability the action is rotated 90 degrees counter-clockwise
+	Rewards   map[rune]float64 // Reward for entering a cell with this symbol
+	Terminal  map[rune]bool    // Symbols of cells that end the episode when entered
+}
+
+// DefaultMapConfig returns the settings of the original Gridworld with an empty map. Episodes only
+// end at terminal cells, so maps where the agent can get stuck need a wrappers.TimeLimit.
+func DefaultMapConfig() MapConfig {
+	return MapConfig{
+		Gamma:     0.9,
+		MaxEps:    1000,
+		Stay:      0.1,
+		VeerRight: 0.05,
+		VeerLeft:  0.05,
+		Rewards:   map[rune]float64{WaterCell: -10, GoalCell: 10},
+		Terminal:  map[rune]bool{GoalCell: true},
+	}
+}
+
+// DefaultGridworldMap is the map file of the original 5x5 Gridworld.
+const DefaultGridworldMap = `# Obstructed 5x5 gridworld
+gamma = 0.9
+episodes = 1000
+stay = 0.1
+veer_right = 0.05
+veer_left = 0.05
+reward W = -10
+reward G = 10
+terminal = G
+map
+S....
+.....
+..#..
+..#..
+..W.G
+`
+
+// MapGridworld is a Gridworld whose layout, rewards and dynamics come from a MapConfig.
+// States are one-hot encoded over the non-wall cells in row-major order.
+type MapGridworld struct {
+	cfg       MapConfig
+	width     int
+	height    int
+	numStates int      // Number of non-wall cells
+	cells     [][]rune // Symbol of every cell
+	index     [][]int  // One-hot index of every cell, -1 for walls
+	starts    [][2]int // (x,y) of every start cell
+
+	x   int  // Agent horizontal coordinate
+	y   int  // Agent vertical coordinate
+	tas bool // Is the episode over?
+}
+
+// NewMapGridworld returns a new MapGridworld Environment built from the passed config.
+func NewMapGridworld(cfg MapConfig, rng *mathlib.Random) (Environment, error) {
+	if len(cfg.Rows) == 0 {
+		return nil, fmt.Errorf("map has no rows")
+	}
+	if cfg.Stay+cfg.VeerRight+cfg.VeerLeft > 1 {
+		return nil, fmt.Errorf("slip probabilities sum to more than one")
+	}
+	env := MapGridworld{cfg: cfg}
+	env.height = len(cfg.Rows)
+	env.width = len([]rune(cfg.Rows[0]))
+	env.cells = make([][]rune, env.height)
+	env.index = make([][]int, env.height)
+
+	for y, row := range cfg.Rows {
+		cells := []rune(row)
+		if len(cells) != env.width {
+			return nil, fmt.Errorf("map row %d has %d cells, expected %d", y, len(cells), env.width)
+		}
+		env.cells[y] = cells
+		env.index[y] = make([]int, env.width)
+		for x, c := range cells {
+			if c == WallCell {
+				env.index[y][x] = -1
+				continue
+			}
+			env.index[y][x] = env.numStates
+			env.numStates++
+			if c == StartCell {
+				env.starts = append(env.starts, [2]int{x, y})
+			}
+		}
+	}
+	if len(env.starts) == 0 {
+		return nil, fmt.Errorf("map has no start cell '%c'", StartCell)
+	}
+
+	env.NewEpisode(rng)
+	return &env, nil
+}
+
+// ParseMapGridworld reads a map file and returns the MapGridworld it describes.
+func ParseMapGridworld(r io.Reader, rng *mathlib.Random) (Environment, error) {
+	cfg, err := ParseMapConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewMapGridworld(cfg, rng)
+}
+
+// ParseMapConfig reads a map file. The file starts with optional "key = value" settings and
+// "reward <symbol> = <value>" lines, followed by a line containing only "map" and then the map rows.
+// Before the map, '#' starts a comment at the start of a line or after a space.
+func ParseMapConfig(r io.Reader) (MapConfig, error) {
+	cfg := DefaultMapConfig()
+	scanner := bufio.NewScanner(r)
+	inMap := false
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if inMap {
+			if line != "" {
+				cfg.Rows = append(cfg.Rows, line)
+			}
+			continue
+		}
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "map" {
+			inMap = true
+			continue
+		}
+		if err := parseMapSetting(&cfg, line); err != nil {
+			return cfg, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cfg, err
+	}
+	if !inMap {
+		return cfg, fmt.Errorf("missing \"map\" line")
+	}
+	return cfg, nil
+}
+
+// LoadMapGridworld reads the map file at path and returns the MapGridworld it describes.
+func LoadMapGridworld(path string, rng *mathlib.Random) (Environment, error) {
+	cfg, err := LoadMapConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMapGridworld(cfg, rng)
+}
+
+// LoadMapConfig reads the map file at path.
+func LoadMapConfig(path string) (MapConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return MapConfig{}, err
+	}
+	defer file.Close()
+	return ParseMapConfig(file)
+}
+
+func parseMapSetting(cfg *MapConfig, line string) error {
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected \"key = value\", got %q", line)
+	}
+	key := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+
+	if strings.HasPrefix(key, "reward ") {
+		symbol := []rune(strings.TrimSpace(strings.TrimPrefix(key, "reward ")))
+		if len(symbol) != 1 {
+			return fmt.Errorf("reward needs a single cell symbol, got %q", string(symbol))
+		}
+		reward, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		cfg.Rewards[symbol[0]] = reward
+		return nil
+	}
+
+	var err error
+	switch key {
+	case "terminal":
+		cfg.Terminal = map[rune]bool{}
+		for _, c := range value {
+			if c != ' ' && c != ',' {
+				cfg.Terminal[c] = true
+			}
+		}
+	case "gamma":
+		cfg.Gamma, err = strconv.ParseFloat(value, 64)
+	case "horizon", "timeout_reward":
+		return fmt.Errorf("%s is no longer a map setting; cut episodes off with the time_limit wrapper and its penalty", key)
+	case "episodes":
+		cfg.MaxEps, err = strconv.Atoi(value)
+	case "stay":
+		cfg.Stay, err = strconv.ParseFloat(value, 64)
+	case "veer_right":
+		cfg.VeerRight, err = strconv.ParseFloat(value, 64)
+	case "veer_left":
+		cfg.VeerLeft, err = strconv.ParseFloat(value, 64)
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return err
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *MapGridworld) GetMaxEps() int {
+	return env.cfg.MaxEps
+}
+
+// GetStateDim returns the dimension (length) of state vectors, which is the number of non-wall cells.
+func (env *MapGridworld) GetStateDim() int {
+	return env.numStates
+}
+
+// GetNumActions returns |\mathcal A|: up, right, down and left.
+func (env *MapGridworld) GetNumActions() int {
+	return 4
+}
+
+// GetGamma returns \gamma
+func (env *MapGridworld) GetGamma() float64 {
+	return env.cfg.Gamma
+}
+
+func (env *MapGridworld) cell(x int, y int) rune {
+	return env.cells[y][x]
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *MapGridworld) Transition(a int, r *mathlib.Random) float64 {
+	// Check if we should transition to s_infty.
+	if env.cfg.Terminal[env.cell(env.x, env.y)] {
+		env.tas = true
+		return 0.0
+	}
+
+	// Apply the "stay" and "veer" noise to get the effective action
+	effectiveAction := a
+	temp := r.Float64()
+	if temp <= env.cfg.Stay {
+		effectiveAction = -1
+	} else if temp <= env.cfg.Stay+env.cfg.VeerRight {
+		effectiveAction = (effectiveAction + 1) % 4
+	} else if temp <= env.cfg.Stay+env.cfg.VeerRight+env.cfg.VeerLeft {
+		effectiveAction = (effectiveAction + 3) % 4
+	}
+
+	// Move if the resulting position is on the map and not a wall
+	xPrime, yPrime := gridStep(env.x, env.y, effectiveAction)
+	if xPrime >= 0 && yPrime >= 0 && xPrime < env.width && yPrime < env.height && env.index[yPrime][xPrime] >= 0 {
+		env.x = xPrime
+		env.y = yPrime
+	}
+
+	c := env.cell(env.x, env.y)
+	if env.cfg.Terminal[c] {
+		env.tas = true
+	}
+	return env.cfg.Rewards[c]
+}
+
+// gridStep returns the position after moving from (x,y) with action a (0 up, 1 right, 2 down, 3 left).
+// Any other action stays put.
+func gridStep(x int, y int, a int) (int, int) {
+	switch a {
+	case 0:
+		return x, y - 1
+	case 1:
+		return x + 1, y
+	case 2:
+		return x, y + 1
+	case 3:
+		return x - 1, y
+	}
+	return x, y
+}
+
+// GetState returns the current state of the environment
+func (env *MapGridworld) GetState() []float64 {
+	if env.tas {
+		panic("GetState called when in TAS.")
+	}
+	return mathlib.ToOneHot(env.index[env.y][env.x], env.GetStateDim())
+}
+
+// InTAS returns whether the current state is Terminal Absorbing State (TAS).
+func (env *MapGridworld) InTAS() bool {
+	return env.tas || env.cfg.Terminal[env.cell(env.x, env.y)]
+}
+
+// NewEpisode resets the environment to start a new episode at a uniformly random start cell.
+func (env *MapGridworld) NewEpisode(rng *mathlib.Random) {
+	start := env.starts[0]
+	if len(env.starts) > 1 {
+		start = env.starts[int(rng.Float64()*float64(len(env.starts)))]
+	}
+	env.x = start[0]
+	env.y = start[1]
+	env.tas = false
+}
+
+// GoalCandidates returns the (x,y) of every open or goal cell, i.e. where SetGoal may put the goal.
+// Cells with another symbol, like water, are left out, so moving the goal never loses them.
+func (env *MapGridworld) GoalCandidates() [][2]int {
+	var candidates [][2]int
+	for y := 0; y < env.height; y++ {
+		for x := 0; x < env.width; x++ {
+			if c := env.cells[y][x]; c == OpenCell || c == GoalCell {
+				candidates = append(candidates, [2]int{x, y})
+			}
+		}
+	}
+	return candidates
+}
+
+// SetGoal moves the goal: every goal cell becomes an open cell and (x,y), which must be an open or
+// goal cell, becomes the only goal cell.
+func (env *MapGridworld) SetGoal(x int, y int) {
+	if c := env.cells[y][x]; c != OpenCell && c != GoalCell {
+		panic("The goal can only be put on an open cell.")
+	}
+	for row := range env.cells {
+		for col := range env.cells[row] {
+			if env.cells[row][col] == GoalCell {
+				env.cells[row][col] = OpenCell
+			}
+		}
+	}
+	env.cells[y][x] = GoalCell
+}
+
+// SetSlip changes the probabilities that the agent stays or veers.
+func (env *MapGridworld) SetSlip(stay float64, veerRight float64, veerLeft float64) {
+	if stay+veerRight+veerLeft > 1 {
+		panic("Slip probabilities sum to more than one.")
+	}
+	env.cfg.Stay = stay
+	env.cfg.VeerRight = veerRight
+	env.cfg.VeerLeft = veerLeft
+}
+
+// mapGridworldSnapshot is the part of a MapGridworld that SetGoal and SetSlip change.
+type mapGridworldSnapshot struct {
+	Rows      []string `json:"rows"`
+	Stay      float64  `json:"stay"`
+	VeerRight float64  `json:"veer_right"`
+	VeerLeft  float64  `json:"veer_left"`
+}
+
+// Snapshot returns the map and the slip probabilities, which may have been changed by SetGoal and SetSlip.
+func (env *MapGridworld) Snapshot() ([]byte, error) {
+	rows := make([]string, env.height)
+	for y := range rows {
+		rows[y] = string(env.cells[y])
+	}
+	return json.Marshal(mapGridworldSnapshot{Rows: rows, Stay: env.cfg.Stay, VeerRight: env.cfg.VeerRight, VeerLeft: env.cfg.VeerLeft})
+}
+
+// Restore sets the map and the slip probabilities to a snapshot. The map may only differ from the
+// current one in where the goal is.
+func (env *MapGridworld) Restore(data []byte) error {
+	snap := mapGridworldSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if len(snap.Rows) != env.height {
+		return fmt.Errorf("snapshot map has %d rows, expected %d", len(snap.Rows), env.height)
+	}
+	cells := make([][]rune, env.height)
+	for y, row := range snap.Rows {
+		cells[y] = []rune(row)
+		if len(cells[y]) != env.width {
+			return fmt.Errorf("snapshot map row %d has %d cells, expected %d", y, len(cells[y]), env.width)
+		}
+		for x, c := range cells[y] {
+			// SetGoal only swaps open and goal cells, so every other cell must be as it was
+			old := env.cells[y][x]
+			if c != old && !((old == OpenCell || old == GoalCell) && (c == OpenCell || c == GoalCell)) {
+				return fmt.Errorf("snapshot map differs at (%d,%d) by more than the goal", x, y)
+			}
+		}
+	}
+	if snap.Stay+snap.VeerRight+snap.VeerLeft > 1 {
+		return fmt.Errorf("slip probabilities sum to more than one")
+	}
+	env.cells = cells
+	env.cfg.Stay, env.cfg.VeerRight, env.cfg.VeerLeft = snap.Stay, snap.VeerRight, snap.VeerLeft
+	return nil
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapGridworldMatchesGridworld(t *testing.T) {
+	mapEnv, err := ParseMapGridworld(strings.NewReader(DefaultGridworldMap), mathlib.NewRandom(0))
+	assert.NoError(t, err)
+	gridEnv := NewGridworld(mathlib.NewRandom(0))
+
+	assert.Equal(t, gridEnv.GetStateDim(), mapEnv.GetStateDim())
+	assert.Equal(t, gridEnv.GetNumActions(), mapEnv.GetNumActions())
+	assert.Equal(t, gridEnv.GetGamma(), mapEnv.GetGamma())
+	assert.Equal(t, gridEnv.GetMaxEps(), mapEnv.GetMaxEps())
+
+	// Identical random streams and actions should produce identical trajectories
+	mapRng := mathlib.NewRandom(7)
+	gridRng := mathlib.NewRandom(7)
+	actions := mathlib.NewRandom(8)
+	for ep := 0; ep < 20; ep++ {
+		mapEnv.NewEpisode(mapRng)
+		gridEnv.NewEpisode(gridRng)
+		for !gridEnv.InTAS() {
+			assert.Equal(t, gridEnv.GetState(), mapEnv.GetState())
+			action := int(actions.Float64() * 4)
+			assert.Equal(t, gridEnv.Transition(action, gridRng), mapEnv.Transition(action, mapRng))
+			assert.Equal(t, gridEnv.InTAS(), mapEnv.InTAS())
+		}
+	}
+}
+
+func TestMapGridworldFiles(t *testing.T) {
+	paths, err := filepath.Glob("../maps/*.txt")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, paths)
+	for _, path := range paths {
+		_, err := LoadMapGridworld(path, mathlib.NewRandom(0))
+		assert.NoError(t, err, path)
+	}
+}
+
+func TestMapGridworldSettings(t *testing.T) {
+	m := `gamma = 0.5   # Trailing comment
+episodes = 7
+stay = 1
+veer_right = 0
+veer_left = 0
+reward x = 2
+map
+S#
+xG
+`
+	env, err := ParseMapGridworld(strings.NewReader(m), mathlib.NewRandom(0))
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, env.GetGamma())
+	assert.Equal(t, 7, env.GetMaxEps())
+	assert.Equal(t, 3, env.GetStateDim())
+
+	// The agent always stays, so the episode never ends
+	r := mathlib.NewRandom(0)
+	for step := 0; step < 10; step++ {
+		assert.Equal(t, 0.0, env.Transition(2, r))
+	}
+	assert.False(t, env.InTAS())
+}
+
+func TestMapGridworldErrors(t *testing.T) {
+	bad := []string{
+		"map\n...\n",                            // No start cell
+		"map\nS..\n..\n",                        // Ragged rows
+		"gamma 0.9\nmap\nS\n",                   // Missing '='
+		"colour = red\nmap\nS\n",                // Unknown setting
+		"horizon = 100\nmap\nS\n",               // Time limits are a wrapper
+		"stay = 0.9\nveer_left = 0.2\nmap\nS\n", // Probabilities above one
+		"S..\n",                                 // Missing map line
+	}
+	for _, m := range bad {
+		_, err := ParseMapGridworld(strings.NewReader(m), mathlib.NewRandom(0))
+		assert.Error(t, err, m)
+	}
+}
+
+func TestWallSensingGridworld(t *testing.T) {
+	cfg, err := ParseMapConfig(strings.NewReader(DefaultGridworldMap))
+	assert.NoError(t, err)
+	env, err := NewWallSensingGridworld(cfg, mathlib.NewRandom(0))
+	assert.NoError(t, err)
+	assert.Equal(t, 16, env.GetStateDim())
+
+	// The top-left corner has walls above and to the left
+	assert.Equal(t, 1|8, mathlib.FromOneHot(env.GetState()))
+
+	// Left of the obstacle the wall is to the right
+	grid := env.(*WallSensingGridworld)
+	grid.x, grid.y = 1, 2
+	assert.Equal(t, 2, mathlib.FromOneHot(env.GetState()))
+}
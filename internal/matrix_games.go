@@ -0,0 +1,122 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// MatrixGame is an iterated two-player normal-form game. Each round both players pick one of the
+// game's actions and receive the payoffs of the joint action. Each player observes the one-hot
+// encoding of the previous round's joint action, from its own point of view (own action first),
+// or a start state in the first round. The game is truncated after the set number of rounds.
+type MatrixGame struct {
+	payoffs    [][][2]float64 // payoffs[a0][a1] = rewards of players 0 and 1
+	numActions int
+	rounds     int
+	gamma      float64
+
+	t         int // Rounds played this episode
+	last      [2]int
+	truncated bool
+}
+
+// NewMatrixGame returns an iterated game with the passed payoffs, where payoffs[a0][a1] are the
+// rewards of players 0 and 1 when they play a0 and a1.
+func NewMatrixGame(payoffs [][][2]float64, rounds int, gamma float64) MultiAgentEnvironment {
+	for _, row := range payoffs {
+		if len(row) != len(payoffs) {
+			panic("MatrixGame payoffs must be square.")
+		}
+	}
+	env := MatrixGame{payoffs: payoffs, numActions: len(payoffs), rounds: rounds, gamma: gamma}
+	env.NewEpisode(nil)
+	return &env
+}
+
+// NewPrisonersDilemma returns the iterated Prisoner's Dilemma with actions cooperate (0) and
+// defect (1) and the payoffs of Axelrod (1984): 3 each for mutual cooperation, 1 each for mutual
+// defection, and 5 and 0 when one defects on the other.
+func NewPrisonersDilemma(rounds int) MultiAgentEnvironment {
+	return NewMatrixGame([][][2]float64{
+		{{3, 3}, {0, 5}},
+		{{5, 0}, {1, 1}},
+	}, rounds, 0.95)
+}
+
+// NewStagHunt returns the iterated Stag Hunt with actions stag (0) and hare (1). Hunting hare is
+// safe and always pays 3, while hunting stag pays 4 only if the other player also hunts stag.
+func NewStagHunt(rounds int) MultiAgentEnvironment {
+	return NewMatrixGame([][][2]float64{
+		{{4, 4}, {0, 3}},
+		{{3, 0}, {3, 3}},
+	}, rounds, 0.95)
+}
+
+// NewRockPaperScissors returns iterated Rock-Paper-Scissors with actions rock (0), paper (1) and
+// scissors (2). The winner of a round gets +1 and the loser -1. No strategy beats every other, so
+// populations evolving against each other tend to cycle.
+func NewRockPaperScissors(rounds int) MultiAgentEnvironment {
+	return NewMatrixGame([][][2]float64{
+		{{0, 0}, {-1, 1}, {1, -1}},
+		{{1, -1}, {0, 0}, {-1, 1}},
+		{{-1, 1}, {1, -1}, {0, 0}},
+	}, rounds, 1)
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *MatrixGame) GetMaxEps() int {
+	return 1000
+}
+
+// GetNumAgents returns the number of players.
+func (env *MatrixGame) GetNumAgents() int {
+	return 2
+}
+
+// GetStateDim returns the number of joint actions plus the start state.
+func (env *MatrixGame) GetStateDim() int {
+	return env.numActions*env.numActions + 1
+}
+
+// GetNumActions returns the number of actions of each player.
+func (env *MatrixGame) GetNumActions() int {
+	return env.numActions
+}
+
+// GetGamma returns \gamma
+func (env *MatrixGame) GetGamma() float64 {
+	return env.gamma
+}
+
+// Transition plays one round and returns the payoffs.
+func (env *MatrixGame) Transition(actions []int, rng *mathlib.Random) []float64 {
+	payoff := env.payoffs[actions[0]][actions[1]]
+	env.last = [2]int{actions[0], actions[1]}
+	env.t++
+	if env.t == env.rounds {
+		env.truncated = true
+	}
+	return []float64{payoff[0], payoff[1]}
+}
+
+// GetState returns the one-hot encoding of the previous joint action from player i's point of view.
+func (env *MatrixGame) GetState(i int) []float64 {
+	if env.t == 0 {
+		return mathlib.ToOneHot(env.numActions*env.numActions, env.GetStateDim())
+	}
+	own, other := env.last[i], env.last[1-i]
+	return mathlib.ToOneHot(own*env.numActions+other, env.GetStateDim())
+}
+
+// InTAS returns whether all rounds have been played.
+func (env *MatrixGame) InTAS() bool {
+	return env.truncated
+}
+
+// Truncated returns whether all rounds have been played, which is the only way the game ends.
+func (env *MatrixGame) Truncated() bool {
+	return env.truncated
+}
+
+// NewEpisode starts a new game.
+func (env *MatrixGame) NewEpisode(rng *mathlib.Random) {
+	env.t = 0
+	env.truncated = false
+}
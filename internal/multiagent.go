@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// MultiAgentEnvironment is an environment where GetNumAgents agents, one per seat, act
+// simultaneously and each receives its own reward. Every agent observes one-hot vectors of length
+// GetStateDim and has GetNumActions discrete actions.
+type MultiAgentEnvironment interface {
+	// How many episodes should be run?
+	GetMaxEps() int
+	// This function returns the number of agents (seats).
+	GetNumAgents() int
+	// This function returns the dimension (length) of each agent's observation vectors.
+	GetStateDim() int
+	// This function returns the number of actions of each agent.
+	GetNumActions() int
+	// This function returns \gamma
+	GetGamma() float64
+	// This function applies the joint action, with actions[i] the action of agent i. It returns the reward of every agent.
+	Transition(actions []int, rng *mathlib.Random) []float64
+	// This function returns the observation of agent i.
+	GetState(i int) []float64
+	// Check if the episode is over, as for Environment. Multi-agent environments may also implement Truncator.
+	InTAS() bool
+	// This function resets the environment to start a new episode (it samples the state from the initial state distribution).
+	NewEpisode(rng *mathlib.Random)
+}
+
+// CheckMultiAgentSpaces returns an error if any of the agents cannot learn in its seat of env.
+func CheckMultiAgentSpaces(agents []Agent, env MultiAgentEnvironment) error {
+	if len(agents) != env.GetNumAgents() {
+		return fmt.Errorf("environment has %d seats but got %d agents", env.GetNumAgents(), len(agents))
+	}
+	obs, act := NewOneHotSpace(env.GetStateDim()), NewDiscreteSpace(env.GetNumActions())
+	for i, agt := range agents {
+		if err := agt.Supports(obs, act); err != nil {
+			return fmt.Errorf("agent %d does not support observations %v and actions %v: %v", i, obs, act, err)
+		}
+	}
+	return nil
+}
+
+// RunMultiAgentEpisode runs one episode with agents[i] in seat i and returns the return of every
+// agent. Each agent learns independently, treating the others as part of its environment.
+func RunMultiAgentEpisode(
+	agents []Agent,
+	env MultiAgentEnvironment,
+	gamma float64,
+	rng *mathlib.Random,
+) []float64 {
+	n := len(agents)
+
+	// Prepare objects
+	env.NewEpisode(rng)
+	curStates := make([][]float64, n)
+	curActions := make([]int, n)
+	for i, agt := range agents {
+		agt.NewEpisode()
+		curStates[i] = env.GetState(i)
+		curActions[i] = agt.GetAction(curStates[i], rng)
+	}
+
+	result := make([]float64, n)
+	curGamma := 1.0
+
+	// Loop over time
+	for {
+		rewards := env.Transition(curActions, rng)
+		for i := range result {
+			result[i] += curGamma * rewards[i]
+		}
+		curGamma *= gamma
+
+		// Check if the episode is over
+		if env.InTAS() {
+			tr, ok := env.(Truncator)
+			truncated := ok && tr.Truncated()
+			for i, agt := range agents {
+				if truncated {
+					agt.TruncatedUpdate(curStates[i], curActions[i], rewards[i], env.GetState(i), rng)
+				} else {
+					agt.LastUpdate(curStates[i], curActions[i], rewards[i], rng)
+				}
+			}
+			break
+		}
+
+		// Every agent chooses its next action from its own observation
+		newActions := make([]int, n)
+		for i, agt := range agents {
+			newState := env.GetState(i)
+			if agt.UpdateBeforeNextAction() {
+				agt.UpdateSARS(curStates[i], curActions[i], rewards[i], newState, rng)
+				newActions[i] = agt.GetAction(newState, rng)
+			} else {
+				newActions[i] = agt.GetAction(newState, rng)
+				agt.UpdateSARSA(curStates[i], curActions[i], rewards[i], newState, newActions[i], rng)
+			}
+			curStates[i] = newState
+		}
+		curActions = newActions
+	}
+	return result
+}
+
+// RunMultiAgentEnvironment resets the agents and runs them for numEps episodes. It returns the
+// matrix of returns, where result(i,j) = the return of agent i on the j'th episode.
+func RunMultiAgentEnvironment(
+	agents []Agent,
+	env MultiAgentEnvironment,
+	numEps int,
+	gamma float64,
+	rng *mathlib.Random,
+) [][]float64 {
+	// Wipe the agents to start a new trial
+	for _, agt := range agents {
+		agt.Reset(rng)
+	}
+
+	result := mathlib.Matrix(len(agents), numEps, 0)
+	for epCount := 0; epCount < numEps; epCount++ {
+		returns := RunMultiAgentEpisode(agents, env, gamma, rng)
+		for i := range agents {
+			result[i][epCount] = returns[i]
+		}
+	}
+	return result
+}
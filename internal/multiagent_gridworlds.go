@@ -0,0 +1,288 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// boundedStep moves (x,y) one cell in direction a (as in gridStep) unless that leaves the size x size grid.
+func boundedStep(x int, y int, a int, size int) (int, int) {
+	nx, ny := gridStep(x, y, a)
+	if nx < 0 || ny < 0 || nx >= size || ny >= size {
+		return x, y
+	}
+	return nx, ny
+}
+
+// TwoAgentGridworld is a 5x5 gridworld where two agents cross paths to reach their own goals.
+// Agent 0 starts in the top left and heads for the bottom right, and agent 1 starts in the top
+// right and heads for the bottom left. Actions are up (0), right (1), down (2) and left (3).
+// Every step costs -1 until an agent reaches its goal, where it stays. If the agents would move
+// into the same cell or swap cells they collide: neither moves and both receive an extra -10.
+// Each agent observes the one-hot encoding of (own cell, other agent's cell). The episode ends
+// when both agents are at their goals and is truncated after 100 steps.
+type TwoAgentGridworld struct {
+	pos       [2][2]int // (x,y) of each agent
+	t         int       // Time into the episode
+	truncated bool
+}
+
+// Constants of the TwoAgentGridworld.
+const (
+	twoAgentSize            = 5
+	twoAgentCollisionReward = -10.0
+	twoAgentHorizon         = 100
+)
+
+// twoAgentStarts and twoAgentGoals are the start and goal cells of each agent.
+var (
+	twoAgentStarts = [2][2]int{{0, 0}, {twoAgentSize - 1, 0}}
+	twoAgentGoals  = [2][2]int{{twoAgentSize - 1, twoAgentSize - 1}, {0, twoAgentSize - 1}}
+)
+
+// NewTwoAgentGridworld returns a new TwoAgentGridworld MultiAgentEnvironment object.
+func NewTwoAgentGridworld(rng *mathlib.Random) MultiAgentEnvironment {
+	env := TwoAgentGridworld{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *TwoAgentGridworld) GetMaxEps() int {
+	return 1000
+}
+
+// GetNumAgents returns the number of agents.
+func (env *TwoAgentGridworld) GetNumAgents() int {
+	return 2
+}
+
+// GetStateDim returns the number of (own cell, other cell) pairs.
+func (env *TwoAgentGridworld) GetStateDim() int {
+	return twoAgentSize * twoAgentSize * twoAgentSize * twoAgentSize
+}
+
+// GetNumActions returns the number of moves.
+func (env *TwoAgentGridworld) GetNumActions() int {
+	return 4
+}
+
+// GetGamma returns \gamma
+func (env *TwoAgentGridworld) GetGamma() float64 {
+	return 0.9
+}
+
+// atGoal returns whether agent i is at its goal.
+func (env *TwoAgentGridworld) atGoal(i int) bool {
+	return env.pos[i] == twoAgentGoals[i]
+}
+
+// Transition moves both agents and returns their rewards.
+func (env *TwoAgentGridworld) Transition(actions []int, rng *mathlib.Random) []float64 {
+	rewards := make([]float64, 2)
+	var next [2][2]int
+	for i := 0; i < 2; i++ {
+		next[i] = env.pos[i]
+		if !env.atGoal(i) {
+			next[i][0], next[i][1] = boundedStep(env.pos[i][0], env.pos[i][1], actions[i], twoAgentSize)
+			rewards[i] = -1
+		}
+	}
+
+	collided := next[0] == next[1] || (next[0] == env.pos[1] && next[1] == env.pos[0])
+	if collided {
+		for i := 0; i < 2; i++ {
+			if next[i] != env.pos[i] {
+				rewards[i] += twoAgentCollisionReward
+			}
+		}
+	} else {
+		env.pos = next
+	}
+
+	env.t++
+	if env.t == twoAgentHorizon && !env.InTAS() {
+		env.truncated = true
+	}
+	return rewards
+}
+
+// cellIndex returns the index of the cell of agent i.
+func (env *TwoAgentGridworld) cellIndex(i int) int {
+	return env.pos[i][1]*twoAgentSize + env.pos[i][0]
+}
+
+// GetState returns the one-hot encoding of (own cell, other agent's cell) for agent i.
+func (env *TwoAgentGridworld) GetState(i int) []float64 {
+	cells := twoAgentSize * twoAgentSize
+	return mathlib.ToOneHotMixedRadix([]int{env.cellIndex(i), env.cellIndex(1 - i)}, []int{cells, cells})
+}
+
+// InTAS returns whether both agents are at their goals or the episode was truncated.
+func (env *TwoAgentGridworld) InTAS() bool {
+	return (env.atGoal(0) && env.atGoal(1)) || env.truncated
+}
+
+// Truncated returns whether the episode ran out of time.
+func (env *TwoAgentGridworld) Truncated() bool {
+	return env.truncated
+}
+
+// NewEpisode puts both agents back at their starts.
+func (env *TwoAgentGridworld) NewEpisode(rng *mathlib.Random) {
+	env.pos = twoAgentStarts
+	env.t = 0
+	env.truncated = false
+}
+
+// PredatorPrey is the pursuit task of Tan (1993) on a size x size grid. Predators, one per seat,
+// chase a prey that moves at random. Actions are up (0), right (1), down (2), left (3) and stay (4).
+// Every step costs each predator -1. When a predator moves onto the prey it is caught, every
+// predator receives +10 and the episode ends. Otherwise the prey then moves to a random
+// neighbouring cell without a predator, or stays. Each predator observes the one-hot encoding of
+// (own cell, the other predators' cells in seat order, the prey's cell). The episode is
+// truncated after 100 steps.
+type PredatorPrey struct {
+	size      int
+	predators [][2]int // (x,y) of each predator
+	prey      [2]int
+	caught    bool
+	t         int // Time into the episode
+	truncated bool
+	numCells  int
+}
+
+// Constants of PredatorPrey.
+const (
+	predatorPreyCaptureReward = 10.0
+	predatorPreyHorizon       = 100
+)
+
+// NewPredatorPrey returns a new PredatorPrey MultiAgentEnvironment with numPredators predators on a size x size grid.
+func NewPredatorPrey(size int, numPredators int, rng *mathlib.Random) MultiAgentEnvironment {
+	if numPredators < 1 || numPredators >= size*size {
+		panic("PredatorPrey needs at least one predator and room for the prey.")
+	}
+	env := PredatorPrey{size: size, predators: make([][2]int, numPredators), numCells: size * size}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *PredatorPrey) GetMaxEps() int {
+	return 1000
+}
+
+// GetNumAgents returns the number of predators.
+func (env *PredatorPrey) GetNumAgents() int {
+	return len(env.predators)
+}
+
+// GetStateDim returns the number of joint positions of the predators and the prey.
+func (env *PredatorPrey) GetStateDim() int {
+	return mathlib.MixedRadixCapacity(env.radices())
+}
+
+// radices returns the number of cells once for each predator and once for the prey.
+func (env *PredatorPrey) radices() []int {
+	radices := make([]int, len(env.predators)+1)
+	for i := range radices {
+		radices[i] = env.numCells
+	}
+	return radices
+}
+
+// GetNumActions returns the four moves and staying.
+func (env *PredatorPrey) GetNumActions() int {
+	return 5
+}
+
+// GetGamma returns \gamma
+func (env *PredatorPrey) GetGamma() float64 {
+	return 0.95
+}
+
+// occupied returns whether a predator is at cell c.
+func (env *PredatorPrey) occupied(c [2]int) bool {
+	for _, p := range env.predators {
+		if p == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the predators, then the prey if it was not caught, and returns the predators' rewards.
+func (env *PredatorPrey) Transition(actions []int, rng *mathlib.Random) []float64 {
+	rewards := mathlib.Vector(len(env.predators), -1)
+	for i, a := range actions {
+		env.predators[i][0], env.predators[i][1] = boundedStep(env.predators[i][0], env.predators[i][1], a, env.size)
+	}
+
+	if env.occupied(env.prey) {
+		env.caught = true
+		for i := range rewards {
+			rewards[i] += predatorPreyCaptureReward
+		}
+		return rewards
+	}
+
+	// The prey moves to a uniformly random free neighbouring cell, or stays
+	var moves [][2]int
+	for a := 0; a < 5; a++ {
+		var c [2]int
+		c[0], c[1] = boundedStep(env.prey[0], env.prey[1], a, env.size)
+		if !env.occupied(c) {
+			moves = append(moves, c)
+		}
+	}
+	env.prey = moves[int(rng.Float64()*float64(len(moves)))]
+
+	env.t++
+	if env.t == predatorPreyHorizon {
+		env.truncated = true
+	}
+	return rewards
+}
+
+// cellIndex returns the index of cell c.
+func (env *PredatorPrey) cellIndex(c [2]int) int {
+	return c[1]*env.size + c[0]
+}
+
+// GetState returns the one-hot encoding of (own cell, other predators' cells, prey cell) for predator i.
+func (env *PredatorPrey) GetState(i int) []float64 {
+	digits := []int{env.cellIndex(env.predators[i])}
+	for j, p := range env.predators {
+		if j != i {
+			digits = append(digits, env.cellIndex(p))
+		}
+	}
+	digits = append(digits, env.cellIndex(env.prey))
+	return mathlib.ToOneHotMixedRadix(digits, env.radices())
+}
+
+// InTAS returns whether the prey was caught or the episode was truncated.
+func (env *PredatorPrey) InTAS() bool {
+	return env.caught || env.truncated
+}
+
+// Truncated returns whether the episode ran out of time.
+func (env *PredatorPrey) Truncated() bool {
+	return env.truncated
+}
+
+// NewEpisode places the predators and the prey in uniformly random cells, with the prey apart from the predators.
+func (env *PredatorPrey) NewEpisode(rng *mathlib.Random) {
+	for i := range env.predators {
+		c := int(rng.Float64() * float64(env.numCells))
+		env.predators[i] = [2]int{c % env.size, c / env.size}
+	}
+	for {
+		c := int(rng.Float64() * float64(env.numCells))
+		env.prey = [2]int{c % env.size, c / env.size}
+		if !env.occupied(env.prey) {
+			break
+		}
+	}
+	env.caught = false
+	env.t = 0
+	env.truncated = false
+}
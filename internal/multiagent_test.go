@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrisonersDilemma(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	game := NewPrisonersDilemma(3)
+	assert.Equal(t, 5, game.GetStateDim())
+	assert.Equal(t, 4, mathlib.FromOneHot(game.GetState(0)))
+
+	assert.Equal(t, []float64{0, 5}, game.Transition([]int{0, 1}, r))
+	// Each player sees its own action first
+	assert.Equal(t, 1, mathlib.FromOneHot(game.GetState(0)))
+	assert.Equal(t, 2, mathlib.FromOneHot(game.GetState(1)))
+
+	game.Transition([]int{1, 1}, r)
+	assert.False(t, game.InTAS())
+	game.Transition([]int{0, 0}, r)
+	assert.True(t, game.InTAS())
+	assert.True(t, game.(Truncator).Truncated())
+}
+
+func TestTwoAgentGridworldCollisions(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	grid := NewTwoAgentGridworld(r).(*TwoAgentGridworld)
+
+	// Moving towards each other along the top row until they would swap cells
+	grid.pos = [2][2]int{{1, 0}, {2, 0}}
+	rewards := grid.Transition([]int{1, 3}, r)
+	assert.Equal(t, []float64{-11, -11}, rewards)
+	assert.Equal(t, [2][2]int{{1, 0}, {2, 0}}, grid.pos)
+
+	// Moving into the same cell
+	grid.pos = [2][2]int{{1, 0}, {3, 0}}
+	grid.Transition([]int{1, 3}, r)
+	assert.Equal(t, [2][2]int{{1, 0}, {3, 0}}, grid.pos)
+
+	// An agent at its goal stays there and is no longer charged
+	grid.pos = [2][2]int{twoAgentGoals[0], {0, 0}}
+	rewards = grid.Transition([]int{0, 2}, r)
+	assert.Equal(t, []float64{0, -1}, rewards)
+	assert.Equal(t, twoAgentGoals[0], grid.pos[0])
+}
+
+func TestPredatorPrey(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	game := NewPredatorPrey(3, 2, r).(*PredatorPrey)
+	assert.Equal(t, 9*9*9, game.GetStateDim())
+
+	game.predators = [][2]int{{0, 0}, {2, 2}}
+	game.prey = [2]int{1, 0}
+	assert.Equal(t, []int{0, 8, 1}, mathlib.FromOneHotMixedRadix(game.GetState(0), game.radices()))
+	assert.Equal(t, []int{8, 0, 1}, mathlib.FromOneHotMixedRadix(game.GetState(1), game.radices()))
+
+	rewards := game.Transition([]int{1, 4}, r)
+	assert.Equal(t, []float64{9, 9}, rewards)
+	assert.True(t, game.InTAS())
+	assert.False(t, game.Truncated())
+}
+
+func TestIndependentLearners(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	env := NewTwoAgentGridworld(r)
+	agents := make([]Agent, env.GetNumAgents())
+	for i := range agents {
+		agents[i] = NewSarsa(env.GetStateDim(), env.GetNumActions(), env.GetGamma(), 0.5, 0, NewEpsilonGreedy(ConstantSchedule(0.1)))
+	}
+	assert.NoError(t, CheckMultiAgentSpaces(agents, env))
+	assert.Error(t, CheckMultiAgentSpaces(agents[:1], env))
+
+	returns := RunMultiAgentEnvironment(agents, env, 300, env.GetGamma(), r)
+	assert.Len(t, returns, 2)
+	for i := range agents {
+		assert.Len(t, returns[i], 300)
+		assert.True(t, mathlib.Mean(returns[i][250:]) > mathlib.Mean(returns[i][:50]))
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// Pig is the solitaire version of the dice game Pig. Each turn the player rolls a die until they hold
+// (1), banking the turn total, or roll a 1, losing it. Rolling (0) any other face adds it to the turn
+// total. The game ends as soon as the banked score plus the turn total reaches the target. Every turn
+// costs -1, so the return is minus the number of turns taken. The state is (score, turn total).
+type Pig struct {
+	target    int
+	score     int
+	turnTotal int
+}
+
+// NewPig returns a new Pig Environment object played to the passed target score.
+func NewPig(target int, rng *mathlib.Random) Environment {
+	if target < 2 {
+		panic("Pig needs a target of at least two.")
+	}
+	env := Pig{target: target}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *Pig) GetMaxEps() int {
+	return 5000
+}
+
+// radices returns the sizes of the (score, turn total) state.
+func (env *Pig) radices() []int {
+	return []int{env.target, env.target}
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *Pig) GetStateDim() int {
+	return mathlib.MixedRadixCapacity(env.radices())
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *Pig) GetNumActions() int {
+	return 2
+}
+
+// GetGamma returns \gamma
+func (env *Pig) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *Pig) Transition(a int, rng *mathlib.Random) float64 {
+	if a == 1 {
+		env.score += env.turnTotal
+		env.turnTotal = 0
+		return -1.0
+	}
+	face := int(rng.Float64()*6) + 1
+	if face == 1 {
+		env.turnTotal = 0
+		return -1.0
+	}
+	env.turnTotal += face
+	if env.InTAS() {
+		// Winning ends the final turn
+		return -1.0
+	}
+	return 0.0
+}
+
+// GetState returns the one-hot encoding of (score, turn total).
+func (env *Pig) GetState() []float64 {
+	return mathlib.ToOneHotMixedRadix([]int{env.score, env.turnTotal}, env.radices())
+}
+
+// InTAS returns whether the target has been reached.
+func (env *Pig) InTAS() bool {
+	return env.score+env.turnTotal >= env.target
+}
+
+// NewEpisode starts a new game.
+func (env *Pig) NewEpisode(rng *mathlib.Random) {
+	env.score = 0
+	env.turnTotal = 0
+}
@@ -0,0 +1,187 @@
+// Package plot renders learning curves from the returns files in data/ as SVG, so results can be
+// looked at without MATLAB.
+package plot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Series is a learning curve: the mean return of every episode and its standard error.
+type Series struct {
+	Name  string
+	Mean  []float64
+	Error []float64
+}
+
+// ReadReturns reads a returns file written by internal.RunTrials. The series is named after the
+// file, without the directory and the _out.csv suffix.
+func ReadReturns(path string) (Series, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Series{}, err
+	}
+	defer file.Close()
+
+	s := Series{Name: strings.TrimSuffix(filepath.Base(path), "_out.csv")}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if lineNum == 1 {
+			continue // Header
+		}
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 2 {
+			return Series{}, fmt.Errorf("%s:%d: expected a return and an error", path, lineNum)
+		}
+		mean, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return Series{}, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		stderr, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return Series{}, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		s.Mean = append(s.Mean, mean)
+		s.Error = append(s.Error, stderr)
+	}
+	return s, scanner.Err()
+}
+
+// Smooth returns the series averaged over trailing windows of the passed number of episodes.
+func Smooth(s Series, window int) Series {
+	if window <= 1 {
+		return s
+	}
+	smoothed := Series{Name: s.Name, Mean: make([]float64, len(s.Mean)), Error: make([]float64, len(s.Error))}
+	for i := range s.Mean {
+		start := i - window + 1
+		if start < 0 {
+			start = 0
+		}
+		for j := start; j <= i; j++ {
+			smoothed.Mean[i] += s.Mean[j]
+			smoothed.Error[i] += s.Error[j]
+		}
+		smoothed.Mean[i] /= float64(i - start + 1)
+		smoothed.Error[i] /= float64(i - start + 1)
+	}
+	return smoothed
+}
+
+// Size and margins of the plot in pixels.
+const (
+	width        = 800
+	height       = 500
+	marginLeft   = 70
+	marginRight  = 160
+	marginTop    = 40
+	marginBottom = 50
+	numTicks     = 5
+)
+
+// colors are the line colors of the series, in order.
+var colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// bounds returns the ranges of the episodes and of the mean returns plus or minus their errors.
+// Errors that are not numbers (e.g. of a single trial) are ignored.
+func bounds(series []Series) (int, float64, float64) {
+	numEps := 0
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		if len(s.Mean) > numEps {
+			numEps = len(s.Mean)
+		}
+		for i, m := range s.Mean {
+			e := errorAt(s, i)
+			low = math.Min(low, m-e)
+			high = math.Max(high, m+e)
+		}
+	}
+	if numEps == 0 {
+		return 1, 0, 1
+	}
+	if low == high {
+		low, high = low-1, high+1
+	}
+	return numEps, low, high
+}
+
+// errorAt returns the error of episode i, or 0 if it is not a number.
+func errorAt(s Series, i int) float64 {
+	if i >= len(s.Error) || math.IsNaN(s.Error[i]) || math.IsInf(s.Error[i], 0) {
+		return 0
+	}
+	return s.Error[i]
+}
+
+// WriteSVG draws the series as lines over shaded bands of one standard error, with the episode on
+// the x axis and the average return on the y axis, as plotResults.m does.
+func WriteSVG(w io.Writer, title string, series []Series) error {
+	numEps, low, high := bounds(series)
+	plotWidth := float64(width - marginLeft - marginRight)
+	plotHeight := float64(height - marginTop - marginBottom)
+	x := func(ep int) float64 {
+		if numEps == 1 {
+			return marginLeft
+		}
+		return marginLeft + plotWidth*float64(ep)/float64(numEps-1)
+	}
+	y := func(r float64) float64 {
+		return marginTop + plotHeight*(high-r)/(high-low)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="24" font-size="16" text-anchor="middle">%s</text>`+"\n", (width-marginRight+marginLeft)/2, escape(title))
+
+	// Axes and ticks
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%g" height="%g" fill="none" stroke="black"/>`+"\n", marginLeft, marginTop, plotWidth, plotHeight)
+	for i := 0; i <= numTicks; i++ {
+		ep := (numEps - 1) * i / numTicks
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%d</text>`+"\n", x(ep), height-marginBottom+18, ep)
+		r := low + (high-low)*float64(i)/numTicks
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.3g</text>`+"\n", marginLeft-6, y(r)+4, r)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", marginLeft, y(r), marginLeft+plotWidth, y(r))
+	}
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">Episode</text>`+"\n", marginLeft+plotWidth/2, height-10)
+	fmt.Fprintf(&b, `<text transform="translate(16,%.1f) rotate(-90)" text-anchor="middle">Average Return</text>`+"\n", marginTop+plotHeight/2)
+
+	for k, s := range series {
+		color := colors[k%len(colors)]
+
+		// Error band: along the top, then back along the bottom
+		var band, line []string
+		for i, m := range s.Mean {
+			band = append(band, fmt.Sprintf("%.1f,%.1f", x(i), y(m+errorAt(s, i))))
+			line = append(line, fmt.Sprintf("%.1f,%.1f", x(i), y(m)))
+		}
+		for i := len(s.Mean) - 1; i >= 0; i-- {
+			band = append(band, fmt.Sprintf("%.1f,%.1f", x(i), y(s.Mean[i]-errorAt(s, i))))
+		}
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s" fill-opacity="0.2" stroke="none"/>`+"\n", strings.Join(band, " "), color)
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", strings.Join(line, " "), color)
+
+		// Legend entry
+		ly := marginTop + 10 + 20*k
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="3"/>`+"\n", width-marginRight+15, ly, width-marginRight+35, ly, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", width-marginRight+40, ly+4, escape(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escape escapes text for use in SVG.
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
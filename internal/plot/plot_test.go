@@ -0,0 +1,50 @@
+package plot
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReturns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sarsa_out.csv")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("Returns, Error\n1,0.5\n2,NaN\n"), 0644))
+	s, err := ReadReturns(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "sarsa", s.Name)
+	assert.Equal(t, []float64{1, 2}, s.Mean)
+	assert.True(t, math.IsNaN(s.Error[1]))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("Returns, Error\n1\n"), 0644))
+	_, err = ReadReturns(path)
+	assert.Error(t, err)
+}
+
+func TestSmooth(t *testing.T) {
+	s := Smooth(Series{Mean: []float64{0, 2, 4, 6}, Error: []float64{1, 1, 1, 1}}, 2)
+	assert.Equal(t, []float64{0, 1, 3, 5}, s.Mean)
+	assert.Equal(t, []float64{1, 1, 1, 1}, s.Error)
+}
+
+func TestWriteSVG(t *testing.T) {
+	var b strings.Builder
+	series := []Series{
+		{Name: "a<b", Mean: []float64{0, 1, 2}, Error: []float64{0.1, math.NaN(), 0.1}},
+		{Name: "flat", Mean: []float64{1, 1}, Error: []float64{0, 0}},
+	}
+	assert.NoError(t, WriteSVG(&b, "Test", series))
+	svg := b.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Equal(t, 2, strings.Count(svg, "<polyline"))
+	assert.Contains(t, svg, "a&lt;b")
+	assert.NotContains(t, svg, "NaN")
+}
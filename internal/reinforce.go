@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"encoding/json"
 	"math"
 
 	"github.com/jackkenney/evolve-rl/mathlib"
@@ -16,12 +17,16 @@ type REINFORCE struct {
 
 	theta [][]float64 // The current best policy we have found
 	alpha float64
+
+	policy *Softmax // Selects actions from the action preferences
 }
 
-// NewREINFORCE returns an initialized REINFORCE object.
-func NewREINFORCE(stateDim int, numActions int, gamma float64, alpha float64) Agent {
+// NewREINFORCE returns an initialized REINFORCE object that selects actions with a softmax of the
+// passed temperature, since the update follows the gradient of the log of its probabilities.
+func NewREINFORCE(stateDim int, numActions int, gamma float64, alpha float64, temperature float64) Agent {
 	agt := REINFORCE{}
 	agt.alpha = alpha
+	agt.policy = NewSoftmax(temperature).(*Softmax)
 
 	agt.ep = NewEpisodeTracker(1)
 	agt.numStates = stateDim
@@ -44,39 +49,65 @@ func (agt *REINFORCE) EpisodicAgent() bool {
 	return true
 }
 
-// GetAction returns the action that the agent selects from the state.
+// GetAction returns the action selected by the exploration policy from the action preferences of the state.
 func (agt *REINFORCE) GetAction(s []float64, rng *mathlib.Random) int {
 	// Convert the one-hot state into an integer from 0 - (numStates-1)
 	state := mathlib.FromOneHot(s)
-	// Get the action probabilities from theta, using softmax action selection.
-	actionProbabilities := agt.theta[state]
-	denominator := 0.0
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] = math.Exp(actionProbabilities[a])
-		denominator += actionProbabilities[a]
-	}
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] /= denominator
-	}
-	// Select random action from softmax
-	temp := rng.Float64()
-	sum := 0.0
-	for a := 0; a < agt.numActions; a++ {
-		sum += actionProbabilities[a]
-		if temp <= sum {
-			return a // The function will return 'a'. This stops the for loop and returns from the function.
-		}
-	}
-	return agt.numActions - 1 // Rounding error
+	return agt.policy.SelectAction(state, agt.theta[state], rng)
 }
 
 // NewEpisode tells the agent that it is at the start of a new episode.
 func (agt *REINFORCE) NewEpisode() {}
 
+// Supports returns an error unless observations are one-hot states and actions are discrete.
+func (agt *REINFORCE) Supports(observation Space, action Space) error {
+	return supportsTabular(agt.numStates, agt.numActions, observation, action)
+}
+
 // Reset the agent entirely - to a blank slate prior to learning
 func (agt *REINFORCE) Reset(rng *mathlib.Random) {
 	mathlib.ResetMat(&agt.theta, 0.0)
 	agt.ep.Wipe()
+	agt.policy.Reset()
+}
+
+// reinforceSnapshot is the learned state of a REINFORCE agent.
+type reinforceSnapshot struct {
+	Theta   [][]float64     `json:"theta"`
+	Tracker json.RawMessage `json:"tracker"`
+	Policy  json.RawMessage `json:"policy,omitempty"`
+}
+
+// Snapshot returns the action preferences, the episode tracker and the state of the exploration policy.
+func (agt *REINFORCE) Snapshot() ([]byte, error) {
+	tracker, err := agt.ep.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := SnapshotOf(agt.policy)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(reinforceSnapshot{Theta: agt.theta, Tracker: tracker, Policy: policy})
+}
+
+// Restore sets the action preferences, the episode tracker and the state of the exploration policy to a snapshot.
+func (agt *REINFORCE) Restore(data []byte) error {
+	snap := reinforceSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkMatrix("theta", snap.Theta, agt.numStates, agt.numActions); err != nil {
+		return err
+	}
+	if err := agt.ep.Restore(snap.Tracker); err != nil {
+		return err
+	}
+	if err := RestoreSnapshot(agt.policy, snap.Policy); err != nil {
+		return err
+	}
+	agt.theta = snap.Theta
+	return nil
 }
 
 // UpdateSARS is unimplemented for this class.
@@ -98,6 +129,11 @@ func (agt *REINFORCE) LastUpdate(s []float64, a int, r float64, rng *mathlib.Ran
 	}
 }
 
+// TruncatedUpdate ends the episode like LastUpdate. Monte Carlo returns can't bootstrap from sPrime.
+func (agt *REINFORCE) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.LastUpdate(s, a, r, rng)
+}
+
 func (agt *REINFORCE) episodeLimitReached(rng *mathlib.Random) {
 	agt.episodicUpdate(rng)
 	agt.ep.Wipe()
@@ -113,7 +149,7 @@ func (agt *REINFORCE) episodicUpdate(rng *mathlib.Random) {
 	L := len(agt.ep.rewards[0])
 	G := 0.0
 
-	var piS, state []float64
+	var piS, state []float64 // pi(s,.) and the one-hot state
 
 	for t := 0; t < L; t++ {
 		state = agt.ep.states[0][t]
@@ -124,13 +160,13 @@ func (agt *REINFORCE) episodicUpdate(rng *mathlib.Random) {
 		for k := t; k < L; k++ {
 			G += math.Pow(agt.gamma, float64(k-t)) * agt.ep.rewards[0][k]
 		}
-		piS = mathlib.ExpVec(agt.theta[s])
-		piS = mathlib.ScalarDivideVec(piS, mathlib.Sum(piS))
+		piS = agt.policy.Probabilities(s, agt.theta[s])
 
-		gradientEstimate[s][a] += G * (1.0 - piS[a])
+		// The gradient of ln pi(s,a) with respect to theta[s][aPrime] is (1{a=aPrime} - pi(s,aPrime)) / temperature
+		gradientEstimate[s][a] += G * (1.0 - piS[a]) / agt.policy.temperature
 		for aPrime := 0; aPrime < agt.numActions; aPrime++ {
 			if aPrime != a {
-				gradientEstimate[s][aPrime] += G * (-piS[aPrime])
+				gradientEstimate[s][aPrime] += G * (-piS[aPrime]) / agt.policy.temperature
 			}
 		}
 	}
@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREINFORCEGradient(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	agt := NewREINFORCE(1, 2, 1, 0.1, 2).(*REINFORCE)
+	agt.Reset(rng)
+
+	// One step with return 1 from uniform preferences moves them by alpha (1{a=b} - 1/2) / temperature
+	agt.NewEpisode()
+	agt.LastUpdate([]float64{1}, 0, 1, rng)
+	assert.InDelta(t, 0.1*0.5/2, agt.theta[0][0], 1e-12)
+	assert.InDelta(t, -0.1*0.5/2, agt.theta[0][1], 1e-12)
+}
@@ -0,0 +1,196 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/bridge"
+)
+
+// Client starts sessions on a Server.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient returns a client of the server at baseURL (e.g. "http://localhost:8080") that gives up
+// on any call that takes longer than timeout. A timeout of 0 means no timeout.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{baseURL: baseURL, http: &http.Client{Timeout: timeout}}
+}
+
+// inProcess is an http.RoundTripper that calls a handler directly instead of using the network.
+type inProcess struct {
+	handler http.Handler
+}
+
+// RoundTrip serves the request with the handler.
+func (t inProcess) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := responseBuffer{header: http.Header{}}
+	t.handler.ServeHTTP(&w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          ioutil.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// responseBuffer is an http.ResponseWriter that keeps the response in memory.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the header of the response.
+func (w *responseBuffer) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sets the status of the response, unless it was already set.
+func (w *responseBuffer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write appends data to the body of the response.
+func (w *responseBuffer) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+// NewInProcessClient returns a client that calls the handler, usually a Server, in this process.
+// It is a stand-in for a remote server that behaves the same way without a network.
+func NewInProcessClient(handler http.Handler) *Client {
+	return &Client{baseURL: "http://in-process", http: &http.Client{Transport: inProcess{handler}}}
+}
+
+// post sends body as JSON and decodes the JSON response into result. Non-2xx responses are errors.
+func (c *Client) post(path string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.baseURL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, result)
+}
+
+// decode reads the JSON body of resp into result, or returns the error of a failed request.
+func decode(resp *http.Response, result interface{}) error {
+	if resp.StatusCode/100 != 2 {
+		var failure bridge.Response
+		if json.NewDecoder(resp.Body).Decode(&failure) == nil && failure.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, failure.Error)
+		}
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Dial starts a session and returns the Environment of it.
+func (c *Client) Dial() (*Environment, error) {
+	var created createResponse
+	if err := c.post(sessionsPath, struct{}{}, &created); err != nil {
+		return nil, err
+	}
+	env := Environment{client: c, path: sessionsPath + "/" + created.ID}
+	var err error
+	if env.Environment, err = bridge.NewTransportEnvironment("remote", env.call, env.deleteSession); err != nil {
+		env.deleteSession()
+		return nil, err
+	}
+	return &env, nil
+}
+
+// Constructor returns an environment constructor for internal.RunTrials that dials a new session
+// for every trial. A failed dial is reported by the Err method of the returned Environment.
+func (c *Client) Constructor() func() internal.Environment {
+	return func() internal.Environment {
+		env, err := c.Dial()
+		if err != nil {
+			return &Environment{Environment: bridge.NewFailedEnvironment(err)}
+		}
+		return env
+	}
+}
+
+// LatencyStats summarizes the round-trip times of the calls made by an Environment.
+type LatencyStats struct {
+	Calls int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the mean round-trip time.
+func (l LatencyStats) Mean() time.Duration {
+	if l.Calls == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Calls)
+}
+
+// Environment is an internal.Environment hosted by a Server. It is a bridge.Environment whose
+// messages go over HTTP, so failures are kept and reported by Err in the same way, and it also
+// records the latency of its calls.
+type Environment struct {
+	*bridge.Environment
+	client  *Client
+	path    string // Path of the session
+	latency LatencyStats
+}
+
+// call sends a request to the session and records its latency.
+func (env *Environment) call(req bridge.Request) (bridge.Response, error) {
+	var resp bridge.Response
+	start := time.Now()
+	err := env.client.post(env.path, req, &resp)
+	elapsed := time.Since(start)
+	env.latency.Calls++
+	env.latency.Total += elapsed
+	if elapsed > env.latency.Max {
+		env.latency.Max = elapsed
+	}
+	return resp, err
+}
+
+// Latency returns the round-trip times of the calls so far.
+func (env *Environment) Latency() LatencyStats {
+	return env.latency
+}
+
+// deleteSession stops the session on the server.
+func (env *Environment) deleteSession() error {
+	req, err := http.NewRequest(http.MethodDelete, env.client.baseURL+env.path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := env.client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/bridge"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGridworldServer() *Server {
+	return NewServer(func() internal.Environment {
+		return internal.NewGridworld(mathlib.NewRandom(0))
+	})
+}
+
+func TestLoopback(t *testing.T) {
+	srv := httptest.NewServer(newGridworldServer())
+	defer srv.Close()
+
+	env, err := NewClient(srv.URL, time.Second).Dial()
+	assert.NoError(t, err)
+	assert.Equal(t, 23, env.GetStateDim())
+	assert.Equal(t, 4, env.GetNumActions())
+	assert.NoError(t, bridge.CheckConformance(env, 10, mathlib.NewRandom(1)))
+	assert.NoError(t, env.Err())
+	assert.True(t, env.Latency().Calls > 10)
+	assert.True(t, env.Latency().Max >= env.Latency().Mean())
+	assert.NoError(t, env.Close())
+
+	// The session is gone
+	env.Transition(0, nil)
+	assert.Error(t, env.Err())
+}
+
+func TestInProcessMatchesLocal(t *testing.T) {
+	env, err := NewInProcessClient(newGridworldServer()).Dial()
+	assert.NoError(t, err)
+	local := internal.NewGridworld(mathlib.NewRandom(0))
+
+	clientRng, seedRng, actionRng := mathlib.NewRandom(2), mathlib.NewRandom(2), mathlib.NewRandom(3)
+	for ep := 0; ep < 5; ep++ {
+		env.NewEpisode(clientRng)
+		serverRng := mathlib.NewRandom(int64(seedRng.Float64() * (1 << 53)))
+		local.NewEpisode(serverRng)
+		for !local.InTAS() {
+			assert.Equal(t, local.GetState(), env.GetState())
+			a := int(actionRng.Float64() * 4)
+			assert.Equal(t, local.Transition(a, serverRng), env.Transition(a, nil))
+		}
+		assert.True(t, env.InTAS())
+	}
+	assert.NoError(t, env.Err())
+}
+
+func TestFailures(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	srv := httptest.NewServer(newGridworldServer())
+	env, err := NewClient(srv.URL, time.Second).Dial()
+	assert.NoError(t, err)
+	env.NewEpisode(rng)
+
+	// The server goes away mid-episode: the episode ends and the error is kept
+	srv.Close()
+	assert.Equal(t, 0.0, env.Transition(0, rng))
+	assert.Error(t, env.Err())
+	assert.True(t, env.InTAS())
+	assert.False(t, env.Truncated())
+	assert.Len(t, env.GetState(), 23)
+
+	// An agent finishes its episodes without panicking
+	agt := internal.NewSarsa(23, 4, 0.9, 0.1, 0, internal.NewSoftmax(1))
+	assert.NotPanics(t, func() { internal.RunAgentEnvironment(agt, env, 3, 0.9, rng) })
+
+	// Slow servers time out
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	_, err = NewClient(slow.URL, 10*time.Millisecond).Dial()
+	assert.Error(t, err)
+
+	// Bad requests are errors, not panics
+	env, err = NewInProcessClient(newGridworldServer()).Dial()
+	assert.NoError(t, err)
+	env.Transition(0, rng)
+	assert.Contains(t, env.Err().Error(), "step before reset")
+}
+
+func TestRunTrialsReportsFailures(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	srv := httptest.NewServer(newGridworldServer())
+	srv.Close()
+	agt := func() internal.Agent {
+		return internal.NewSarsa(23, 4, 0.9, 0.1, 0, internal.NewSoftmax(1))
+	}
+	err := internal.RunTrials(rng, agt, NewClient(srv.URL, time.Second).Constructor(), 2, "remote")
+	assert.Error(t, err)
+}
+
+func TestRunTrialsClosesSessions(t *testing.T) {
+	srv := newGridworldServer()
+	agt := func() internal.Agent {
+		return internal.NewSarsa(23, 4, 0.9, 0.1, 0, internal.NewSoftmax(1))
+	}
+	cfg := internal.TrialConfig{NumTrials: 3, NumEps: 2}
+	_, err := internal.RunTrialsReturns(context.Background(), mathlib.NewRandom(0), agt, NewInProcessClient(srv).Constructor(), cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, srv.sessions)
+}
+
+// closingGridworld is a gridworld that counts how many times it is closed.
+type closingGridworld struct {
+	internal.Environment
+	closed *int32
+}
+
+func (env closingGridworld) Close() error {
+	atomic.AddInt32(env.closed, 1)
+	return nil
+}
+
+func TestDeleteClosesEnvironment(t *testing.T) {
+	var closed int32
+	srv := NewServer(func() internal.Environment {
+		return closingGridworld{Environment: internal.NewGridworld(mathlib.NewRandom(0)), closed: &closed}
+	})
+	env, err := NewInProcessClient(srv).Dial()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&closed))
+	assert.NoError(t, env.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&closed))
+	assert.Empty(t, srv.sessions)
+}
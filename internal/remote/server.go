@@ -0,0 +1,115 @@
+// Package remote serves environments over HTTP and provides a client Environment for them, so
+// trials can step environments hosted in another process. Messages are the JSON requests and
+// responses of package bridge:
+//
+//	POST   /sessions        starts a new environment and returns {"id":"..."}
+//	POST   /sessions/{id}   sends a bridge.Request to it and returns the bridge.Response
+//	DELETE /sessions/{id}   stops it, closing the environment if it is an io.Closer
+package remote
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/internal/bridge"
+)
+
+// sessionsPath is the path of the session collection.
+const sessionsPath = "/sessions"
+
+// createResponse is the body of the response to a new session.
+type createResponse struct {
+	ID string `json:"id"`
+}
+
+// session is a bridge.Session that can be used by concurrent requests.
+type session struct {
+	mu  sync.Mutex
+	s   *bridge.Session
+	env internal.Environment
+}
+
+// Server is an http.Handler that runs a new environment for every session.
+type Server struct {
+	newEnv   func() internal.Environment
+	mu       sync.Mutex
+	sessions map[string]*session
+	nextID   int
+}
+
+// NewServer returns a Server whose sessions run environments made by newEnv.
+func NewServer(newEnv func() internal.Environment) *Server {
+	return &Server{newEnv: newEnv, sessions: map[string]*session{}}
+}
+
+// writeJSON writes v as the body of a response with the passed status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ServeHTTP routes session requests.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == sessionsPath {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, bridge.Response{Error: "use POST to start a session"})
+			return
+		}
+		srv.mu.Lock()
+		srv.nextID++
+		id := strconv.Itoa(srv.nextID)
+		env := srv.newEnv()
+		srv.sessions[id] = &session{s: bridge.NewSession(env), env: env}
+		srv.mu.Unlock()
+		writeJSON(w, http.StatusCreated, createResponse{ID: id})
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, sessionsPath+"/")
+	srv.mu.Lock()
+	sess, ok := srv.sessions[id]
+	if ok && r.Method == http.MethodDelete {
+		delete(srv.sessions, id)
+	}
+	srv.mu.Unlock()
+	if !ok || !strings.HasPrefix(r.URL.Path, sessionsPath+"/") {
+		writeJSON(w, http.StatusNotFound, bridge.Response{Error: "no session " + id})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		// A request still being answered finishes before the environment is closed
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if closer, ok := sess.env.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				writeJSON(w, http.StatusInternalServerError, bridge.Response{Error: "close: " + err.Error()})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		var req bridge.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, bridge.Response{Error: "bad request: " + err.Error()})
+			return
+		}
+		sess.mu.Lock()
+		resp := sess.s.Handle(req)
+		sess.mu.Unlock()
+		status := http.StatusOK
+		if resp.Error != "" {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, resp)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, bridge.Response{Error: "use POST or DELETE on a session"})
+	}
+}
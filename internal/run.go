@@ -1,8 +1,13 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"strconv"
 	"sync"
 
@@ -10,15 +15,46 @@ import (
 )
 
 // RunEpisode calculates the return from the episode of running the agent in the environment.
+// The episode runs until env.InTAS(), so environments that may never terminate should be wrapped
+// in a wrappers.TimeLimit.
 func RunEpisode(
 	agt Agent,
 	env Environment,
 	gamma float64,
 	rng *mathlib.Random,
 ) float64 {
+	result, _ := runEpisode(context.Background(), agt, env, gamma, streams{agent: rng, env: rng})
+	return result
+}
+
+// streams are the random number generators of the agent and of the environment in a trial.
+// Separate streams keep the environment's randomness the same when the agent changes.
+type streams struct {
+	agent *mathlib.Random
+	env   *mathlib.Random
+}
+
+// splitStreams splits new agent and environment streams off rng.
+func splitStreams(rng *mathlib.Random) streams {
+	return streams{agent: rng.Split(), env: rng.Split()}
+}
+
+// interruptSteps is the number of steps between checks for cancellation in the middle of an episode.
+const interruptSteps = 1000
+
+// runEpisode is RunEpisode with the agent and the environment drawing from their own streams. If
+// ctx is cancelled, the episode is abandoned within interruptSteps steps, and it returns false.
+// The agent has then learned from part of the episode.
+func runEpisode(
+	ctx context.Context,
+	agt Agent,
+	env Environment,
+	gamma float64,
+	rngs streams,
+) (float64, bool) {
 
 	// Prepare objects
-	env.NewEpisode(rng)
+	env.NewEpisode(rngs.env)
 	agt.NewEpisode()
 
 	// Create variables that we will use
@@ -30,17 +66,25 @@ func RunEpisode(
 	result = 0
 	curGamma = 1
 	curState = env.GetState()
-	curAction = agt.GetAction(curState, rng)
+	curAction = agt.GetAction(curState, rngs.agent)
 
 	// Loop over time
-	for t := 0; true; t++ {
-		reward = env.Transition(curAction, rng)
+	for t := 1; ; t++ {
+		if t%interruptSteps == 0 && ctx.Err() != nil {
+			return result, false
+		}
+		reward = env.Transition(curAction, rngs.env)
 		result += curGamma * reward
 		curGamma *= gamma
 
-		// Check if in the terminal absorbing state
+		// Check if the episode is over. A truncated episode did not reach the terminal absorbing
+		// state, so the agent may still bootstrap from the final state.
 		if env.InTAS() {
-			agt.LastUpdate(curState, curAction, reward, rng)
+			if tr, ok := env.(Truncator); ok && tr.Truncated() {
+				agt.TruncatedUpdate(curState, curAction, reward, env.GetState(), rngs.agent)
+			} else {
+				agt.LastUpdate(curState, curAction, reward, rngs.agent)
+			}
 			break
 		}
 
@@ -48,24 +92,23 @@ func RunEpisode(
 
 		// Check if we should update before computing the next action
 		if agt.UpdateBeforeNextAction() {
-			agt.UpdateSARS(curState, curAction, reward, newState, rng)
-			newAction = agt.GetAction(newState, rng)
+			agt.UpdateSARS(curState, curAction, reward, newState, rngs.agent)
+			newAction = agt.GetAction(newState, rngs.agent)
 		} else {
-			newAction = agt.GetAction(newState, rng)
-			agt.UpdateSARSA(curState, curAction, reward, newState, newAction, rng)
+			newAction = agt.GetAction(newState, rngs.agent)
+			agt.UpdateSARSA(curState, curAction, reward, newState, newAction, rngs.agent)
 		}
 
 		// Prepare for the next iteration of the t-loop, where "new" variables will be the "cur" variables.
 		curAction = newAction
 		curState = newState
-		if t > 10 {
-			break
-		}
 	}
-	return result
+	return result, true
 }
 
-// RunAgentEnvironment runs the passed agent in the environment passed and returns the
+// RunAgentEnvironment runs the passed agent in the environment passed for numEps episodes, starting
+// from a Reset, and returns the return of every episode. A Trial can instead be run a few episodes
+// at a time.
 func RunAgentEnvironment(
 	agt Agent,
 	env Environment,
@@ -73,62 +116,397 @@ func RunAgentEnvironment(
 	gamma float64,
 	rng *mathlib.Random,
 ) []float64 {
+	result, _ := runAgentEnvironment(context.Background(), agt, env, numEps, gamma, streams{agent: rng, env: rng})
+	return result
+}
+
+// runAgentEnvironment is RunAgentEnvironment with the agent and the environment drawing from their
+// own streams. If ctx is cancelled, it returns the returns of the finished episodes with ctx's error.
+func runAgentEnvironment(
+	ctx context.Context,
+	agt Agent,
+	env Environment,
+	numEps int,
+	gamma float64,
+	rngs streams,
+) ([]float64, error) {
 
 	// Wipe the agent to start a new trial
-	agt.Reset(rng)
+	agt.Reset(rngs.agent)
+
+	result, _, err := runEpisodes(ctx, agt, env, make([]float64, 0, numEps), numEps, gamma, rngs)
+	return result, err
+}
+
+// runEpisodes carries on training the agent, appending the return of every episode to result until
+// it holds numEps. If ctx is cancelled, it returns the returns so far with ctx's error, and whether
+// it abandoned an episode in the middle, as runEpisode does; the abandoned episode has no return.
+func runEpisodes(
+	ctx context.Context,
+	agt Agent,
+	env Environment,
+	result []float64,
+	numEps int,
+	gamma float64,
+	rngs streams,
+) ([]float64, bool, error) {
 
-	result := make([]float64, numEps)
 	// Loop over episodes
-	for epCount := 0; epCount < numEps; epCount++ {
-		result[epCount] = RunEpisode(agt, env, gamma, rng)
+	for len(result) < numEps {
+		if err := ctx.Err(); err != nil {
+			return result, false, err
+		}
+		ret, finished := runEpisode(ctx, agt, env, gamma, rngs)
+		if !finished {
+			return result, true, ctx.Err()
+		}
+		result = append(result, ret)
 	}
 
 	// Return the "result" variable, holding the returns from each episode.
-	return result
+	return result, false, nil
 }
 
 type agentConstructor func() Agent
 type environmentConstructor func() Environment
 
-// RunTrials runs them in parallel using constructors passed as arguments
+// TrialConfig says how many trials RunTrialsWithConfig runs and where it writes the results.
+type TrialConfig struct {
+	NumTrials   int
+	NumEps      int    // Episodes per trial, or 0 for the environment's GetMaxEps
+	Parallelism int    // Trials run at once, or 0 for one per CPU
+	OutputDir   string // Directory of the returns file, "data" if empty
+	FileName    string // The returns are written to <OutputDir>/<FileName>_out.csv
+
+	// Checkpoint is the file the progress of the trials is saved to, or "" for no checkpoints. If
+	// the file exists, the run resumes from it. It is kept when the run finishes, so running again
+	// only rewrites the returns; delete it to start over.
+	Checkpoint      string
+	CheckpointEvery int // Episodes between saves of a trial's progress, 0 to save only finished trials
+
+	// Fingerprint identifies the seed and hyperparameters of the run, e.g. with a hash of them. It
+	// is saved in the checkpoint, and a checkpoint with another fingerprint is refused, so that a
+	// leftover checkpoint is not resumed by a different run.
+	Fingerprint string
+
+	Log io.Writer // Where the progress of the trials is written, or nil to write nothing
+}
+
+// RunTrials runs them in parallel using constructors passed as arguments. It returns an error
+// before running anything if the agent does not support the environment's spaces.
+//
+// The agents and environments are constructed one trial at a time, in order, and every trial's
+// agent and environment draw from their own streams split off rng. The results therefore depend
+// only on rng's seed, not on how the trials are scheduled, as long as the constructors only draw
+// random numbers from rng while they run.
+//
+// A trial fails if it panics or if its environment is an ErrorReporter that failed. The other
+// trials carry on, and the returns of those that finish are written before a *TrialsError reports
+// the failures.
 func RunTrials(rng *mathlib.Random,
 	agentConstructor agentConstructor,
 	envConstructor environmentConstructor,
 	numTrials int,
 	fileName string,
-) {
+) error {
+	return RunTrialsWithConfig(rng, agentConstructor, envConstructor, TrialConfig{NumTrials: numTrials, FileName: fileName})
+}
 
-	// Create objects we will use
-	env := envConstructor()
+// RunTrialsWithConfig is RunTrials with the number of episodes, the parallelism and the output
+// directory configurable.
+func RunTrialsWithConfig(rng *mathlib.Random,
+	agentConstructor agentConstructor,
+	envConstructor environmentConstructor,
+	cfg TrialConfig,
+) error {
+	return RunTrialsContext(context.Background(), rng, agentConstructor, envConstructor, cfg)
+}
+
+// RunTrialsContext is RunTrialsWithConfig that stops when ctx is cancelled, e.g. on Ctrl-C. Running
+// trials stop after their current episode and trials that have not started are skipped. The
+// returns of the trials that finished are written, and with a checkpoint the progress of the others
+// is saved, so the run can be resumed.
+func RunTrialsContext(ctx context.Context,
+	rng *mathlib.Random,
+	agentConstructor agentConstructor,
+	envConstructor environmentConstructor,
+	cfg TrialConfig,
+) error {
+	returns, errs, changes, err := runTrials(ctx, rng, agentConstructor, envConstructor, cfg)
+	if err != nil {
+		return err
+	}
+	if err := writeFinishedChangePoints(changes, errs, changePointsPath(cfg.OutputDir, cfg.FileName)); err != nil {
+		return err
+	}
+	return writeFinishedReturns(returns, errs, outputPath(cfg.OutputDir, cfg.FileName))
+}
+
+// RunTrialsReturns is RunTrialsContext that returns the returns of every trial instead of writing
+// their mean, so returns(i,j) = the return on the j'th episode of the i'th trial. cfg.OutputDir and
+// cfg.FileName are not used. The returns of trials that did not finish are nil, and a *TrialsError
+// says why.
+func RunTrialsReturns(ctx context.Context,
+	rng *mathlib.Random,
+	agentConstructor agentConstructor,
+	envConstructor environmentConstructor,
+	cfg TrialConfig,
+) ([][]float64, error) {
+	returns, errs, _, err := runTrials(ctx, rng, agentConstructor, envConstructor, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if trialsErr := newTrialsError(errs); trialsErr != nil {
+		for i, err := range errs {
+			if err != nil {
+				returns[i] = nil
+			}
+		}
+		return returns, trialsErr
+	}
+	return returns, nil
+}
+
+// runTrials runs the trials of RunTrialsContext and returns the returns, the error and the change
+// points of the environment of each. It returns an error before running anything if the run can't start.
+func runTrials(ctx context.Context,
+	rng *mathlib.Random,
+	agentConstructor agentConstructor,
+	envConstructor environmentConstructor,
+	cfg TrialConfig,
+) ([][]float64, []error, [][]ChangePoint, error) {
 
 	// Get environment settings
-	numEps := env.GetMaxEps()
-	gamma := env.GetGamma()
+	numEps, gamma, err := probe(agentConstructor, envConstructor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cfg.NumEps > 0 {
+		numEps = cfg.NumEps
+	}
+
+	var ckpt *checkpointer
+	if cfg.Checkpoint != "" {
+		if ckpt, err = loadCheckpointer(cfg.Checkpoint, cfg.CheckpointEvery, cfg.Fingerprint, cfg.NumTrials, numEps); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	changes := make([][]ChangePoint, cfg.NumTrials)
+	returns, errs := runParallelTrials(ctx, cfg.NumTrials, cfg.Parallelism, cfg.Log, func(i int) func() ([]float64, error) {
+		// Finished trials are still constructed on resume, so later trials get the same streams
+		env, agt, rngs := envConstructor(), agentConstructor(), splitStreams(rng)
+		return func() ([]float64, error) {
+			defer closeEnvironment(env)
+			var result []float64
+			var err error
+			if ckpt != nil {
+				result, err = runCheckpointedTrial(ctx, ckpt, i, agt, env, numEps, gamma, rngs)
+			} else {
+				result, err = runAgentEnvironment(ctx, agt, env, numEps, gamma, rngs)
+			}
+			if envErr := envError(env); envErr != nil {
+				err = envErr
+			}
+			changes[i] = ChangePointsOf(env)
+			return result, err
+		}
+	})
+	return returns, errs, changes, nil
+}
+
+// probe builds an agent and an environment to check that the agent supports the environment's
+// spaces, and returns the environment's number of episodes and discount. The environment is closed.
+func probe(agentConstructor agentConstructor, envConstructor environmentConstructor) (int, float64, error) {
+	env := envConstructor()
+	defer closeEnvironment(env)
+	if err := envError(env); err != nil {
+		return 0, 0, err
+	}
+	if err := CheckSpaces(agentConstructor(), env); err != nil {
+		return 0, 0, err
+	}
+	return env.GetMaxEps(), env.GetGamma(), nil
+}
+
+// TrialsError reports the trials of a run that failed or were stopped by cancellation.
+type TrialsError struct {
+	NumTrials int
+	Written   bool    // Were the returns of the other trials written?
+	Errors    []error // Why every trial that did not finish stopped, in the order of the trials
+}
+
+// Error summarizes the failures, giving the first in full.
+func (e *TrialsError) Error() string {
+	msg := fmt.Sprintf("%d of %d trials did not finish", len(e.Errors), e.NumTrials)
+	if e.Written {
+		msg += " (the returns of the others were written)"
+	}
+	return msg + ": " + e.Errors[0].Error()
+}
 
-	// Create a matrix to store the resulting returns. results(i,j) = the return on the j'th episode of the i'th trial.
-	var returns = mathlib.Matrix(numTrials, numEps, 0)
+// Unwrap returns the first failure, so errors.Is(err, context.Canceled) tells if a run was interrupted.
+func (e *TrialsError) Unwrap() error {
+	return e.Errors[0]
+}
 
-	fmt.Println("Starting trial 1 of ", numTrials)
+// newTrialsError returns a *TrialsError for the trials whose error is not nil, or nil if every trial finished.
+func newTrialsError(errs []error) *TrialsError {
+	trialsErr := TrialsError{NumTrials: len(errs)}
+	for i, err := range errs {
+		if err != nil {
+			trialsErr.Errors = append(trialsErr.Errors, fmt.Errorf("trial %d: %w", i+1, err))
+		}
+	}
+	if len(trialsErr.Errors) == 0 {
+		return nil
+	}
+	return &trialsErr
+}
+
+// writeFinishedReturns writes the returns of the trials whose error is nil to path, and returns a
+// *TrialsError if there are any others. Nothing is written if no trial finished.
+func writeFinishedReturns(returns [][]float64, errs []error, path string) error {
+	var finished [][]float64
+	for i, err := range errs {
+		if err == nil {
+			finished = append(finished, returns[i])
+		}
+	}
+	trialsErr := newTrialsError(errs)
+	if len(finished) > 0 {
+		if err := writeReturns(finished, path); err != nil {
+			return err
+		}
+		if trialsErr != nil {
+			trialsErr.Written = true
+		}
+	}
+	if trialsErr != nil {
+		return trialsErr
+	}
+	return nil
+}
+
+// writeFinishedChangePoints writes the change points of the trials whose error is nil to path.
+// Nothing is written if none of them has a change point.
+func writeFinishedChangePoints(changes [][]ChangePoint, errs []error, path string) error {
+	finished := make([][]ChangePoint, len(changes))
+	changed := false
+	for i, err := range errs {
+		if err == nil && len(changes[i]) > 0 {
+			finished[i] = changes[i]
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteChangePoints(file, finished); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runParallelTrials runs numTrials trials in parallel, at most parallelism at a time (one per CPU
+// if parallelism < 1), and returns the returns and the error of each, so returns(i,j) = the return
+// on the j'th episode of the i'th trial. Trial i is prepared by start(i), which is called in order
+// from a single goroutine, and then run by the function start returns. A trial that panics fails
+// with an error holding the panic and its stack, and the other trials carry on. Once ctx is
+// cancelled no more trials are started, and those not started fail with ctx's error. The starts of
+// the first and every tenth trial are written to log, if it is not nil.
+func runParallelTrials(ctx context.Context, numTrials int, parallelism int, log io.Writer, start func(i int) func() ([]float64, error)) ([][]float64, []error) {
+	returns := make([][]float64, numTrials)
+	errs := make([]error, numTrials)
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > numTrials {
+		parallelism = numTrials
+	}
+	slots := make(chan struct{}, parallelism)
+
+	logf(log, "Starting trial 1 of %d", numTrials)
 
 	var wg sync.WaitGroup
 	// Loop over trials
-	for trial := 0; trial < numTrials; trial++ {
-		if (trial+1)%10 == 0 {
-			fmt.Println("Starting trial ", trial+1, " of ", numTrials)
+	for i := 0; i < numTrials; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			for ; i < numTrials; i++ {
+				errs[i] = err
+			}
+			break
+		}
+		if (i+1)%10 == 0 {
+			logf(log, "Starting trial %d of %d", i+1, numTrials)
+		}
+
+		var trial func() ([]float64, error)
+		if errs[i] = recovered(func() { trial = start(i) }); errs[i] != nil {
+			<-slots
+			continue
 		}
 		wg.Add(1)
 		go func(i int) {
-			env := envConstructor()
-			agt := agentConstructor()
+			defer wg.Done()
+			if err := recovered(func() { returns[i], errs[i] = trial() }); err != nil {
+				errs[i] = err
+			}
+			<-slots
+		}(i)
+	}
+	wg.Wait()
+	return returns, errs
+}
 
-			returns[i] = RunAgentEnvironment(agt, env, numEps, gamma, rng)
+// logf writes a line of progress to log, if it is not nil.
+func logf(log io.Writer, format string, args ...interface{}) {
+	if log != nil {
+		fmt.Fprintf(log, format+"\n", args...)
+	}
+}
 
-			wg.Done()
-		}(trial)
+// recovered calls f and returns an error holding the panic and its stack if f panics.
+func recovered(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	f()
+	return nil
+}
+
+// outputPath returns the path of the returns file <dir>/<fileName>_out.csv, where dir defaults to data.
+func outputPath(dir string, fileName string) string {
+	if dir == "" {
+		dir = "data"
 	}
-	wg.Wait()
+	return filepath.Join(dir, fileName+"_out.csv")
+}
+
+// changePointsPath returns the path of the change points file <dir>/<fileName>_changepoints.csv,
+// where dir defaults to data.
+func changePointsPath(dir string, fileName string) string {
+	if dir == "" {
+		dir = "data"
+	}
+	return filepath.Join(dir, fileName+"_changepoints.csv")
+}
 
-	// Convert returns into a vector of mean returns and the standard error (used for error bars)
+// writeReturns writes the mean return of every episode over the trials and its standard error
+// (used for error bars) to path.
+func writeReturns(returns [][]float64, path string) error {
+	numEps := len(returns[0])
 	meanReturns := mathlib.Vector(numEps, 0)
 	stderrReturns := mathlib.Vector(numEps, 0)
 
@@ -139,9 +517,9 @@ func RunTrials(rng *mathlib.Random,
 	}
 
 	// Print the results to a file
-	file, err := os.Create("data/" + fileName + "_out.csv")
+	file, err := os.Create(path)
 	if err != nil {
-		fmt.Println(err.Error() + "\n")
+		return err
 	}
 	defer file.Close()
 	file.WriteString("Returns, Error\n")
@@ -150,4 +528,5 @@ func RunTrials(rng *mathlib.Random,
 		line = strconv.FormatFloat(meanReturns[epCount], 'g', -1, 64) + "," + strconv.FormatFloat(stderrReturns[epCount], 'g', -1, 64)
 		file.WriteString(line + "\n")
 	}
+	return nil
 }
@@ -0,0 +1,470 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingAgent always takes the same action and records which end-of-episode update RunEpisode called.
+type recordingAgent struct {
+	action          int
+	last, truncated int
+	sPrime          []float64
+}
+
+func (agt *recordingAgent) UpdateBeforeNextAction() bool                   { return false }
+func (agt *recordingAgent) GetAction(s []float64, rng *mathlib.Random) int { return agt.action }
+func (agt *recordingAgent) NewEpisode()                                    {}
+func (agt *recordingAgent) Reset(rng *mathlib.Random)                      {}
+func (agt *recordingAgent) Supports(observation Space, action Space) error { return nil }
+func (agt *recordingAgent) UpdateSARS(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+}
+func (agt *recordingAgent) UpdateSARSA(s []float64, a int, r float64, sPrime []float64, aPrime int, rng *mathlib.Random) {
+}
+func (agt *recordingAgent) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random) {
+	agt.last++
+}
+func (agt *recordingAgent) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	agt.truncated++
+	agt.sPrime = sPrime
+}
+
+// cutOffEnvironment truncates episodes after steps transitions, like wrappers.TimeLimit.
+type cutOffEnvironment struct {
+	Environment
+	steps int
+	t     int
+}
+
+func (env *cutOffEnvironment) Transition(a int, rng *mathlib.Random) float64 {
+	env.t++
+	return env.Environment.Transition(a, rng)
+}
+
+func (env *cutOffEnvironment) InTAS() bool { return env.t >= env.steps || env.Environment.InTAS() }
+
+func (env *cutOffEnvironment) Truncated() bool { return env.t >= env.steps && !env.Environment.InTAS() }
+
+func (env *cutOffEnvironment) NewEpisode(rng *mathlib.Random) {
+	env.t = 0
+	env.Environment.NewEpisode(rng)
+}
+
+func TestRunEpisodeTruncation(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	cfg := DefaultMapConfig()
+	cfg.Rows = []string{"SG"}
+	cfg.Stay, cfg.VeerRight, cfg.VeerLeft = 0, 0, 0
+	grid, err := NewMapGridworld(cfg, r)
+	assert.NoError(t, err)
+	env := &cutOffEnvironment{Environment: grid, steps: 10}
+
+	// Moving left into the edge of the map never reaches the goal, so the episode times out
+	agt := &recordingAgent{action: 3}
+	RunEpisode(agt, env, 1, r)
+	assert.Equal(t, 0, agt.last)
+	assert.Equal(t, 1, agt.truncated)
+	assert.Equal(t, env.GetState(), agt.sPrime)
+
+	// Moving right reaches the goal, which terminates the episode
+	agt.action = 1
+	RunEpisode(agt, env, 1, r)
+	assert.Equal(t, 1, agt.last)
+	assert.Equal(t, 1, agt.truncated)
+}
+
+func TestRunParallelTrialsLimit(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	var log bytes.Buffer
+	returns, errs := runParallelTrials(context.Background(), 10, 3, &log, func(i int) func() ([]float64, error) {
+		return func() ([]float64, error) {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			mu.Unlock()
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return []float64{1}, nil
+		}
+	})
+	assert.Len(t, returns, 10)
+	assert.Equal(t, make([]error, 10), errs)
+	assert.True(t, most <= 3)
+	assert.Equal(t, "Starting trial 1 of 10\nStarting trial 10 of 10\n", log.String())
+}
+
+func TestRunTrialsReproducible(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The same seed gives bit-identical returns whatever the parallelism
+	run := func(parallelism int) string {
+		rng := mathlib.NewRandom(7)
+		env := func() Environment { return NewGridworld(rng) }
+		agt := func() Agent { return NewSarsa(23, 4, 0.9, 0.1, 0, NewSoftmax(1)) }
+		cfg := TrialConfig{NumTrials: 6, NumEps: 20, Parallelism: parallelism, OutputDir: dir, FileName: "repro"}
+		assert.NoError(t, RunTrialsWithConfig(rng, agt, env, cfg))
+		out, err := ioutil.ReadFile(filepath.Join(dir, "repro_out.csv"))
+		assert.NoError(t, err)
+		return string(out)
+	}
+	first := run(1)
+	assert.Equal(t, first, run(0))
+	assert.Equal(t, first, run(3))
+
+	// Split streams are reproducible and differ from each other
+	a, b := mathlib.NewRandom(1), mathlib.NewRandom(1)
+	a1, a2, b1 := a.Split(), a.Split(), b.Split()
+	x := a1.Float64()
+	assert.Equal(t, x, b1.Float64())
+	assert.NotEqual(t, x, a2.Float64())
+	assert.NotEqual(t, x, a.Float64())
+}
+
+// interruptedAgent is a learning agent whose run is killed when it is checkpointed for the
+// (snapshots+1)'th time.
+type interruptedAgent struct {
+	Agent
+	snapshots int
+}
+
+func (agt *interruptedAgent) Snapshot() ([]byte, error) {
+	if agt.snapshots == 0 {
+		return nil, fmt.Errorf("interrupted")
+	}
+	agt.snapshots--
+	return agt.Agent.(Snapshotter).Snapshot()
+}
+
+func (agt *interruptedAgent) Restore(data []byte) error {
+	return agt.Agent.(Snapshotter).Restore(data)
+}
+
+func TestRunTrialsResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	setups := map[string]struct {
+		env func(rng *mathlib.Random) Environment
+		agt func() Agent
+	}{
+		// The episode tracker and the epsilon schedule are in the middle of a batch when interrupted
+		"bbo": {
+			env: func(rng *mathlib.Random) Environment {
+				return &cutOffEnvironment{Environment: NewGridworld(rng), steps: 100}
+			},
+			agt: func() Agent {
+				return NewTabularBBO(23, 4, 0.9, 3, NewEpsilonGreedy(LinearSchedule{Start: 1, End: 0.1, Steps: 500}))
+			},
+		},
+		// The arm means drift between episodes
+		"bandit": {
+			env: func(rng *mathlib.Random) Environment { return NewRandomWalkBandit(5, 50, 0.1) },
+			agt: func() Agent { return NewUCB1Bandit(5, 2) },
+		},
+	}
+	for name, setup := range setups {
+		run := func(fileName string, checkpoint string, interrupt bool) error {
+			rng := mathlib.NewRandom(3)
+			trial := 0
+			agt := func() Agent {
+				if !interrupt {
+					return setup.agt()
+				}
+				trial++
+				return &interruptedAgent{Agent: setup.agt(), snapshots: trial % 5}
+			}
+			env := func() Environment { return setup.env(rng) }
+			cfg := TrialConfig{NumTrials: 6, NumEps: 50, Parallelism: 2, OutputDir: dir, FileName: fileName,
+				Checkpoint: checkpoint, CheckpointEvery: 10}
+			return RunTrialsWithConfig(rng, agt, env, cfg)
+		}
+		checkpoint := filepath.Join(dir, name+"_checkpoint.json")
+		assert.NoError(t, run(name, "", false))
+		assert.Error(t, run(name+"_resumed", checkpoint, true))
+		assert.NoError(t, run(name+"_resumed", checkpoint, false))
+
+		want, err := ioutil.ReadFile(filepath.Join(dir, name+"_out.csv"))
+		assert.NoError(t, err)
+		got, err := ioutil.ReadFile(filepath.Join(dir, name+"_resumed_out.csv"))
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got), name)
+	}
+
+	// A checkpoint of a different run is refused
+	rng := mathlib.NewRandom(3)
+	cfg := TrialConfig{NumTrials: 2, NumEps: 50, OutputDir: dir, FileName: "other", Checkpoint: filepath.Join(dir, "bbo_checkpoint.json")}
+	assert.Error(t, RunTrialsWithConfig(rng, func() Agent { return NewUCB1Bandit(5, 2) },
+		func() Environment { return NewRandomWalkBandit(5, 50, 0.1) }, cfg))
+	cfg = TrialConfig{NumTrials: 6, NumEps: 50, OutputDir: dir, FileName: "other", Checkpoint: filepath.Join(dir, "bbo_checkpoint.json"),
+		Fingerprint: "seed 4"}
+	err = RunTrialsWithConfig(rng, func() Agent { return NewUCB1Bandit(5, 2) },
+		func() Environment { return NewRandomWalkBandit(5, 50, 0.1) }, cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "another seed")
+}
+
+// cancellingAgent is a learning agent that cancels the run at the start of an episode.
+type cancellingAgent struct {
+	Agent
+	episodes int // Episode that cancels the run
+	cancel   func()
+}
+
+func (agt *cancellingAgent) Snapshot() ([]byte, error) { return agt.Agent.(Snapshotter).Snapshot() }
+func (agt *cancellingAgent) Restore(data []byte) error { return agt.Agent.(Snapshotter).Restore(data) }
+
+func (agt *cancellingAgent) NewEpisode() {
+	agt.Agent.NewEpisode()
+	if agt.episodes--; agt.episodes == 0 {
+		agt.cancel()
+	}
+}
+
+// stepCancellingAgent is an agent that cancels the run when it takes its steps'th action.
+type stepCancellingAgent struct {
+	Agent
+	steps  int
+	taken  int
+	cancel func()
+}
+
+func (agt *stepCancellingAgent) GetAction(s []float64, rng *mathlib.Random) int {
+	if agt.taken++; agt.taken == agt.steps {
+		agt.cancel()
+	}
+	return agt.Agent.GetAction(s, rng)
+}
+
+func TestRunEpisodesInterrupt(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+
+	// An episode that never ends is abandoned soon after the cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	agt := &stepCancellingAgent{Agent: &recordingAgent{}, steps: 2500, cancel: cancel}
+	returns, err := runAgentEnvironment(ctx, agt, NewNChain(5, 0.2, rng), 3, 1, splitStreams(rng))
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, returns)
+	assert.True(t, agt.taken <= 2500+interruptSteps)
+
+	// A trial cancelled in the middle of an episode can't be run again
+	ctx, cancel = context.WithCancel(context.Background())
+	trial := NewTrial(&stepCancellingAgent{Agent: &recordingAgent{}, steps: 10, cancel: cancel}, NewNChain(5, 0.2, rng), rng)
+	assert.Equal(t, context.Canceled, trial.RunTo(ctx, 3))
+	err = trial.RunTo(context.Background(), 3)
+	assert.Error(t, err)
+	assert.NotEqual(t, context.Canceled, err)
+}
+
+func TestRunTrialsFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	rng := mathlib.NewRandom(0)
+	env := func() Environment { return NewRandomWalkBandit(3, 20, 0.1) }
+	cfg := TrialConfig{NumTrials: 4, Parallelism: 2, OutputDir: dir, FileName: "failures"}
+
+	// A panicking trial is reported, and the returns of the others are written
+	trial := 0
+	agt := func() Agent {
+		trial++
+		if trial == 3 {
+			return &recordingAgent{action: 7}
+		}
+		return NewUCB1Bandit(3, 2)
+	}
+	err = RunTrialsWithConfig(rng, agt, env, cfg)
+	var trialsErr *TrialsError
+	assert.True(t, errors.As(err, &trialsErr))
+	assert.Len(t, trialsErr.Errors, 1)
+	assert.True(t, trialsErr.Written)
+	assert.Contains(t, err.Error(), "trial 2: panic: runtime error: index out of range")
+	_, err = os.Stat(filepath.Join(dir, "failures_out.csv"))
+	assert.NoError(t, err)
+
+	// RunTrialsReturns gives the returns of every trial that finished
+	trial = 0
+	cfg.NumEps = 5
+	returns, err := RunTrialsReturns(context.Background(), rng, agt, env, cfg)
+	assert.True(t, errors.As(err, &trialsErr))
+	assert.Len(t, returns, 4)
+	assert.Nil(t, returns[1])
+	for _, i := range []int{0, 2, 3} {
+		assert.Len(t, returns[i], 5)
+	}
+
+	// A cancelled run stops without writing anything if no trial finished
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.FileName = "cancelled"
+	err = RunTrialsContext(ctx, rng, func() Agent { return NewUCB1Bandit(3, 2) }, env, cfg)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = os.Stat(filepath.Join(dir, "cancelled_out.csv"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// shiftingEnvironment logs a change before every second episode.
+type shiftingEnvironment struct {
+	Environment
+	episodes int
+}
+
+func (env *shiftingEnvironment) NewEpisode(rng *mathlib.Random) {
+	env.episodes++
+	env.Environment.NewEpisode(rng)
+}
+
+func (env *shiftingEnvironment) ChangePoints() []ChangePoint {
+	var points []ChangePoint
+	for ep := 2; ep < env.episodes; ep += 2 {
+		points = append(points, ChangePoint{Episode: ep, Description: "shift"})
+	}
+	return points
+}
+
+func TestRunTrialsChangePoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	rng := mathlib.NewRandom(0)
+	cfg := TrialConfig{NumTrials: 2, NumEps: 5, OutputDir: dir, FileName: "changes"}
+
+	// The change points of every trial are written next to the returns
+	assert.NoError(t, RunTrialsWithConfig(rng, func() Agent { return NewUCB1Bandit(3, 2) },
+		func() Environment { return &shiftingEnvironment{Environment: NewRandomWalkBandit(3, 20, 0.1)} }, cfg))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "changes_changepoints.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Trial, Episode, Change\n1,2,\"shift\"\n1,4,\"shift\"\n2,2,\"shift\"\n2,4,\"shift\"\n", string(data))
+
+	// Environments that don't change get no file
+	cfg.FileName = "stationary"
+	assert.NoError(t, RunTrialsWithConfig(rng, func() Agent { return NewUCB1Bandit(3, 2) },
+		func() Environment { return NewRandomWalkBandit(3, 20, 0.1) }, cfg))
+	_, err = os.Stat(filepath.Join(dir, "stationary_changepoints.csv"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunTrialsInterruptAndResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interrupt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run := func(ctx context.Context, fileName string, agt func() Agent) error {
+		rng := mathlib.NewRandom(4)
+		env := func() Environment { return NewGridworld(rng) }
+		cfg := TrialConfig{NumTrials: 3, NumEps: 30, Parallelism: 1, OutputDir: dir, FileName: fileName,
+			Checkpoint: filepath.Join(dir, "interrupted_checkpoint.json")}
+		if fileName == "uninterrupted" {
+			cfg.Checkpoint = ""
+		}
+		return RunTrialsContext(ctx, rng, agt, env, cfg)
+	}
+	sarsa := func() Agent { return NewSarsa(23, 4, 0.9, 0.1, 0, NewEpsilonGreedy(ConstantSchedule(0.1))) }
+	assert.NoError(t, run(context.Background(), "uninterrupted", sarsa))
+
+	// Interrupting the second trial halfway through saves its progress without any periodic checkpoints
+	ctx, cancel := context.WithCancel(context.Background())
+	trial := 0
+	err = run(ctx, "interrupted", func() Agent {
+		if trial++; trial == 3 {
+			return &cancellingAgent{Agent: sarsa(), episodes: 12, cancel: cancel}
+		}
+		return sarsa()
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	c, err := loadCheckpointer(filepath.Join(dir, "interrupted_checkpoint.json"), 0, "", 3, 30)
+	assert.NoError(t, err)
+	assert.Len(t, c.trial(0).Returns, 30)
+	assert.Len(t, c.trial(1).Returns, 12)
+	assert.NotEmpty(t, c.trial(1).Agent)
+	assert.Empty(t, c.trial(2).Returns)
+	assert.NoError(t, run(context.Background(), "interrupted", sarsa))
+
+	want, err := ioutil.ReadFile(filepath.Join(dir, "uninterrupted_out.csv"))
+	assert.NoError(t, err)
+	got, err := ioutil.ReadFile(filepath.Join(dir, "interrupted_out.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestTrialsRunTo(t *testing.T) {
+	ctors := func() (*mathlib.Random, func() Agent, func() Environment) {
+		rng := mathlib.NewRandom(5)
+		return rng, func() Agent { return NewSarsa(23, 4, 0.9, 0.1, 0, NewSoftmax(1)) }, func() Environment { return NewGridworld(rng) }
+	}
+	rng, agt, env := ctors()
+	want, err := RunTrialsReturns(context.Background(), rng, agt, env, TrialConfig{NumTrials: 4, NumEps: 20})
+	assert.NoError(t, err)
+
+	// Trials run a few episodes at a time give the returns of trials run all at once
+	rng, agt, env = ctors()
+	trials, err := NewTrials(rng, agt, env, 4)
+	assert.NoError(t, err)
+	assert.NoError(t, RunTrialsTo(context.Background(), trials, 5, 2))
+	assert.Len(t, trials[0].Returns(), 5)
+	assert.NoError(t, RunTrialsTo(context.Background(), trials[:2], 12, 2))
+	assert.Len(t, trials[2].Returns(), 5)
+
+	// A cancelled run can be run again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(RunTrialsTo(ctx, trials, 20, 2), context.Canceled))
+	assert.NoError(t, RunTrialsTo(context.Background(), trials, 20, 0))
+	for i, trial := range trials {
+		assert.Equal(t, want[i], trial.Returns())
+	}
+}
+
+// closingEnvironment counts how many of the environments built by a run are closed.
+type closingEnvironment struct {
+	Environment
+	closed *int32
+}
+
+func (env *closingEnvironment) Close() error {
+	atomic.AddInt32(env.closed, 1)
+	return nil
+}
+
+func TestRunsCloseEnvironments(t *testing.T) {
+	var built, closed int32
+	rng := mathlib.NewRandom(0)
+	agt := func() Agent { return NewUCB1Bandit(3, 2) }
+	env := func() Environment {
+		built++
+		return &closingEnvironment{Environment: NewRandomWalkBandit(3, 20, 0.1), closed: &closed}
+	}
+
+	// The environment built to check the spaces and those of every trial are closed
+	_, err := RunTrialsReturns(context.Background(), rng, agt, env, TrialConfig{NumTrials: 3, NumEps: 5, Parallelism: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), built)
+	assert.Equal(t, built, closed)
+
+	// Resumable trials close theirs when asked to
+	built, closed = 0, 0
+	trials, err := NewTrials(rng, agt, env, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), closed)
+	for _, trial := range trials {
+		trial.Close()
+	}
+	assert.Equal(t, built, closed)
+}
@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"math"
+	"encoding/json"
 
 	"github.com/jackkenney/evolve-rl/mathlib"
 )
@@ -15,12 +15,15 @@ type Sarsa struct {
 	theta           [][]float64 // Q (Action-Value) Function
 	alpha           float64
 	optimisticValue float64
+
+	policy ExplorationPolicy // Selects actions from the action values
 }
 
 // NewSarsa returns an initialized Sarsa object.
-func NewSarsa(stateDim int, numActions int, gamma float64, alpha float64, optimisticValue float64) Agent {
+func NewSarsa(stateDim int, numActions int, gamma float64, alpha float64, optimisticValue float64, policy ExplorationPolicy) Agent {
 	agt := Sarsa{}
 	agt.alpha = alpha
+	agt.policy = policy
 
 	agt.numStates = stateDim
 	agt.numActions = numActions
@@ -42,35 +45,11 @@ func (agt *Sarsa) EpisodicAgent() bool {
 	return false
 }
 
-// GetAction return softmax selected action
+// GetAction returns the action selected by the exploration policy from the action values of the state.
 func (agt *Sarsa) GetAction(s []float64, rng *mathlib.Random) int {
 	// Convert the one-hot state into an integer from 0 - (numStates-1)
 	state := mathlib.FromOneHot(s)
-
-	// Get the action probabilities from theta, using softmax action selection.
-	actionProbabilities := make([]float64, len(agt.theta[state]))
-	copy(agt.theta[state], actionProbabilities)
-
-	// Softmax
-	denominator := 0.0
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] = math.Exp(actionProbabilities[a])
-		denominator += actionProbabilities[a]
-	}
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] /= denominator
-	}
-
-	// Select random action from softmax
-	temp := rng.Float64()
-	sum := 0.0
-	for a := 0; a < agt.numActions; a++ {
-		sum += actionProbabilities[a]
-		if temp <= sum {
-			return a // The function will return 'a'. This stops the for loop and returns from the function.
-		}
-	}
-	return agt.numActions - 1 // Rounding error
+	return agt.policy.SelectAction(state, agt.theta[state], rng)
 }
 
 // NewEpisode tells the agent that it is at the start of a new episode.
@@ -78,9 +57,46 @@ func (agt *Sarsa) NewEpisode() {
 	// Nothing to do at episode threshold for sarsa
 }
 
+// Supports returns an error unless observations are one-hot states and actions are discrete.
+func (agt *Sarsa) Supports(observation Space, action Space) error {
+	return supportsTabular(agt.numStates, agt.numActions, observation, action)
+}
+
 // Reset the agent entirely - to a blank slate prior to learning
 func (agt *Sarsa) Reset(rng *mathlib.Random) {
 	mathlib.ResetMat(&agt.theta, agt.optimisticValue)
+	agt.policy.Reset()
+}
+
+// sarsaSnapshot is the learned state of a Sarsa agent.
+type sarsaSnapshot struct {
+	Theta  [][]float64     `json:"theta"`
+	Policy json.RawMessage `json:"policy,omitempty"`
+}
+
+// Snapshot returns the action values and the state of the exploration policy.
+func (agt *Sarsa) Snapshot() ([]byte, error) {
+	policy, err := SnapshotOf(agt.policy)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sarsaSnapshot{Theta: agt.theta, Policy: policy})
+}
+
+// Restore sets the action values and the state of the exploration policy to a snapshot.
+func (agt *Sarsa) Restore(data []byte) error {
+	snap := sarsaSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkMatrix("theta", snap.Theta, agt.numStates, agt.numActions); err != nil {
+		return err
+	}
+	if err := RestoreSnapshot(agt.policy, snap.Policy); err != nil {
+		return err
+	}
+	agt.theta = snap.Theta
+	return nil
 }
 
 // UpdateSARS is unimplemented for this class.
@@ -103,3 +119,13 @@ func (agt *Sarsa) LastUpdate(s []float64, a int, r float64, rng *mathlib.Random)
 	tdError := r - agt.theta[state][a]
 	agt.theta[state][a] += agt.alpha * tdError
 }
+
+// TruncatedUpdate bootstraps from the expected action value of sPrime under the exploration policy,
+// since no next action is taken after a truncated episode.
+func (agt *Sarsa) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	sIdx := mathlib.FromOneHot(s)
+	sPrimeIdx := mathlib.FromOneHot(sPrime)
+	probs := agt.policy.Probabilities(sPrimeIdx, agt.theta[sPrimeIdx])
+	tdError := r + agt.gamma*mathlib.Dot(probs, agt.theta[sPrimeIdx]) - agt.theta[sIdx][a]
+	agt.theta[sIdx][a] += agt.alpha * tdError
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+)
+
+// SpaceKind says how the vectors or actions of a Space are encoded.
+type SpaceKind int
+
+const (
+	// Discrete spaces are the integers 0 to N-1. Observations are vectors of length one.
+	Discrete SpaceKind = iota
+	// OneHot spaces are the one-hot encodings of the integers 0 to N-1.
+	OneHot
+	// Box spaces are real vectors with a lower and upper bound on every component. Bounds may be infinite.
+	Box
+	// MultiDiscrete spaces are integer vectors whose i'th component is between 0 and Radices[i]-1.
+	MultiDiscrete
+)
+
+// String returns the name of the kind.
+func (k SpaceKind) String() string {
+	switch k {
+	case Discrete:
+		return "Discrete"
+	case OneHot:
+		return "OneHot"
+	case Box:
+		return "Box"
+	case MultiDiscrete:
+		return "MultiDiscrete"
+	}
+	return fmt.Sprintf("SpaceKind(%d)", int(k))
+}
+
+// Space describes the observations or actions of an Environment.
+type Space struct {
+	Kind    SpaceKind
+	N       int       // Number of values of a Discrete or OneHot space
+	Low     []float64 // Lower bound of every component of a Box space
+	High    []float64 // Upper bound of every component of a Box space
+	Radices []int     // Number of values of every component of a MultiDiscrete space
+}
+
+// NewDiscreteSpace returns the space of the integers 0 to n-1.
+func NewDiscreteSpace(n int) Space {
+	return Space{Kind: Discrete, N: n}
+}
+
+// NewOneHotSpace returns the space of one-hot vectors of length n.
+func NewOneHotSpace(n int) Space {
+	return Space{Kind: OneHot, N: n}
+}
+
+// NewBoxSpace returns the space of vectors bounded component-wise by low and high.
+func NewBoxSpace(low []float64, high []float64) Space {
+	if len(low) != len(high) {
+		panic("Box bounds must have the same length.")
+	}
+	return Space{Kind: Box, Low: low, High: high}
+}
+
+// NewUnboundedBoxSpace returns the space of all real vectors of length dim.
+func NewUnboundedBoxSpace(dim int) Space {
+	low := make([]float64, dim)
+	high := make([]float64, dim)
+	for i := 0; i < dim; i++ {
+		low[i] = math.Inf(-1)
+		high[i] = math.Inf(1)
+	}
+	return NewBoxSpace(low, high)
+}
+
+// NewMultiDiscreteSpace returns the space of integer vectors with the passed number of values per component.
+func NewMultiDiscreteSpace(radices []int) Space {
+	return Space{Kind: MultiDiscrete, Radices: radices}
+}
+
+// Dim returns the length of the vectors of the space.
+func (s Space) Dim() int {
+	switch s.Kind {
+	case Discrete:
+		return 1
+	case OneHot:
+		return s.N
+	case Box:
+		return len(s.Low)
+	case MultiDiscrete:
+		return len(s.Radices)
+	}
+	panic("Unknown space kind.")
+}
+
+// Bounds returns the lower and upper bound of every component of the vectors of the space.
+func (s Space) Bounds() ([]float64, []float64) {
+	if s.Kind == Box {
+		return s.Low, s.High
+	}
+	low := make([]float64, s.Dim())
+	high := make([]float64, s.Dim())
+	for i := range high {
+		switch s.Kind {
+		case Discrete:
+			high[i] = float64(s.N - 1)
+		case OneHot:
+			high[i] = 1
+		case MultiDiscrete:
+			high[i] = float64(s.Radices[i] - 1)
+		}
+	}
+	return low, high
+}
+
+// Contains returns whether x is a valid vector of the space.
+func (s Space) Contains(x []float64) bool {
+	if len(x) != s.Dim() {
+		return false
+	}
+	switch s.Kind {
+	case Discrete:
+		return isInt(x[0]) && x[0] >= 0 && x[0] < float64(s.N)
+	case OneHot:
+		ones := 0
+		for _, v := range x {
+			if v == 1 {
+				ones++
+			} else if v != 0 {
+				return false
+			}
+		}
+		return ones == 1
+	case Box:
+		for i, v := range x {
+			if !(v >= s.Low[i] && v <= s.High[i]) {
+				return false
+			}
+		}
+		return true
+	case MultiDiscrete:
+		for i, v := range x {
+			if !isInt(v) || v < 0 || v >= float64(s.Radices[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isInt returns whether x is a whole number.
+func isInt(x float64) bool {
+	return x == math.Trunc(x)
+}
+
+// String describes the space, e.g. "OneHot(23)" or "Box(4)".
+func (s Space) String() string {
+	switch s.Kind {
+	case Discrete, OneHot:
+		return fmt.Sprintf("%v(%d)", s.Kind, s.N)
+	case MultiDiscrete:
+		return fmt.Sprintf("%v%v", s.Kind, s.Radices)
+	}
+	return fmt.Sprintf("%v(%d)", s.Kind, s.Dim())
+}
+
+// ObservationSpacer is implemented by environments whose observations are not one-hot vectors.
+type ObservationSpacer interface {
+	ObservationSpace() Space
+}
+
+// ActionSpacer is implemented by environments that describe their actions with a Space.
+type ActionSpacer interface {
+	ActionSpace() Space
+}
+
+// ObservationSpaceOf returns the observation space of env. Environments that do not implement
+// ObservationSpacer observe one-hot vectors of length GetStateDim.
+func ObservationSpaceOf(env Environment) Space {
+	if s, ok := env.(ObservationSpacer); ok {
+		return s.ObservationSpace()
+	}
+	return NewOneHotSpace(env.GetStateDim())
+}
+
+// ActionSpaceOf returns the action space of env. Environments that do not implement ActionSpacer
+// have GetNumActions discrete actions.
+func ActionSpaceOf(env Environment) Space {
+	if s, ok := env.(ActionSpacer); ok {
+		return s.ActionSpace()
+	}
+	return NewDiscreteSpace(env.GetNumActions())
+}
+
+// CheckSpaces returns an error if agt cannot learn in env.
+func CheckSpaces(agt Agent, env Environment) error {
+	obs, act := ObservationSpaceOf(env), ActionSpaceOf(env)
+	if err := agt.Supports(obs, act); err != nil {
+		return fmt.Errorf("agent does not support observations %v and actions %v: %v", obs, act, err)
+	}
+	return nil
+}
+
+// supportsTabular is the Supports check of agents that keep a table over one-hot states and
+// discrete actions.
+func supportsTabular(stateDim int, numActions int, observation Space, action Space) error {
+	if observation.Kind != OneHot || observation.N != stateDim {
+		return fmt.Errorf("need %v observations", NewOneHotSpace(stateDim))
+	}
+	return supportsDiscreteActions(numActions, action)
+}
+
+// supportsDiscreteActions checks that the action space is numActions discrete actions.
+func supportsDiscreteActions(numActions int, action Space) error {
+	if action.Kind != Discrete || action.N != numActions {
+		return fmt.Errorf("need %v actions", NewDiscreteSpace(numActions))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaceContains(t *testing.T) {
+	assert.True(t, NewDiscreteSpace(3).Contains([]float64{2}))
+	assert.False(t, NewDiscreteSpace(3).Contains([]float64{3}))
+	assert.False(t, NewDiscreteSpace(3).Contains([]float64{0.5}))
+
+	assert.True(t, NewOneHotSpace(3).Contains([]float64{0, 1, 0}))
+	assert.False(t, NewOneHotSpace(3).Contains([]float64{0, 1, 1}))
+	assert.False(t, NewOneHotSpace(3).Contains([]float64{0, 1}))
+
+	box := NewBoxSpace([]float64{-1, 0}, []float64{1, math.Inf(1)})
+	assert.True(t, box.Contains([]float64{-1, 1e9}))
+	assert.False(t, box.Contains([]float64{-2, 0}))
+	assert.False(t, box.Contains([]float64{0, math.NaN()}))
+
+	multi := NewMultiDiscreteSpace([]int{2, 3})
+	assert.True(t, multi.Contains([]float64{1, 2}))
+	assert.False(t, multi.Contains([]float64{2, 0}))
+	assert.Equal(t, "MultiDiscrete[2 3]", multi.String())
+	low, high := multi.Bounds()
+	assert.Equal(t, []float64{0, 0}, low)
+	assert.Equal(t, []float64{1, 2}, high)
+}
+
+func TestEnvironmentSpaces(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	for _, env := range []Environment{
+		NewGridworld(r), NewTaxi(r), NewMountainCar(r), NewCartPole(r), NewAcrobot(r), NewPendulum(3, r),
+		NewLinearContextualBandit(3, 4, 0.1, 10, r),
+	} {
+		obs := ObservationSpaceOf(env)
+		assert.Equal(t, env.GetStateDim(), obs.Dim())
+		assert.True(t, obs.Contains(env.GetState()), "%T observation outside %v", env, obs)
+		assert.Equal(t, NewDiscreteSpace(env.GetNumActions()), ActionSpaceOf(env))
+	}
+}
+
+func TestCheckSpaces(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	grid := NewGridworld(r)
+	sarsa := NewSarsa(grid.GetStateDim(), grid.GetNumActions(), grid.GetGamma(), 0.1, 0, NewSoftmax(1))
+	assert.NoError(t, CheckSpaces(sarsa, grid))
+	assert.Error(t, CheckSpaces(sarsa, NewCartPole(r)))
+
+	bandit := NewLinearContextualBandit(3, 4, 0.1, 10, r)
+	assert.NoError(t, CheckSpaces(NewLinUCB(4, 3, 1), bandit))
+	assert.Error(t, CheckSpaces(NewLinUCB(5, 3, 1), bandit))
+	assert.Error(t, CheckSpaces(NewUCB1Bandit(2, 1), bandit))
+
+	// Incompatible pairs are rejected before any trial runs
+	err := RunTrials(r,
+		func() Agent { return sarsa },
+		func() Environment { return NewCartPole(r) },
+		1, "incompatible")
+	assert.Error(t, err)
+}
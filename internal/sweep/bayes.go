@@ -0,0 +1,246 @@
+package sweep
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Kernel is the correlation of a Gaussian process between two points that are r length scales apart.
+type Kernel func(r float64) float64
+
+// RBF is the radial basis function (squared exponential) kernel, whose samples are infinitely smooth.
+func RBF(r float64) float64 {
+	return math.Exp(-r * r / 2)
+}
+
+// Matern52 is the Matérn kernel with ν = 5/2, whose samples are twice differentiable. It is the
+// usual choice for hyperparameter search, where RBF tends to be too smooth (Snoek et al., 2012).
+func Matern52(r float64) float64 {
+	s := math.Sqrt(5) * r
+	return (1 + s + s*s/3) * math.Exp(-s)
+}
+
+// Length scales, relative to the diagonal of the unit hypercube, and noise variances, relative to
+// the variance of the scores, among which fitGP picks the most likely
+var (
+	gpLengthScales = []float64{0.05, 0.1, 0.2, 0.4, 0.8, 1.6}
+	gpNoises       = []float64{1e-6, 1e-3, 0.01, 0.1, 0.5}
+)
+
+// gaussianProcess is a Gaussian process regression of scores on points of the unit hypercube. The
+// scores are standardized, so a kernel of variance 1 suits returns of any scale.
+type gaussianProcess struct {
+	kernel        Kernel
+	lengthScale   float64
+	x             [][]float64
+	chol          [][]float64 // Cholesky factor of the covariance of the observed scores, K + noise I
+	alpha         []float64   // (K + noise I)^-1 y for the standardized scores y
+	mean          float64     // Mean of the scores
+	scale         float64     // Standard deviation of the scores
+	logLikelihood float64     // Log marginal likelihood of the standardized scores
+}
+
+// fitGP returns the Gaussian process of the scores y at the points x whose length scale and noise
+// maximize the marginal likelihood, or an error if there are too few scores to fit one.
+func fitGP(kernel Kernel, x [][]float64, y []float64) (*gaussianProcess, error) {
+	if len(y) < 2 {
+		return nil, fmt.Errorf("a Gaussian process needs at least 2 scores, not %d", len(y))
+	}
+	mean := mathlib.Mean(y)
+	scale := mathlib.StdError(y) * math.Sqrt(float64(len(y)))
+	if scale == 0 {
+		scale = 1
+	}
+	standardized := make([]float64, len(y))
+	for i := range y {
+		standardized[i] = (y[i] - mean) / scale
+	}
+
+	var best *gaussianProcess
+	diagonal := math.Sqrt(float64(len(x[0])))
+	for _, lengthScale := range gpLengthScales {
+		for _, noise := range gpNoises {
+			gp, err := newGaussianProcess(kernel, lengthScale*diagonal, noise, x, standardized)
+			if err == nil && (best == nil || gp.logLikelihood > best.logLikelihood) {
+				best = gp
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no Gaussian process fits the scores")
+	}
+	best.mean, best.scale = mean, scale
+	return best, nil
+}
+
+// newGaussianProcess returns the Gaussian process of the standardized scores y at the points x
+// with the passed length scale and noise variance, or an error if their covariance is singular.
+func newGaussianProcess(kernel Kernel, lengthScale float64, noise float64, x [][]float64, y []float64) (*gaussianProcess, error) {
+	gp := gaussianProcess{kernel: kernel, lengthScale: lengthScale, x: x}
+	k := mathlib.Matrix(len(x), len(x), 0)
+	for i := range x {
+		for j := 0; j <= i; j++ {
+			k[i][j] = gp.cov(x[i], x[j])
+		}
+		k[i][i] += noise
+	}
+	var err error
+	if gp.chol, err = mathlib.Cholesky(k); err != nil {
+		return nil, err
+	}
+	gp.alpha = mathlib.CholeskySolve(gp.chol, y)
+
+	// log p(y) = -y^T alpha / 2 - log det(K + noise I) / 2 - n log(2 pi) / 2
+	gp.logLikelihood = -mathlib.Dot(y, gp.alpha)/2 - float64(len(y))*math.Log(2*math.Pi)/2
+	for i := range gp.chol {
+		gp.logLikelihood -= math.Log(gp.chol[i][i])
+	}
+	return &gp, nil
+}
+
+// cov returns the prior covariance of the standardized scores at a and b.
+func (gp *gaussianProcess) cov(a []float64, b []float64) float64 {
+	d := 0.0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return gp.kernel(math.Sqrt(d) / gp.lengthScale)
+}
+
+// predict returns the posterior mean and standard deviation of the standardized score at u.
+func (gp *gaussianProcess) predict(u []float64) (float64, float64) {
+	k := make([]float64, len(gp.x))
+	for i, x := range gp.x {
+		k[i] = gp.cov(u, x)
+	}
+	v := mathlib.ForwardSubstitute(gp.chol, k)
+	variance := gp.cov(u, u) - mathlib.Dot(v, v)
+	return mathlib.Dot(k, gp.alpha), math.Sqrt(math.Max(variance, 0))
+}
+
+// expectedImprovement returns the expected amount by which a normally distributed score with the
+// passed mean and standard deviation exceeds best by more than xi (Jones et al., 1998).
+func expectedImprovement(mean float64, std float64, best float64, xi float64) float64 {
+	improvement := mean - best - xi
+	if std == 0 {
+		return math.Max(improvement, 0)
+	}
+	z := improvement / std
+	return improvement*mathlib.NormalCDF(z) + std*mathlib.NormalPDF(z)
+}
+
+// BayesianOptimizer proposes configurations by Bayesian optimization (Snoek et al., 2012). A
+// Gaussian process models the score of a configuration from the scores observed so far, and each
+// proposal is the configuration with the highest expected improvement on the best of them. The
+// first configurations are drawn by Latin hypercube sampling to start the model off.
+//
+// The model works on the unit hypercube that Space maps onto the hyperparameters, so choices and
+// whole numbers are modelled as the ranges of the hypercube that give them.
+type BayesianOptimizer struct {
+	Space         Space
+	Kernel        Kernel
+	InitialPoints int     // Configurations drawn by Latin hypercube sampling before the model proposes any
+	Candidates    int     // Random points the expected improvement is maximized over for each proposal
+	Xi            float64 // Improvement on the best score, in standard deviations of the scores, that is expected for free
+
+	rng     *mathlib.Random
+	initial [][]float64       // Points of the initial design not yet proposed
+	x       [][]float64       // Points of the configurations observed
+	y       []float64         // Their scores
+	pending map[int][]float64 // Points of the configurations proposed but not yet observed, by ID
+	lastID  int
+}
+
+// NewBayesianOptimizer returns an optimizer of the space with the usual settings, which draws from rng.
+func NewBayesianOptimizer(space Space, kernel Kernel, rng *mathlib.Random) *BayesianOptimizer {
+	initial := 2 * len(space)
+	if initial < 5 {
+		initial = 5
+	}
+	return &BayesianOptimizer{
+		Space:         space,
+		Kernel:        kernel,
+		InitialPoints: initial,
+		Candidates:    2000,
+		Xi:            0.01,
+		rng:           rng,
+		pending:       map[int][]float64{},
+	}
+}
+
+// Propose returns the next configuration to try. Its ID counts the proposals, from 1.
+func (b *BayesianOptimizer) Propose() Config {
+	b.lastID++
+	var u []float64
+	if b.lastID == 1 {
+		b.initial = latinHypercube(len(b.Space), b.InitialPoints, b.rng)
+	}
+	if len(b.initial) > 0 {
+		u, b.initial = b.initial[0], b.initial[1:]
+	} else {
+		u = b.maximizeImprovement()
+	}
+	b.pending[b.lastID] = u
+	return Config{ID: b.lastID, Params: b.Space.at(u)}
+}
+
+// Observe tells the optimizer the score of a configuration it proposed, where higher is better.
+func (b *BayesianOptimizer) Observe(c Config, score float64) error {
+	u, ok := b.pending[c.ID]
+	if !ok {
+		return fmt.Errorf("configuration %d was not proposed or was already observed", c.ID)
+	}
+	delete(b.pending, c.ID)
+	b.x = append(b.x, u)
+	b.y = append(b.y, score)
+	return nil
+}
+
+// maximizeImprovement returns the point with the highest expected improvement among random points
+// and points near the best observed so far, or a random point if no model fits the scores yet.
+func (b *BayesianOptimizer) maximizeImprovement() []float64 {
+	random := func() []float64 {
+		u := make([]float64, len(b.Space))
+		for j := range u {
+			u[j] = b.rng.Float64()
+		}
+		return u
+	}
+	gp, err := fitGP(b.Kernel, b.x, b.y)
+	if err != nil {
+		return random()
+	}
+
+	// Search around the five best points as well as everywhere, since the maximum is often near them
+	best := make([]int, len(b.y))
+	for i := range best {
+		best[i] = i
+	}
+	sort.SliceStable(best, func(i, j int) bool { return b.y[best[i]] > b.y[best[j]] })
+	if len(best) > 5 {
+		best = best[:5]
+	}
+	bestScore := (b.y[best[0]] - gp.mean) / gp.scale
+
+	var argmax []float64
+	maxImprovement := math.Inf(-1)
+	for c := 0; c < b.Candidates; c++ {
+		var u []float64
+		if c%2 == 0 {
+			u = random()
+		} else {
+			u = make([]float64, len(b.Space))
+			for j, x := range b.x[best[(c/2)%len(best)]] {
+				u[j] = math.Min(math.Max(x+0.05*b.rng.NormFloat64(), 0), 1)
+			}
+		}
+		mean, std := gp.predict(u)
+		if improvement := expectedImprovement(mean, std, bestScore, b.Xi); improvement > maxImprovement {
+			argmax, maxImprovement = u, improvement
+		}
+	}
+	return argmax
+}
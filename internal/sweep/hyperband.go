@@ -0,0 +1,169 @@
+package sweep
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Hyperband is a schedule that stops the trials of bad configurations early. Successive halving
+// (Jamieson and Talwalkar, 2016) runs many configurations for a few episodes, keeps the best 1/Eta
+// of them, runs those to Eta times as many episodes, and so on up to MaxEpisodes. Hyperband (Li et
+// al., 2018) runs several brackets of successive halving that start at different numbers of
+// episodes, from MinEpisodes up, which hedges against agents that learn slowly but end up best.
+//
+// Trials are continued from one rung to the next rather than started over, so a configuration that
+// survives every rung gets the same returns as in a sweep of MaxEpisodes episodes. The trials of
+// every configuration in a rung run in parallel, at most the spec's parallelism at a time.
+// Checkpoints are not used, so an interrupted search starts over.
+type Hyperband struct {
+	MinEpisodes int // Episodes of the first rung of the bracket that starts the most configurations
+	MaxEpisodes int // Episodes of the configurations that survive every rung
+	Eta         int // Factor by which each rung cuts the configurations and multiplies their episodes
+	Window      int // Configurations are ranked by their mean return over this many final episodes
+}
+
+// validate returns an error if the schedule has no rungs.
+func (h Hyperband) validate() error {
+	if h.MinEpisodes < 1 || h.MaxEpisodes < h.MinEpisodes {
+		return fmt.Errorf("episodes must rise from at least 1, not from %d to %d", h.MinEpisodes, h.MaxEpisodes)
+	}
+	if h.Eta < 2 {
+		return fmt.Errorf("eta must be at least 2, not %d", h.Eta)
+	}
+	return nil
+}
+
+// rungs returns the episodes of the rungs of a bracket that starts at first episodes: first, Eta
+// times that, and so on up to MaxEpisodes, rounded to whole episodes.
+func (h Hyperband) rungs(first float64) []int {
+	var rungs []int
+	for eps := first; eps < float64(h.MaxEpisodes)-0.5; eps *= float64(h.Eta) {
+		rungs = append(rungs, int(math.Round(eps)))
+	}
+	return append(rungs, h.MaxEpisodes)
+}
+
+// SuccessiveHalving runs the configurations through one bracket of successive halving that starts
+// at h.MinEpisodes, and returns the results of every configuration with the episodes it ran. Like
+// Run, it stops at the first failure and returns the results so far.
+func (s *Sweep) SuccessiveHalving(ctx context.Context, configs []Config, h Hyperband) ([]Result, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	return s.halve(ctx, configs, h.rungs(float64(h.MinEpisodes)), h)
+}
+
+// Hyperband runs the brackets of h on configurations drawn at random from the space, numbered
+// across the brackets, and returns the results of every configuration with the episodes it ran.
+func (s *Sweep) Hyperband(ctx context.Context, space Space, h Hyperband, rng *mathlib.Random) ([]Result, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	// The most aggressive bracket starts at about MinEpisodes and has brackets+1 rungs
+	ratio := float64(h.MaxEpisodes) / float64(h.MinEpisodes)
+	brackets := int(math.Floor(math.Log(ratio)/math.Log(float64(h.Eta)) + 1e-9))
+
+	var results []Result
+	lastID := 0
+	for b := brackets; b >= 0; b-- {
+		// Every bracket gets about the same budget of episodes
+		n := int(math.Ceil(float64(brackets+1) / float64(b+1) * math.Pow(float64(h.Eta), float64(b))))
+		configs := Random(space, n, rng)
+		for i := range configs {
+			lastID++
+			configs[i].ID = lastID
+		}
+		first := float64(h.MaxEpisodes) / math.Pow(float64(h.Eta), float64(b))
+		s.logf("Bracket %d of %d: %d configurations from %d episodes", brackets-b+1, brackets+1, n, int(math.Round(first)))
+		bracket, err := s.halve(ctx, configs, h.rungs(first), h)
+		results = append(results, bracket...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// halve runs the configurations to the episodes of each rung in turn, keeping the best 1/Eta of
+// them after every rung but the last, and returns the results of every configuration. The trials
+// of a configuration are built when it first runs and closed once it is dropped, after which only
+// their returns are kept.
+func (s *Sweep) halve(ctx context.Context, configs []Config, rungs []int, h Hyperband) ([]Result, error) {
+	trials := make([][]*internal.Trial, len(configs))
+	dropped := make([][][]float64, len(configs)) // Returns of the configurations that were dropped
+	// drop closes the trials of configuration i and keeps their returns
+	drop := func(i int) {
+		for _, t := range trials[i] {
+			dropped[i] = append(dropped[i], t.Returns())
+			t.Close()
+		}
+		trials[i] = nil
+	}
+	defer func() {
+		for i := range trials {
+			drop(i)
+		}
+	}()
+	// results returns the results of the configurations that have run
+	results := func() []Result {
+		var results []Result
+		for i, c := range configs {
+			r := Result{Config: c, Returns: dropped[i]}
+			for _, t := range trials[i] {
+				r.Returns = append(r.Returns, t.Returns())
+			}
+			if len(r.Returns) > 0 && len(r.Returns[0]) > 0 {
+				results = append(results, r)
+			}
+		}
+		return results
+	}
+
+	alive := make([]int, len(configs))
+	for i := range alive {
+		alive[i] = i
+	}
+	for rung, eps := range rungs {
+		s.logf("Running %d configurations to %d episodes", len(alive), eps)
+		var running []*internal.Trial
+		for _, i := range alive {
+			if trials[i] == nil {
+				var err error
+				if trials[i], err = s.Spec.NewTrials(s.Environment, s.agent(configs[i])); err != nil {
+					return results(), fmt.Errorf("configuration %d: %w", configs[i].ID, err)
+				}
+			}
+			running = append(running, trials[i]...)
+		}
+		if err := internal.RunTrialsTo(ctx, running, eps, s.Spec.Parallelism); err != nil {
+			return results(), fmt.Errorf("running %d configurations to %d episodes: %w", len(alive), eps, err)
+		}
+		if rung == len(rungs)-1 {
+			break
+		}
+
+		scores := map[int]float64{}
+		for _, i := range alive {
+			var finals []float64
+			for _, t := range trials[i] {
+				finals = append(finals, finalMean(t.Returns(), h.Window))
+			}
+			scores[i] = mathlib.Mean(finals)
+		}
+		sort.SliceStable(alive, func(a, b int) bool { return scores[alive[a]] > scores[alive[b]] })
+		keep := len(alive) / h.Eta
+		if keep < 1 {
+			keep = 1
+		}
+		for _, i := range alive[keep:] {
+			drop(i)
+		}
+		alive = alive[:keep]
+	}
+	return results(), nil
+}
@@ -0,0 +1,251 @@
+package sweep
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"gopkg.in/yaml.v3"
+)
+
+// Dimension is a hyperparameter of the agent and the values a search may give it: either the
+// choices in Values, or the numbers from Min to Max.
+type Dimension struct {
+	Name    string
+	Values  []interface{} // Choices, e.g. policies; if set, Min, Max, Log and Integer are not used
+	Min     float64
+	Max     float64
+	Log     bool // Search the range on a log scale, e.g. for step sizes
+	Integer bool // Search only the whole numbers in the range, e.g. for numbers of candidates
+}
+
+// ParseDimension parses "<param>=<value>,<value>,..." for choices, each read as YAML as spec files
+// are, or "<param>=<min>:<max>[:log][:int]" for a range.
+func ParseDimension(s string) (Dimension, error) {
+	eq := strings.Index(s, "=")
+	if eq < 1 {
+		return Dimension{}, fmt.Errorf("dimension %q is not <param>=<value>,<value>,... or <param>=<min>:<max>[:log][:int]", s)
+	}
+	d := Dimension{Name: s[:eq]}
+	if fields := strings.Split(s[eq+1:], ":"); len(fields) > 1 {
+		var err1, err2 error
+		d.Min, err1 = strconv.ParseFloat(fields[0], 64)
+		d.Max, err2 = strconv.ParseFloat(fields[1], 64)
+		if err1 != nil || err2 != nil {
+			return Dimension{}, fmt.Errorf("dimension %q has a bad range", s)
+		}
+		for _, option := range fields[2:] {
+			switch option {
+			case "log":
+				d.Log = true
+			case "int":
+				d.Integer = true
+			default:
+				return Dimension{}, fmt.Errorf("dimension %q has an unknown option %q", s, option)
+			}
+		}
+		return d, d.validate()
+	}
+	for _, v := range strings.Split(s[eq+1:], ",") {
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(v), &value); err != nil || value == nil {
+			return Dimension{}, fmt.Errorf("dimension %q has a bad value %q", s, v)
+		}
+		d.Values = append(d.Values, value)
+	}
+	return d, d.validate()
+}
+
+// validate returns an error if the dimension has no values.
+func (d Dimension) validate() error {
+	switch {
+	case d.Name == "":
+		return fmt.Errorf("dimension has no name")
+	case len(d.Values) > 0:
+		return nil
+	case !(d.Min <= d.Max):
+		return fmt.Errorf("dimension %s has min %v above max %v", d.Name, d.Min, d.Max)
+	case d.Log && d.Min <= 0:
+		return fmt.Errorf("dimension %s is on a log scale, so its min must be positive", d.Name)
+	case d.Integer && math.Ceil(d.Min) > math.Floor(d.Max):
+		return fmt.Errorf("dimension %s has no whole numbers from %v to %v", d.Name, d.Min, d.Max)
+	}
+	return nil
+}
+
+// at returns the value at the fraction u in [0, 1] of the way through the dimension: the choice or
+// whole number of the equal stratum that u falls in, or the number u of the way from Min to Max.
+func (d Dimension) at(u float64) interface{} {
+	if len(d.Values) > 0 {
+		return d.Values[stratum(u, len(d.Values))]
+	}
+	if d.Integer {
+		lo, hi := math.Ceil(d.Min), math.Floor(d.Max)
+		if d.Log {
+			// Strata of equal width on the log scale from lo to hi+1, so each whole number x gets [x, x+1)
+			x := math.Floor(math.Exp(math.Log(lo) + u*(math.Log(hi+1)-math.Log(lo))))
+			return int(math.Min(math.Max(x, lo), hi))
+		}
+		return int(lo) + stratum(u, int(hi-lo)+1)
+	}
+	switch {
+	case u <= 0:
+		return d.Min // Exactly, since the log scale would round it
+	case u >= 1:
+		return d.Max
+	case d.Log:
+		return math.Exp(math.Log(d.Min) + u*(math.Log(d.Max)-math.Log(d.Min)))
+	}
+	return d.Min + u*(d.Max-d.Min)
+}
+
+// stratum returns which of n equal strata of [0, 1] u falls in, counting 1 as in the last.
+func stratum(u float64, n int) int {
+	i := int(u * float64(n))
+	if i >= n {
+		return n - 1
+	}
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// grid returns the values a grid search tries: every choice, every whole number of an integer
+// range with at most points of them, and otherwise points values evenly spaced from Min to Max.
+func (d Dimension) grid(points int) []interface{} {
+	if len(d.Values) > 0 {
+		return d.Values
+	}
+	if points < 2 {
+		return []interface{}{d.at(0.5)}
+	}
+	var values []interface{}
+	for k := 0; k < points; k++ {
+		v := d.at(float64(k) / float64(points-1))
+		if len(values) > 0 && values[len(values)-1] == v {
+			continue // Whole numbers repeat when there are fewer of them than points
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Space is the hyperparameters a sweep searches.
+type Space []Dimension
+
+// Validate returns an error if a dimension has no values or two have the same name.
+func (s Space) Validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("search space has no dimensions")
+	}
+	names := map[string]bool{}
+	for _, d := range s {
+		if err := d.validate(); err != nil {
+			return err
+		}
+		if names[d.Name] {
+			return fmt.Errorf("dimension %s appears twice", d.Name)
+		}
+		names[d.Name] = true
+	}
+	return nil
+}
+
+// at returns the hyperparameters at the point u of the unit hypercube, one coordinate per dimension.
+func (s Space) at(u []float64) experiment.Params {
+	params := experiment.Params{}
+	for i, d := range s {
+		params[d.Name] = d.at(u[i])
+	}
+	return params
+}
+
+// Config is a point of the search space: hyperparameters that are added to the agent's in the
+// spec, overriding those it already has.
+type Config struct {
+	ID     int // Number of the configuration, from 1
+	Params experiment.Params
+}
+
+// Grid returns every combination of the dimensions' grid values, with the first dimension varying
+// slowest. Ranges are tried at points evenly spaced values, or at every whole number of an integer
+// range if there are fewer of them.
+func Grid(space Space, points int) []Config {
+	combos := []experiment.Params{{}}
+	for _, d := range space {
+		var next []experiment.Params
+		for _, p := range combos {
+			for _, v := range d.grid(points) {
+				params := experiment.Params{}
+				for k, x := range p {
+					params[k] = x
+				}
+				params[d.Name] = v
+				next = append(next, params)
+			}
+		}
+		combos = next
+	}
+	configs := make([]Config, len(combos))
+	for i, p := range combos {
+		configs[i] = Config{ID: i + 1, Params: p}
+	}
+	return configs
+}
+
+// Random returns n configurations drawn uniformly from the space, on a log scale for Log ranges.
+func Random(space Space, n int, rng *mathlib.Random) []Config {
+	configs := make([]Config, n)
+	for i := range configs {
+		u := make([]float64, len(space))
+		for j := range u {
+			u[j] = rng.Float64()
+		}
+		configs[i] = Config{ID: i + 1, Params: space.at(u)}
+	}
+	return configs
+}
+
+// LatinHypercube returns n configurations drawn by Latin hypercube sampling (McKay et al., 1979):
+// every dimension is cut into n equal strata and each configuration falls in a different stratum of
+// each, so the search covers every range evenly however few configurations it has.
+func LatinHypercube(space Space, n int, rng *mathlib.Random) []Config {
+	configs := make([]Config, n)
+	for i, u := range latinHypercube(len(space), n, rng) {
+		configs[i] = Config{ID: i + 1, Params: space.at(u)}
+	}
+	return configs
+}
+
+// latinHypercube returns n points of the unit hypercube of dims dimensions drawn by Latin hypercube sampling.
+func latinHypercube(dims int, n int, rng *mathlib.Random) [][]float64 {
+	strata := make([][]int, dims)
+	for j := range strata {
+		strata[j] = permutation(n, rng)
+	}
+	points := make([][]float64, n)
+	for i := range points {
+		points[i] = make([]float64, dims)
+		for j := range points[i] {
+			points[i][j] = (float64(strata[j][i]) + rng.Float64()) / float64(n)
+		}
+	}
+	return points
+}
+
+// permutation returns a uniformly random permutation of 0, ..., n-1.
+func permutation(n int, rng *mathlib.Random) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j := stratum(rng.Float64(), i+1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm
+}
@@ -0,0 +1,316 @@
+// Package sweep searches the hyperparameters of an agent. A Space names the hyperparameters to
+// search and their values, Grid, Random and LatinHypercube enumerate configurations of it, and a
+// Sweep runs the trials of every configuration with the settings of an experiment spec. A
+// BayesianOptimizer instead proposes configurations one at a time from the scores of those before,
+// which finds good ones in fewer runs when trials are expensive, and Hyperband stops the trials of
+// bad configurations early to screen many of them cheaply. The results are written as one tidy
+// table of returns, with a row per configuration, trial and episode, and a summary ranking the
+// configurations by their mean return over the final episodes.
+package sweep
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Sweep is a search of the hyperparameters of one agent of a spec on one of its environments. Every
+// configuration is run with the spec's seed, trials, episodes, parallelism and checkpoints, so all
+// configurations see the same random numbers and a killed sweep resumes where it stopped.
+type Sweep struct {
+	Spec        *experiment.Spec
+	Environment experiment.EnvSpec
+	Agent       experiment.AgentSpec // Hyperparameters that the configurations add to or override
+	Log         io.Writer            // Where the progress of the sweep is written, or nil to write nothing
+}
+
+// New returns the sweep of the agent of the spec labelled agent on the environment labelled env,
+// where an empty label picks the only one. Environments and agents without a label go by their name.
+func New(spec *experiment.Spec, env string, agent string) (*Sweep, error) {
+	s := Sweep{Spec: spec}
+	var envs, agents []string
+	found := 0
+	for _, e := range spec.Environments {
+		envs = append(envs, e.LabelOrName())
+		if env == "" || env == e.LabelOrName() {
+			s.Environment = e
+			found++
+		}
+	}
+	if found != 1 {
+		return nil, fmt.Errorf("pick one of the environments %s", strings.Join(envs, ", "))
+	}
+	found = 0
+	for _, a := range spec.Agents {
+		agents = append(agents, a.LabelOrName())
+		if agent == "" || agent == a.LabelOrName() {
+			s.Agent = a
+			found++
+		}
+	}
+	if found != 1 {
+		return nil, fmt.Errorf("pick one of the agents %s", strings.Join(agents, ", "))
+	}
+	return &s, nil
+}
+
+// logf writes a line of progress to s.Log, if there is one.
+func (s *Sweep) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
+
+// Name returns the name of the sweep's results: the spec's name, or the agent's label if it has none.
+func (s *Sweep) Name() string {
+	if s.Spec.Name != "" {
+		return s.Spec.Name
+	}
+	return s.Agent.LabelOrName()
+}
+
+// agent returns the agent of the configuration.
+func (s *Sweep) agent(c Config) experiment.AgentSpec {
+	params := experiment.Params{}
+	for k, v := range s.Agent.Params {
+		params[k] = v
+	}
+	for k, v := range c.Params {
+		params[k] = v
+	}
+	return experiment.AgentSpec{Name: s.Agent.Name, Label: fmt.Sprintf("%s_config%d", s.Agent.LabelOrName(), c.ID), Params: params}
+}
+
+// Result is the returns of every trial of a configuration, so Returns(i,j) = the return on the j'th
+// episode of the i'th trial. The returns of trials that did not finish are nil.
+type Result struct {
+	Config  Config
+	Returns [][]float64
+}
+
+// Run runs the trials of each configuration in turn and returns their results. If a configuration
+// fails or ctx is cancelled, Run stops and returns the results so far, including the current
+// configuration if any of its trials finished, with the error.
+func (s *Sweep) Run(ctx context.Context, configs []Config) ([]Result, error) {
+	var results []Result
+	for i, c := range configs {
+		s.logf("Configuration %d of %d: %s", i+1, len(configs), formatParams(c.Params))
+		r, err := s.run(ctx, c)
+		if len(r.Returns) > 0 {
+			results = append(results, r)
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Optimize runs n configurations proposed by opt, one at a time, and tells opt the score of each:
+// the mean over its trials of their mean return over the final window episodes. Like Run, it stops
+// at the first configuration that fails.
+func (s *Sweep) Optimize(ctx context.Context, opt *BayesianOptimizer, n int, window int) ([]Result, error) {
+	var results []Result
+	for i := 0; i < n; i++ {
+		c := opt.Propose()
+		s.logf("Configuration %d of %d: %s", i+1, n, formatParams(c.Params))
+		r, err := s.run(ctx, c)
+		if len(r.Returns) > 0 {
+			results = append(results, r)
+		}
+		if err != nil {
+			return results, err
+		}
+		if err := opt.Observe(c, Summarize([]Result{r}, window)[0].Mean); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// run runs the trials of a configuration. The result has no returns unless a trial finished.
+func (s *Sweep) run(ctx context.Context, c Config) (Result, error) {
+	returns, err := s.Spec.Returns(ctx, s.Environment, s.agent(c), s.Name()+"_config"+strconv.Itoa(c.ID))
+	r := Result{Config: c}
+	for _, trial := range returns {
+		if trial != nil {
+			r.Returns = returns
+			break
+		}
+	}
+	if err != nil {
+		return r, fmt.Errorf("configuration %d: %w", c.ID, err)
+	}
+	return r, nil
+}
+
+// formatParams returns the hyperparameters as <name>=<value> in the order of their names.
+func formatParams(params experiment.Params) string {
+	var fields []string
+	for _, name := range paramNames([]Config{{Params: params}}) {
+		fields = append(fields, name+"="+formatValue(params[name]))
+	}
+	return strings.Join(fields, " ")
+}
+
+// paramNames returns the names of the hyperparameters of any of the configurations, sorted.
+func paramNames(configs []Config) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, c := range configs {
+		for name := range c.Params {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatValue returns a hyperparameter as it would be written in a spec file.
+func formatValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// WriteTable writes the results as CSV with the columns config, one per hyperparameter, trial,
+// episode and return, and a row for every episode of every trial that finished. Configurations,
+// trials and episodes are numbered from 1.
+func WriteTable(w io.Writer, results []Result) error {
+	configs := make([]Config, len(results))
+	for i, r := range results {
+		configs[i] = r.Config
+	}
+	names := paramNames(configs)
+
+	out := csv.NewWriter(w)
+	out.Write(append(append([]string{"config"}, names...), "trial", "episode", "return"))
+	for _, r := range results {
+		row := []string{strconv.Itoa(r.Config.ID)}
+		for _, name := range names {
+			row = append(row, formatValue(r.Config.Params[name]))
+		}
+		n := len(row)
+		for trial, returns := range r.Returns {
+			for ep, ret := range returns {
+				row = append(row[:n], strconv.Itoa(trial+1), strconv.Itoa(ep+1), strconv.FormatFloat(ret, 'g', -1, 64))
+				out.Write(row)
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Summary is how well a configuration did: the mean over its trials of the mean return of each
+// trial over the final episodes, with a 95% confidence interval.
+type Summary struct {
+	Config   Config
+	Trials   int // Trials that ran
+	Episodes int // Episodes of the longest trial, fewer than the others' if the configuration was stopped early
+	Mean     float64
+	Low      float64 // Bounds of the confidence interval, which is unbounded with fewer than two trials
+	High     float64
+}
+
+// Summarize returns the summaries of the results, best first, taking the mean return of each trial
+// over its final window episodes. Configurations that ran more episodes rank above those that were
+// stopped early, since returns tend to rise as agents learn. Configurations without a trial are left out.
+func Summarize(results []Result, window int) []Summary {
+	var summaries []Summary
+	for _, r := range results {
+		var finals []float64
+		episodes := 0
+		for _, returns := range r.Returns {
+			if len(returns) > 0 {
+				finals = append(finals, finalMean(returns, window))
+			}
+			if len(returns) > episodes {
+				episodes = len(returns)
+			}
+		}
+		if len(finals) == 0 {
+			continue
+		}
+		s := Summary{Config: r.Config, Trials: len(finals), Episodes: episodes, Mean: mathlib.Mean(finals), Low: math.Inf(-1), High: math.Inf(1)}
+		if len(finals) > 1 {
+			halfWidth := tCritical95(len(finals)-1) * mathlib.StdError(finals)
+			s.Low, s.High = s.Mean-halfWidth, s.Mean+halfWidth
+		}
+		summaries = append(summaries, s)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].Episodes != summaries[j].Episodes {
+			return summaries[i].Episodes > summaries[j].Episodes
+		}
+		return summaries[i].Mean > summaries[j].Mean
+	})
+	return summaries
+}
+
+// finalMean returns the mean of the last window returns, or of all of them if there are fewer.
+func finalMean(returns []float64, window int) float64 {
+	start := len(returns) - window
+	if start < 0 || window < 1 {
+		start = 0
+	}
+	return mathlib.Mean(returns[start:])
+}
+
+// tCritical95 returns the 97.5th percentile of Student's t-distribution with df degrees of freedom,
+// from a table that rounds df down, so the intervals it gives are never too narrow.
+func tCritical95(df int) float64 {
+	table := []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	switch {
+	case df <= len(table):
+		return table[df-1]
+	case df < 40:
+		return table[len(table)-1]
+	case df < 60:
+		return 2.021
+	case df < 120:
+		return 2.000
+	}
+	return 1.980
+}
+
+// WriteSummary writes the summaries as CSV with the columns rank, config, one per hyperparameter,
+// trials, episodes, mean, ci_low and ci_high.
+func WriteSummary(w io.Writer, summaries []Summary) error {
+	configs := make([]Config, len(summaries))
+	for i, s := range summaries {
+		configs[i] = s.Config
+	}
+	names := paramNames(configs)
+
+	out := csv.NewWriter(w)
+	out.Write(append(append([]string{"rank", "config"}, names...), "trials", "episodes", "mean", "ci_low", "ci_high"))
+	for rank, s := range summaries {
+		row := []string{strconv.Itoa(rank + 1), strconv.Itoa(s.Config.ID)}
+		for _, name := range names {
+			row = append(row, formatValue(s.Config.Params[name]))
+		}
+		row = append(row, strconv.Itoa(s.Trials), strconv.Itoa(s.Episodes), strconv.FormatFloat(s.Mean, 'g', -1, 64),
+			strconv.FormatFloat(s.Low, 'g', -1, 64), strconv.FormatFloat(s.High, 'g', -1, 64))
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
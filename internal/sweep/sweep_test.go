@@ -0,0 +1,290 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/internal/experiment"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSpec = `
+name: test
+seed: 1
+trials: 3
+episodes: 30
+environments:
+  - name: gridworld
+    wrappers: [{name: time_limit, params: {steps: 20}}]
+agents:
+  - name: sarsa
+    params: {alpha: 0.1, policy: epsilon_greedy, epsilon: 0.2}
+  - name: bbo
+    params: {n: 2}
+`
+
+func TestParseDimension(t *testing.T) {
+	d, err := ParseDimension("alpha=0.001:0.1:log")
+	assert.NoError(t, err)
+	assert.Equal(t, Dimension{Name: "alpha", Min: 0.001, Max: 0.1, Log: true}, d)
+	d, err = ParseDimension("policy=softmax,epsilon_greedy")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"softmax", "epsilon_greedy"}, d.Values)
+
+	for _, bad := range []string{"alpha", "alpha=1:0", "alpha=0:1:log", "n=1.2:1.8:int", "alpha=0:1:cubic", "=1,2"} {
+		_, err := ParseDimension(bad)
+		assert.Error(t, err, bad)
+	}
+	assert.Error(t, Space{{Name: "n", Min: 1, Max: 2}, {Name: "n", Values: []interface{}{1}}}.Validate())
+}
+
+func TestGrid(t *testing.T) {
+	space := Space{
+		{Name: "alpha", Min: 0.001, Max: 0.1, Log: true},
+		{Name: "n", Min: 1, Max: 2, Integer: true},
+		{Name: "policy", Values: []interface{}{"softmax", "greedy"}},
+	}
+	configs := Grid(space, 3)
+	assert.Len(t, configs, 3*2*2) // n has only two whole numbers
+	assert.Equal(t, Config{ID: 1, Params: experiment.Params{"alpha": 0.001, "n": 1, "policy": "softmax"}}, configs[0])
+	assert.InDelta(t, 0.01, configs[4].Params["alpha"], 1e-12)
+	assert.Equal(t, 0.1, configs[11].Params["alpha"])
+}
+
+func TestSampling(t *testing.T) {
+	space := Space{
+		{Name: "alpha", Min: 0.001, Max: 0.1, Log: true},
+		{Name: "n", Min: 1, Max: 10, Integer: true},
+		{Name: "policy", Values: []interface{}{"softmax", "greedy"}},
+	}
+	for _, configs := range [][]Config{Random(space, 50, mathlib.NewRandom(0)), LatinHypercube(space, 50, mathlib.NewRandom(0))} {
+		assert.Len(t, configs, 50)
+		for _, c := range configs {
+			alpha := c.Params["alpha"].(float64)
+			assert.True(t, alpha >= 0.001 && alpha <= 0.1)
+			n := c.Params["n"].(int)
+			assert.True(t, n >= 1 && n <= 10)
+		}
+	}
+
+	// Latin hypercube sampling puts one configuration in each of n strata of every dimension
+	configs := LatinHypercube(space, 10, mathlib.NewRandom(1))
+	var ns []int
+	decades := map[int]int{}
+	for _, c := range configs {
+		ns = append(ns, c.Params["n"].(int))
+		decades[int(math.Floor(5*(math.Log10(c.Params["alpha"].(float64))+3)))]++
+	}
+	assert.ElementsMatch(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, ns)
+	assert.Equal(t, map[int]int{0: 1, 1: 1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 7: 1, 8: 1, 9: 1}, decades)
+
+	// The same seed gives the same configurations
+	assert.Equal(t, configs, LatinHypercube(space, 10, mathlib.NewRandom(1)))
+}
+
+func TestSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sweep")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	spec, err := experiment.ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+	spec.OutputDir = dir
+	spec.Checkpoint = 2
+
+	_, err = New(spec, "", "")
+	assert.EqualError(t, err, "pick one of the agents sarsa, bbo")
+	s, err := New(spec, "", "sarsa")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", s.Name())
+
+	configs := Grid(Space{{Name: "epsilon", Values: []interface{}{0.2, 0.5}}}, 0)
+	configs = append(configs, Config{ID: 3, Params: experiment.Params{"epsilon": 0.2}})
+	var log bytes.Buffer
+	s.Log = &log
+	results, err := s.Run(context.Background(), configs)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Contains(t, log.String(), "Configuration 2 of 3: epsilon=0.5\n")
+	for _, r := range results {
+		assert.Len(t, r.Returns, 3)
+		assert.Len(t, r.Returns[0], 30)
+	}
+	// Every configuration gets the same random numbers
+	assert.Equal(t, results[0].Returns, results[2].Returns)
+	assert.NotEqual(t, results[0].Returns, results[1].Returns)
+
+	var table bytes.Buffer
+	assert.NoError(t, WriteTable(&table, results))
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	assert.Len(t, lines, 1+3*3*30)
+	assert.Equal(t, "config,epsilon,trial,episode,return", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1+3*30], "2,0.5,1,1,"))
+
+	summaries := Summarize(results, 3)
+	assert.Len(t, summaries, 3)
+	for i, sum := range summaries {
+		if i > 0 {
+			assert.True(t, sum.Mean <= summaries[i-1].Mean)
+		}
+		assert.True(t, sum.Low <= sum.Mean && sum.Mean <= sum.High)
+		assert.Equal(t, 3, sum.Trials)
+	}
+	var finals []float64
+	for _, returns := range results[1].Returns {
+		finals = append(finals, mathlib.Mean(returns[27:]))
+	}
+	for _, sum := range summaries {
+		if sum.Config.ID == 2 {
+			assert.InDelta(t, mathlib.Mean(finals), sum.Mean, 1e-12)
+			assert.InDelta(t, 4.303*mathlib.StdError(finals), sum.High-sum.Mean, 1e-12)
+		}
+	}
+
+	// A sweep run again reads the configurations that finished from their checkpoints
+	again, err := s.Run(context.Background(), configs)
+	assert.NoError(t, err)
+	assert.Equal(t, results, again)
+
+	// A cancelled sweep stops at the first configuration
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Spec.Checkpoint = 0
+	results, err = s.Run(ctx, configs)
+	assert.True(t, err != nil && strings.HasPrefix(err.Error(), "configuration 1: "))
+	assert.Empty(t, results)
+}
+
+func TestTCritical95(t *testing.T) {
+	assert.Equal(t, 12.706, tCritical95(1))
+	assert.Equal(t, 2.042, tCritical95(35))
+	assert.Equal(t, 1.980, tCritical95(1000))
+}
+
+func TestGaussianProcess(t *testing.T) {
+	assert.Equal(t, 1.0, RBF(0))
+	assert.InDelta(t, 1.0, Matern52(0), 1e-12)
+	assert.True(t, Matern52(1) < Matern52(0.5))
+
+	x := [][]float64{{0.1}, {0.3}, {0.5}, {0.9}}
+	y := []float64{1, 3, 2, -1}
+	for _, kernel := range []Kernel{RBF, Matern52} {
+		gp, err := fitGP(kernel, x, y)
+		assert.NoError(t, err)
+		// The scores are hardly noisy, so the posterior passes through them
+		for i := range x {
+			mean, std := gp.predict(x[i])
+			assert.InDelta(t, y[i], gp.mean+gp.scale*mean, 0.1)
+			assert.True(t, std < 0.2)
+		}
+		// It is less sure between them
+		_, std := gp.predict([]float64{0.7})
+		_, near := gp.predict([]float64{0.31})
+		assert.True(t, std > near)
+	}
+	_, err := fitGP(RBF, x[:1], y[:1])
+	assert.Error(t, err)
+
+	assert.InDelta(t, 0.0, expectedImprovement(0, 0, 1, 0), 1e-12)
+	assert.InDelta(t, 1.0, expectedImprovement(2, 0, 1, 0), 1e-12)
+	assert.InDelta(t, mathlib.NormalPDF(0), expectedImprovement(1, 1, 1, 0), 1e-12)
+}
+
+func TestBayesianOptimizer(t *testing.T) {
+	space := Space{{Name: "x", Min: 0, Max: 1}, {Name: "y", Min: 0, Max: 1}}
+	score := func(c Config) float64 {
+		x, y := c.Params["x"].(float64), c.Params["y"].(float64)
+		return -(x-0.3)*(x-0.3) - (y-0.7)*(y-0.7)
+	}
+
+	// Bayesian optimization gets closer to the maximum than Latin hypercube sampling with the same budget
+	opt := NewBayesianOptimizer(space, Matern52, mathlib.NewRandom(0))
+	best, lhsBest := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < 25; i++ {
+		c := opt.Propose()
+		assert.Equal(t, i+1, c.ID)
+		assert.NoError(t, opt.Observe(c, score(c)))
+		best = math.Max(best, score(c))
+	}
+	for _, c := range LatinHypercube(space, 25, mathlib.NewRandom(0)) {
+		lhsBest = math.Max(lhsBest, score(c))
+	}
+	assert.True(t, best > -0.001, "best %g", best)
+	assert.True(t, best > lhsBest, "best %g, Latin hypercube %g", best, lhsBest)
+	assert.Error(t, opt.Observe(Config{ID: 3}, 0))
+
+	// A sweep tells the optimizer the score of every configuration it runs
+	spec, err := experiment.ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+	s, err := New(spec, "", "sarsa")
+	assert.NoError(t, err)
+	opt = NewBayesianOptimizer(Space{{Name: "epsilon", Min: 0.01, Max: 0.5}}, RBF, mathlib.NewRandom(0))
+	opt.InitialPoints = 2
+	results, err := s.Optimize(context.Background(), opt, 3, 5)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Len(t, opt.y, 3)
+	assert.Equal(t, Summarize(results[2:], 5)[0].Mean, opt.y[2])
+}
+
+func TestHyperband(t *testing.T) {
+	h := Hyperband{MinEpisodes: 2, MaxEpisodes: 18, Eta: 3, Window: 2}
+	assert.Equal(t, []int{2, 6, 18}, h.rungs(2))
+	assert.Equal(t, []int{18}, h.rungs(18))
+	assert.Error(t, Hyperband{MinEpisodes: 2, MaxEpisodes: 18, Eta: 1}.validate())
+
+	spec, err := experiment.ParseSpec(strings.NewReader(testSpec))
+	assert.NoError(t, err)
+	spec.Episodes = 18
+	s, err := New(spec, "", "sarsa")
+	assert.NoError(t, err)
+	space := Space{{Name: "epsilon", Min: 0.01, Max: 0.9}}
+
+	// Successive halving keeps a third of the configurations at each rung
+	configs := LatinHypercube(space, 9, mathlib.NewRandom(0))
+	results, err := s.SuccessiveHalving(context.Background(), configs, h)
+	assert.NoError(t, err)
+	assert.Len(t, results, 9)
+	episodes := map[int]int{}
+	var survivor Result
+	for _, r := range results {
+		assert.Len(t, r.Returns, 3)
+		episodes[len(r.Returns[0])]++
+		if len(r.Returns[0]) == 18 {
+			survivor = r
+		}
+	}
+	assert.Equal(t, map[int]int{2: 6, 6: 2, 18: 1}, episodes)
+	summaries := Summarize(results, 2)
+	assert.Equal(t, survivor.Config.ID, summaries[0].Config.ID)
+	assert.Equal(t, 6, summaries[1].Episodes)
+
+	// The survivor's trials were continued, so they match a sweep of all its episodes
+	full, err := s.Run(context.Background(), []Config{survivor.Config})
+	assert.NoError(t, err)
+	assert.Equal(t, full[0].Returns, survivor.Returns)
+
+	// Hyperband runs brackets of 9, 5 and 3 configurations starting at 2, 6 and 18 episodes
+	results, err = s.Hyperband(context.Background(), space, h, mathlib.NewRandom(0))
+	assert.NoError(t, err)
+	assert.Len(t, results, 9+5+3)
+	assert.Equal(t, 17, results[16].Config.ID)
+	episodes = map[int]int{}
+	for _, r := range results {
+		episodes[len(r.Returns[0])]++
+	}
+	assert.Equal(t, map[int]int{2: 6, 6: 2 + 4, 18: 1 + 1 + 3}, episodes)
+
+	// An interrupted search returns the configurations that ran
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = s.SuccessiveHalving(ctx, configs, h)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, results)
+}
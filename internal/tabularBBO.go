@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"math"
+	"encoding/json"
 
 	"github.com/jackkenney/evolve-rl/mathlib"
 )
@@ -19,14 +19,19 @@ type TabularBBO struct {
 
 	newTheta     [][]float64 // The policy we're currently running and thinking of switching curTheta to
 	newThetaJHat float64
+
+	policy ExplorationPolicy // Selects actions from the action preferences of newTheta
 }
 
 // NewTabularBBO returns an initialized TabularBBO object.
-func NewTabularBBO(stateDim int, numActions int, gamma float64, N int) Agent {
+func NewTabularBBO(stateDim int, numActions int, gamma float64, N int, policy ExplorationPolicy) Agent {
 	bbo := TabularBBO{}
 
 	bbo.ep = NewEpisodeTracker(N)
 	bbo.numStates = stateDim
+	bbo.numActions = numActions
+	bbo.gamma = gamma
+	bbo.policy = policy
 
 	bbo.newTheta = make([][]float64, bbo.numStates)
 	bbo.curTheta = make([][]float64, bbo.numStates)
@@ -48,38 +53,79 @@ func (bbo *TabularBBO) EpisodicAgent() bool {
 	return true
 }
 
-// GetAction returns the action that the agent selects from the state.
+// GetAction returns the action selected by the exploration policy from the action preferences of the state.
 func (bbo *TabularBBO) GetAction(s []float64, rng *mathlib.Random) int {
 	// Convert the one-hot state into an integer from 0 - (numStates-1)
 	state := mathlib.FromOneHot(s)
-	// Get the action probabilities from theta, using softmax action selection.
-	actionProbabilities := bbo.newTheta[state]
-	denominator := 0.0
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] = math.Exp(actionProbabilities[a])
-		denominator += actionProbabilities[a]
-	}
-	for a := 0; a < len(actionProbabilities); a++ {
-		actionProbabilities[a] /= denominator
-	}
-	// Select random action from softmax
-	temp := rng.Float64()
-	sum := 0.0
-	for a := 0; a < bbo.numActions; a++ {
-		sum += actionProbabilities[a]
-		if temp <= sum {
-			return a // The function will return 'a'. This stops the for loop and returns from the function.
-		}
-	}
-	return bbo.numActions - 1 // Rounding error
+	return bbo.policy.SelectAction(state, bbo.newTheta[state], rng)
 }
 
 // NewEpisode tells the agent that it is at the start of a new episode.
 func (bbo *TabularBBO) NewEpisode() {}
 
+// Supports returns an error unless observations are one-hot states and actions are discrete.
+func (bbo *TabularBBO) Supports(observation Space, action Space) error {
+	return supportsTabular(bbo.numStates, bbo.numActions, observation, action)
+}
+
 // Reset the agent entirely - to a blank slate prior to learning
 func (bbo *TabularBBO) Reset(rng *mathlib.Random) {
 	bbo.ep.Wipe()
+	bbo.policy.Reset()
+}
+
+// tabularBBOSnapshot is the learned state of a TabularBBO agent.
+type tabularBBOSnapshot struct {
+	CurTheta     [][]float64     `json:"cur_theta"`
+	CurThetaJHat float64         `json:"cur_theta_jhat"`
+	NewTheta     [][]float64     `json:"new_theta"`
+	NewThetaJHat float64         `json:"new_theta_jhat"`
+	Tracker      json.RawMessage `json:"tracker"`
+	Policy       json.RawMessage `json:"policy,omitempty"`
+}
+
+// Snapshot returns both policies and their estimates, the episode tracker and the state of the exploration policy.
+func (bbo *TabularBBO) Snapshot() ([]byte, error) {
+	tracker, err := bbo.ep.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := SnapshotOf(bbo.policy)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tabularBBOSnapshot{
+		CurTheta:     bbo.curTheta,
+		CurThetaJHat: bbo.curThetaJHat,
+		NewTheta:     bbo.newTheta,
+		NewThetaJHat: bbo.newThetaJHat,
+		Tracker:      tracker,
+		Policy:       policy,
+	})
+}
+
+// Restore sets both policies and their estimates, the episode tracker and the state of the
+// exploration policy to a snapshot.
+func (bbo *TabularBBO) Restore(data []byte) error {
+	snap := tabularBBOSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := checkMatrix("current theta", snap.CurTheta, bbo.numStates, bbo.numActions); err != nil {
+		return err
+	}
+	if err := checkMatrix("new theta", snap.NewTheta, bbo.numStates, bbo.numActions); err != nil {
+		return err
+	}
+	if err := bbo.ep.Restore(snap.Tracker); err != nil {
+		return err
+	}
+	if err := RestoreSnapshot(bbo.policy, snap.Policy); err != nil {
+		return err
+	}
+	bbo.curTheta, bbo.curThetaJHat = snap.CurTheta, snap.CurThetaJHat
+	bbo.newTheta, bbo.newThetaJHat = snap.NewTheta, snap.NewThetaJHat
+	return nil
 }
 
 // UpdateSARS is unimplemented for this class.
@@ -101,6 +147,11 @@ func (bbo *TabularBBO) LastUpdate(s []float64, a int, r float64, rng *mathlib.Ra
 	}
 }
 
+// TruncatedUpdate ends the episode like LastUpdate. Monte Carlo returns can't bootstrap from sPrime.
+func (bbo *TabularBBO) TruncatedUpdate(s []float64, a int, r float64, sPrime []float64, rng *mathlib.Random) {
+	bbo.LastUpdate(s, a, r, rng)
+}
+
 func (bbo *TabularBBO) episodeLimitReached(rng *mathlib.Random) {
 	bbo.episodicUpdate(rng)
 	bbo.ep.Wipe()
@@ -13,7 +13,7 @@ var (
 
 func init() {
 	rng = mathlib.NewRandom(0)
-	agt = NewTabularBBO(23, 4, 0.9, 1).(Agent)
+	agt = NewTabularBBO(23, 4, 0.9, 1, NewSoftmax(1)).(Agent)
 	env = NewGridworld(rng)
 }
 
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCliffWalking(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	cliff := NewCliffWalking(r)
+	assert.Equal(t, 36, mathlib.FromOneHot(cliff.GetState()))
+
+	// Stepping right from the start falls off the cliff
+	assert.Equal(t, -100.0, cliff.Transition(1, r))
+	assert.Equal(t, 36, mathlib.FromOneHot(cliff.GetState()))
+
+	// Up, eleven steps right and down is the optimal path
+	total := cliff.Transition(0, r)
+	for i := 0; i < 11; i++ {
+		total += cliff.Transition(1, r)
+	}
+	total += cliff.Transition(2, r)
+	assert.Equal(t, -13.0, total)
+	assert.True(t, cliff.InTAS())
+}
+
+func TestWindyGridworld(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	windy := NewWindyGridworld(false, r)
+
+	// The optimal path of Example 6.5 takes 15 steps
+	path := []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3}
+	for _, action := range path {
+		assert.False(t, windy.InTAS())
+		windy.Transition(action, r)
+	}
+	assert.True(t, windy.InTAS())
+
+	// Stochastic wind in a windy column gives different outcomes
+	outcomes := map[int]bool{}
+	stochastic := NewWindyGridworld(true, r).(*WindyGridworld)
+	for i := 0; i < 100; i++ {
+		stochastic.x, stochastic.y = 6, 5
+		stochastic.Transition(1, r)
+		outcomes[stochastic.y] = true
+	}
+	assert.Len(t, outcomes, 3)
+}
+
+func TestFrozenLake(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	lake := NewFrozenLake(FrozenLake4x4, false, r)
+	assert.Equal(t, 16, lake.GetStateDim())
+
+	// Down, down, right, right, down, right avoids the holes
+	rewards := 0.0
+	for _, action := range []int{2, 2, 1, 1, 2, 1} {
+		assert.False(t, lake.InTAS())
+		rewards += lake.Transition(action, r)
+	}
+	assert.Equal(t, 1.0, rewards)
+	assert.True(t, lake.InTAS())
+
+	// Falling in a hole ends the episode with no reward
+	lake.NewEpisode(r)
+	assert.Equal(t, 0.0, lake.Transition(1, r))
+	assert.Equal(t, 0.0, lake.Transition(2, r))
+	assert.True(t, lake.InTAS())
+
+	assert.Equal(t, 64, NewFrozenLake(FrozenLake8x8, true, r).GetStateDim())
+}
+
+func TestTaxi(t *testing.T) {
+	r := mathlib.NewRandom(0)
+	taxi := NewTaxi(r).(*Taxi)
+	assert.Equal(t, 500, taxi.GetStateDim())
+
+	taxi.row, taxi.col, taxi.passenger, taxi.destination = 0, 0, 0, 1
+	assert.Equal(t, taxiWrongReward, taxi.Transition(taxiDropoff, r))
+	assert.Equal(t, taxiStepReward, taxi.Transition(taxiPickup, r))
+	assert.Equal(t, taxiInTaxi, taxi.passenger)
+
+	// The wall east of column 1 blocks the top row
+	taxi.Transition(1, r)
+	taxi.Transition(1, r)
+	assert.Equal(t, 1, taxi.col)
+
+	// Drive around the wall to G
+	for _, action := range []int{2, 2, 1, 1, 1, 0, 0} {
+		taxi.Transition(action, r)
+	}
+	assert.Equal(t, [2]int{0, 4}, [2]int{taxi.row, taxi.col})
+	assert.Equal(t, taxiGoalReward, taxi.Transition(taxiDropoff, r))
+	assert.True(t, taxi.InTAS())
+
+	// Every state index is in range
+	for i := 0; i < 100; i++ {
+		taxi.NewEpisode(r)
+		assert.NotEqual(t, taxi.passenger, taxi.destination)
+		digits := mathlib.FromOneHotMixedRadix(taxi.GetState(), taxiRadices)
+		assert.Equal(t, []int{taxi.row, taxi.col, taxi.passenger, taxi.destination}, digits)
+	}
+}
@@ -0,0 +1,141 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// taxiMap is the Taxi domain of Dietterich (2000). '|' is a wall between two cells.
+var taxiMap = []string{
+	"+---------+",
+	"|R: | : :G|",
+	"| : | : : |",
+	"| : : : : |",
+	"| | : | : |",
+	"|Y| : |B: |",
+	"+---------+",
+}
+
+// taxiRadices are the sizes of the (taxi row, taxi column, passenger location, destination) state.
+var taxiRadices = []int{taxiSize, taxiSize, 5, 4}
+
+// taxiLocations are the (row, col) of the R, G, Y and B pick-up/drop-off locations.
+var taxiLocations = [4][2]int{{0, 0}, {0, 4}, {4, 0}, {4, 3}}
+
+const (
+	taxiSize        = 5
+	taxiInTaxi      = 4 // Passenger location when the passenger is in the taxi
+	taxiPickup      = 4
+	taxiDropoff     = 5
+	taxiNumActions  = 6
+	taxiStepReward  = -1.0
+	taxiGoalReward  = 20.0
+	taxiWrongReward = -10.0
+)
+
+// Taxi is the Taxi domain of Dietterich (2000) as in OpenAI Gym. A taxi on a 5x5 grid picks up a
+// passenger at one of four locations and drops them off at another. Every step costs -1, an illegal
+// pick-up or drop-off costs -10 and a successful drop-off pays +20 and ends the episode.
+// Actions are north (0), east (1), south (2), west (3), pick-up (4) and drop-off (5).
+// The state (taxi row, taxi column, passenger location, destination) is one-hot encoded over 500 states.
+type Taxi struct {
+	row, col    int // Taxi position
+	passenger   int // Index into taxiLocations, or taxiInTaxi
+	destination int // Index into taxiLocations
+	done        bool
+}
+
+// NewTaxi returns a new Taxi Environment object.
+func NewTaxi(rng *mathlib.Random) Environment {
+	env := Taxi{}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *Taxi) GetMaxEps() int {
+	return 2000
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *Taxi) GetStateDim() int {
+	return mathlib.MixedRadixCapacity(taxiRadices)
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *Taxi) GetNumActions() int {
+	return taxiNumActions
+}
+
+// GetGamma returns \gamma
+func (env *Taxi) GetGamma() float64 {
+	return 0.99
+}
+
+// locationAt returns the index of the location the taxi is at, or -1.
+func (env *Taxi) locationAt() int {
+	for i, loc := range taxiLocations {
+		if loc[0] == env.row && loc[1] == env.col {
+			return i
+		}
+	}
+	return -1
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *Taxi) Transition(a int, rng *mathlib.Random) float64 {
+	switch a {
+	case 0:
+		if env.row > 0 {
+			env.row--
+		}
+	case 2:
+		if env.row < taxiSize-1 {
+			env.row++
+		}
+	case 1:
+		if taxiMap[env.row+1][2*env.col+2] == ':' {
+			env.col++
+		}
+	case 3:
+		if taxiMap[env.row+1][2*env.col] == ':' {
+			env.col--
+		}
+	case taxiPickup:
+		loc := env.locationAt()
+		if env.passenger == taxiInTaxi || loc != env.passenger {
+			return taxiWrongReward
+		}
+		env.passenger = taxiInTaxi
+	case taxiDropoff:
+		loc := env.locationAt()
+		if env.passenger != taxiInTaxi || loc == -1 {
+			return taxiWrongReward
+		}
+		env.passenger = loc
+		if loc == env.destination {
+			env.done = true
+			return taxiGoalReward
+		}
+	}
+	return taxiStepReward
+}
+
+// GetState returns the one-hot encoding of (taxi row, taxi column, passenger location, destination).
+func (env *Taxi) GetState() []float64 {
+	return mathlib.ToOneHotMixedRadix([]int{env.row, env.col, env.passenger, env.destination}, taxiRadices)
+}
+
+// InTAS returns whether the passenger has been delivered.
+func (env *Taxi) InTAS() bool {
+	return env.done
+}
+
+// NewEpisode places the taxi uniformly at random and picks different passenger and destination locations.
+func (env *Taxi) NewEpisode(rng *mathlib.Random) {
+	env.row = int(rng.Float64() * taxiSize)
+	env.col = int(rng.Float64() * taxiSize)
+	env.passenger = int(rng.Float64() * 4)
+	env.destination = int(rng.Float64() * 3)
+	if env.destination >= env.passenger {
+		env.destination++
+	}
+	env.done = false
+}
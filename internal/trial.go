@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Trial is a trial that can be run a few episodes at a time, continuing where it stopped, e.g. so
+// that a scheduler can give more episodes to the agents that learn best and stop the others early.
+// Running a trial to n episodes and then to m > n gives the same returns as running it to m at once.
+type Trial struct {
+	agt     Agent
+	env     Environment
+	gamma   float64
+	rngs    streams
+	returns []float64
+	err     error // Why the trial failed, after which it runs no more
+}
+
+// NewTrial returns a trial of a new agent in env. The agent is Reset, and it and the environment
+// draw from their own streams split off rng, as in a trial of RunTrials.
+func NewTrial(agt Agent, env Environment, rng *mathlib.Random) *Trial {
+	t := Trial{agt: agt, env: env, gamma: env.GetGamma(), rngs: splitStreams(rng)}
+	agt.Reset(t.rngs.agent)
+	return &t
+}
+
+// Returns returns the return of every episode run so far.
+func (t *Trial) Returns() []float64 {
+	return t.returns
+}
+
+// RunTo runs episodes until the trial has run numEps in all, and returns an error if the
+// environment failed or if ctx is cancelled first. A trial cancelled between episodes can be run
+// again, but one cancelled in the middle of an episode fails for good, since its agent learned
+// from part of the episode.
+func (t *Trial) RunTo(ctx context.Context, numEps int) error {
+	if t.err != nil {
+		return t.err
+	}
+	var abandoned bool
+	var err error
+	t.returns, abandoned, err = runEpisodes(ctx, t.agt, t.env, t.returns, numEps, t.gamma, t.rngs)
+	if envErr := envError(t.env); envErr != nil {
+		t.err = envErr
+		return envErr
+	}
+	if abandoned {
+		t.err = fmt.Errorf("cancelled in the middle of episode %d", len(t.returns)+1)
+	}
+	return err
+}
+
+// Close closes the trial's environment if it is an io.Closer, e.g. to end a remote session. The
+// trial can't be run after.
+func (t *Trial) Close() {
+	closeEnvironment(t.env)
+}
+
+// NewTrials returns the trials RunTrials would run, built from the constructors in the same order,
+// so running them all to the same number of episodes with RunTrialsTo gives the same returns. It
+// returns an error if the agent does not support the environment's spaces. Close the trials once
+// they are no longer run.
+func NewTrials(rng *mathlib.Random,
+	agentConstructor agentConstructor,
+	envConstructor environmentConstructor,
+	numTrials int,
+) ([]*Trial, error) {
+	if _, _, err := probe(agentConstructor, envConstructor); err != nil {
+		return nil, err
+	}
+	trials := make([]*Trial, numTrials)
+	for i := range trials {
+		env, agt := envConstructor(), agentConstructor()
+		trials[i] = NewTrial(agt, env, rng)
+	}
+	return trials, nil
+}
+
+// RunTrialsTo runs the trials in parallel until each has run numEps episodes, at most parallelism
+// at a time (one per CPU if parallelism < 1). Failures and cancellation are reported as by
+// RunTrialsContext, with a *TrialsError. A trial that panics fails for good.
+func RunTrialsTo(ctx context.Context, trials []*Trial, numEps int, parallelism int) error {
+	_, errs := runParallelTrials(ctx, len(trials), parallelism, nil, func(i int) func() ([]float64, error) {
+		return func() ([]float64, error) {
+			return nil, trials[i].RunTo(ctx, numEps)
+		}
+	})
+	for i, err := range errs {
+		if err != nil && err != ctx.Err() && trials[i].err == nil {
+			trials[i].err = err // A panic, which may have left the agent half updated
+		}
+	}
+	if trialsErr := newTrialsError(errs); trialsErr != nil {
+		return trialsErr
+	}
+	return nil
+}
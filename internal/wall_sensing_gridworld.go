@@ -0,0 +1,43 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+// WallSensingGridworld is a partially observable MapGridworld. The agent does not see its position,
+// only which of the four neighbouring cells (up, right, down, left) are walls or off the map.
+// Observations are the one-hot encoding of that 4-bit mask, so different cells alias each other.
+type WallSensingGridworld struct {
+	*MapGridworld
+}
+
+// NewWallSensingGridworld returns a new WallSensingGridworld Environment built from the passed config.
+func NewWallSensingGridworld(cfg MapConfig, rng *mathlib.Random) (Environment, error) {
+	env, err := NewMapGridworld(cfg, rng)
+	if err != nil {
+		return nil, err
+	}
+	return &WallSensingGridworld{env.(*MapGridworld)}, nil
+}
+
+// GetStateDim returns the number of possible wall masks.
+func (env *WallSensingGridworld) GetStateDim() int {
+	return 16
+}
+
+// blocked returns whether (x,y) is a wall or off the map.
+func (env *WallSensingGridworld) blocked(x int, y int) bool {
+	return x < 0 || y < 0 || x >= env.width || y >= env.height || env.index[y][x] < 0
+}
+
+// GetState returns the one-hot encoding of the wall mask around the agent.
+func (env *WallSensingGridworld) GetState() []float64 {
+	if env.tas {
+		panic("GetState called when in TAS.")
+	}
+	mask := 0
+	for a := 0; a < 4; a++ {
+		if env.blocked(gridStep(env.x, env.y, a)) {
+			mask |= 1 << uint(a)
+		}
+	}
+	return mathlib.ToOneHot(mask, 16)
+}
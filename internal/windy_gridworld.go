@@ -0,0 +1,80 @@
+package internal
+
+import "github.com/jackkenney/evolve-rl/mathlib"
+
+const (
+	windyWidth  = 10
+	windyHeight = 7
+)
+
+// windStrength is how many cells the wind in each column pushes the agent up.
+var windStrength = [windyWidth]int{0, 0, 0, 1, 1, 1, 2, 2, 1, 0}
+
+// WindyGridworld is the windy gridworld of Sutton and Barto (2018), Example 6.5. An upward wind
+// of column-dependent strength shifts the agent after each move, and every step costs -1 until the
+// goal is reached. In the stochastic variant (Exercise 6.10) the wind in windy columns is one
+// stronger, as given, or one weaker with probability 1/3 each.
+// Actions are up (0), right (1), down (2) and left (3).
+type WindyGridworld struct {
+	stochastic bool
+	x          int // Agent horizontal coordinate (0 to 9)
+	y          int // Agent vertical coordinate (0 to 6)
+}
+
+// NewWindyGridworld returns a new WindyGridworld Environment object.
+func NewWindyGridworld(stochastic bool, rng *mathlib.Random) Environment {
+	env := WindyGridworld{stochastic: stochastic}
+	env.NewEpisode(rng)
+	return &env
+}
+
+// GetMaxEps returns how many episodes should be run
+func (env *WindyGridworld) GetMaxEps() int {
+	return 500
+}
+
+// GetStateDim returns the dimension (length) of state vectors.
+func (env *WindyGridworld) GetStateDim() int {
+	return windyWidth * windyHeight
+}
+
+// GetNumActions returns |\mathcal A|.
+func (env *WindyGridworld) GetNumActions() int {
+	return 4
+}
+
+// GetGamma returns \gamma
+func (env *WindyGridworld) GetGamma() float64 {
+	return 1.0
+}
+
+// Transition applies action a, updating the state of the environment. It returns the reward that results from the state transition.
+func (env *WindyGridworld) Transition(a int, rng *mathlib.Random) float64 {
+	// The wind of the column the agent moves from applies
+	wind := windStrength[env.x]
+	if env.stochastic && wind > 0 {
+		wind += int(rng.Float64()*3) - 1
+	}
+
+	xPrime, yPrime := gridStep(env.x, env.y, a)
+	yPrime -= wind
+	env.x = int(clip(float64(xPrime), 0, windyWidth-1))
+	env.y = int(clip(float64(yPrime), 0, windyHeight-1))
+	return -1.0
+}
+
+// GetState returns the one-hot encoding of the agent's position.
+func (env *WindyGridworld) GetState() []float64 {
+	return mathlib.ToOneHot(env.y*windyWidth+env.x, env.GetStateDim())
+}
+
+// InTAS returns whether the agent has reached the goal.
+func (env *WindyGridworld) InTAS() bool {
+	return env.x == 7 && env.y == 3
+}
+
+// NewEpisode moves the agent to the start cell.
+func (env *WindyGridworld) NewEpisode(rng *mathlib.Random) {
+	env.x = 0
+	env.y = 3
+}
@@ -0,0 +1,274 @@
+package wrappers
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Truncated reports whether the wrapped environment ended the episode by truncation.
+func (w *Wrapper) Truncated() bool {
+	if tr, ok := w.Environment.(internal.Truncator); ok {
+		return tr.Truncated()
+	}
+	return false
+}
+
+// Err returns the failure of the wrapped environment if it is an internal.ErrorReporter, or nil, so
+// runners see the failures of wrapped remote environments.
+func (w *Wrapper) Err() error {
+	if e, ok := w.Environment.(internal.ErrorReporter); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// Close closes the wrapped environment if it is an io.Closer, e.g. to end a remote session.
+func (w *Wrapper) Close() error {
+	if c, ok := w.Environment.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// TimeLimit ends episodes after maxSteps transitions. Hitting the limit is reported by Truncated,
+// separately from the wrapped environment reaching a terminal state. RunEpisode runs until InTAS,
+// so environments that may never terminate need a time limit.
+type TimeLimit struct {
+	Wrapper
+	maxSteps  int
+	penalty   float64 // Reward added on the step that hits the limit
+	t         int     // Time into the episode
+	truncated bool
+}
+
+// NewTimeLimit wraps env so that episodes last at most maxSteps transitions.
+func NewTimeLimit(env internal.Environment, maxSteps int) internal.Environment {
+	return NewPenalizedTimeLimit(env, maxSteps, 0)
+}
+
+// NewPenalizedTimeLimit is NewTimeLimit with penalty added to the reward of the step that hits the
+// limit, like the -100 of the original gridworld for running 100 steps.
+func NewPenalizedTimeLimit(env internal.Environment, maxSteps int, penalty float64) internal.Environment {
+	if maxSteps < 1 {
+		panic("TimeLimit needs at least one step.")
+	}
+	return &TimeLimit{Wrapper: Wrapper{env}, maxSteps: maxSteps, penalty: penalty}
+}
+
+// Transition applies action a and truncates the episode if the limit is reached.
+func (w *TimeLimit) Transition(a int, rng *mathlib.Random) float64 {
+	r := w.Environment.Transition(a, rng)
+	w.t++
+	if w.t >= w.maxSteps && !w.Environment.InTAS() {
+		w.truncated = true
+		r += w.penalty
+	}
+	return r
+}
+
+// InTAS returns whether the episode is over, either terminated or truncated.
+func (w *TimeLimit) InTAS() bool {
+	return w.truncated || w.Environment.InTAS()
+}
+
+// Truncated returns whether the episode was cut off by this or a wrapped time limit.
+func (w *TimeLimit) Truncated() bool {
+	return w.truncated || w.Wrapper.Truncated()
+}
+
+// NewEpisode resets the step counter and starts a new episode.
+func (w *TimeLimit) NewEpisode(rng *mathlib.Random) {
+	w.t = 0
+	w.truncated = false
+	w.Environment.NewEpisode(rng)
+}
+
+// RewardScale multiplies every reward by a constant.
+type RewardScale struct {
+	Wrapper
+	scale float64
+}
+
+// NewRewardScale wraps env so that its rewards are multiplied by scale.
+func NewRewardScale(env internal.Environment, scale float64) internal.Environment {
+	return &RewardScale{Wrapper: Wrapper{env}, scale: scale}
+}
+
+// Transition returns the scaled reward.
+func (w *RewardScale) Transition(a int, rng *mathlib.Random) float64 {
+	return w.scale * w.Environment.Transition(a, rng)
+}
+
+// RewardClip bounds every reward to [low, high].
+type RewardClip struct {
+	Wrapper
+	low, high float64
+}
+
+// NewRewardClip wraps env so that its rewards are clipped to [low, high].
+func NewRewardClip(env internal.Environment, low float64, high float64) internal.Environment {
+	if low > high {
+		panic("RewardClip needs low <= high.")
+	}
+	return &RewardClip{Wrapper: Wrapper{env}, low: low, high: high}
+}
+
+// Transition returns the clipped reward.
+func (w *RewardClip) Transition(a int, rng *mathlib.Random) float64 {
+	return math.Max(w.low, math.Min(w.high, w.Environment.Transition(a, rng)))
+}
+
+// ActionRepeat applies every action k times, or until the episode ends, and returns the sum of the rewards.
+type ActionRepeat struct {
+	Wrapper
+	k int
+}
+
+// NewActionRepeat wraps env so that every action is repeated k times.
+func NewActionRepeat(env internal.Environment, k int) internal.Environment {
+	if k < 1 {
+		panic("ActionRepeat needs at least one repeat.")
+	}
+	return &ActionRepeat{Wrapper: Wrapper{env}, k: k}
+}
+
+// Transition applies action a up to k times and returns the total reward.
+func (w *ActionRepeat) Transition(a int, rng *mathlib.Random) float64 {
+	total := 0.0
+	for i := 0; i < w.k && !w.Environment.InTAS(); i++ {
+		total += w.Environment.Transition(a, rng)
+	}
+	return total
+}
+
+// StickyActions executes the previous action instead of the chosen one with probability p, as
+// recommended for the Arcade Learning Environment by Machado et al. (2018).
+type StickyActions struct {
+	Wrapper
+	p    float64
+	prev int // The previously executed action, -1 at the start of an episode
+}
+
+// NewStickyActions wraps env so that actions stick with probability p.
+func NewStickyActions(env internal.Environment, p float64) internal.Environment {
+	return &StickyActions{Wrapper: Wrapper{env}, p: p, prev: -1}
+}
+
+// Transition applies action a, or the previous action with probability p.
+func (w *StickyActions) Transition(a int, rng *mathlib.Random) float64 {
+	if w.prev >= 0 && rng.Float64() < w.p {
+		a = w.prev
+	}
+	w.prev = a
+	return w.Environment.Transition(a, rng)
+}
+
+// NewEpisode forgets the previous action and starts a new episode.
+func (w *StickyActions) NewEpisode(rng *mathlib.Random) {
+	w.prev = -1
+	w.Environment.NewEpisode(rng)
+}
+
+// NormalizeObservation standardizes every component of the observation with a running estimate of
+// its mean and variance (Welford's algorithm) and clips the result to [-clip, clip]. The statistics
+// are updated once per observed state and carry over between episodes.
+type NormalizeObservation struct {
+	Wrapper
+	clip    float64
+	count   float64
+	mean    []float64
+	m2      []float64 // Sum of squared differences from the mean
+	frozen  bool
+	pending bool // Has the environment moved since the statistics were last updated?
+	last    []float64
+}
+
+// NewNormalizeObservation wraps env so that its observations are standardized.
+func NewNormalizeObservation(env internal.Environment, clip float64) internal.Environment {
+	dim := env.GetStateDim()
+	return &NormalizeObservation{
+		Wrapper: Wrapper{env},
+		clip:    clip,
+		mean:    mathlib.Vector(dim, 0),
+		m2:      mathlib.Vector(dim, 0),
+		pending: true,
+	}
+}
+
+// Freeze stops updating the statistics, e.g. to evaluate a trained agent.
+func (w *NormalizeObservation) Freeze() {
+	w.frozen = true
+}
+
+// GetState returns the standardized observation.
+func (w *NormalizeObservation) GetState() []float64 {
+	if w.pending {
+		w.last = w.Environment.GetState()
+		if !w.frozen {
+			w.count++
+			for i, x := range w.last {
+				delta := x - w.mean[i]
+				w.mean[i] += delta / w.count
+				w.m2[i] += delta * (x - w.mean[i])
+			}
+		}
+		w.pending = false
+	}
+	result := make([]float64, len(w.last))
+	for i, x := range w.last {
+		std := 1.0
+		if w.count > 1 {
+			std = math.Sqrt(w.m2[i]/w.count + 1e-8)
+		}
+		result[i] = math.Max(-w.clip, math.Min(w.clip, (x-w.mean[i])/std))
+	}
+	return result
+}
+
+// ObservationSpace returns the box [-clip, clip] in every component.
+func (w *NormalizeObservation) ObservationSpace() internal.Space {
+	dim := w.Environment.GetStateDim()
+	return internal.NewBoxSpace(mathlib.Vector(dim, -w.clip), mathlib.Vector(dim, w.clip))
+}
+
+// Transition applies action a in the wrapped environment.
+func (w *NormalizeObservation) Transition(a int, rng *mathlib.Random) float64 {
+	w.pending = true
+	return w.Environment.Transition(a, rng)
+}
+
+// NewEpisode starts a new episode in the wrapped environment.
+func (w *NormalizeObservation) NewEpisode(rng *mathlib.Random) {
+	w.pending = true
+	w.Environment.NewEpisode(rng)
+}
+
+// normalizeObservationSnapshot is the state of a NormalizeObservation.
+type normalizeObservationSnapshot struct {
+	Count  float64   `json:"count"`
+	Mean   []float64 `json:"mean"`
+	M2     []float64 `json:"m2"`
+	Frozen bool      `json:"frozen"`
+}
+
+// Snapshot returns the running statistics and the snapshot of the wrapped environment.
+func (w *NormalizeObservation) Snapshot() ([]byte, error) {
+	return w.snapshotWith(normalizeObservationSnapshot{Count: w.count, Mean: w.mean, M2: w.m2, Frozen: w.frozen})
+}
+
+// Restore sets the running statistics and the wrapped environment to a snapshot.
+func (w *NormalizeObservation) Restore(data []byte) error {
+	snap := normalizeObservationSnapshot{}
+	if err := w.restoreWith(data, &snap); err != nil {
+		return err
+	}
+	if len(snap.Mean) != len(w.mean) || len(snap.M2) != len(w.m2) {
+		return fmt.Errorf("snapshot statistics have dimension %d, expected %d", len(snap.Mean), len(w.mean))
+	}
+	w.count, w.mean, w.m2, w.frozen = snap.Count, snap.Mean, snap.M2, snap.Frozen
+	return nil
+}
@@ -0,0 +1,240 @@
+package wrappers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// ReturnsAfterChanges returns the window returns that follow each change point, so the adaptation
+// of an agent after every shift can be compared. Change points too close to the end are skipped.
+func ReturnsAfterChanges(returns []float64, points []internal.ChangePoint, window int) [][]float64 {
+	var segments [][]float64
+	for _, p := range points {
+		if p.Episode+window <= len(returns) {
+			segments = append(segments, returns[p.Episode:p.Episode+window])
+		}
+	}
+	return segments
+}
+
+// schedule counts episodes and reports when it is time for a change every `every` episodes.
+type schedule struct {
+	every   int
+	episode int // Number of episodes started so far
+	changes []internal.ChangePoint
+}
+
+// next counts a new episode and returns whether the environment should change before it.
+func (s *schedule) next() bool {
+	change := s.every > 0 && s.episode > 0 && s.episode%s.every == 0
+	s.episode++
+	return change
+}
+
+// record logs a change before the current episode.
+func (s *schedule) record(description string) {
+	s.changes = append(s.changes, internal.ChangePoint{Episode: s.episode - 1, Description: description})
+}
+
+// ChangePoints returns every change made so far.
+func (s *schedule) ChangePoints() []internal.ChangePoint {
+	return s.changes
+}
+
+// scheduleSnapshot is the state of a schedule.
+type scheduleSnapshot struct {
+	Episode int                    `json:"episode"`
+	Changes []internal.ChangePoint `json:"changes"`
+}
+
+func (s *schedule) snapshot() scheduleSnapshot {
+	return scheduleSnapshot{Episode: s.episode, Changes: s.changes}
+}
+
+func (s *schedule) restore(snap scheduleSnapshot) {
+	s.episode = snap.Episode
+	s.changes = snap.Changes
+}
+
+// GoalSetter is implemented by environments whose goal can be moved, like internal.MapGridworld.
+type GoalSetter interface {
+	GoalCandidates() [][2]int
+	SetGoal(x int, y int)
+}
+
+// MovingGoal moves the goal of the wrapped environment to a uniformly random candidate cell every
+// `every` episodes.
+type MovingGoal struct {
+	Wrapper
+	schedule
+	goal GoalSetter
+}
+
+// NewMovingGoal wraps env so that its goal moves every `every` episodes. Env, or an environment
+// it wraps, must implement GoalSetter.
+func NewMovingGoal(env internal.Environment, every int) internal.Environment {
+	inner := find(env, func(e internal.Environment) bool {
+		_, ok := e.(GoalSetter)
+		return ok
+	})
+	if inner == nil {
+		panic("MovingGoal needs an environment that implements GoalSetter.")
+	}
+	goal := inner.(GoalSetter)
+	return &MovingGoal{Wrapper: Wrapper{env}, schedule: schedule{every: every}, goal: goal}
+}
+
+// NewEpisode moves the goal if it is time to, then starts a new episode.
+func (w *MovingGoal) NewEpisode(rng *mathlib.Random) {
+	if w.next() {
+		candidates := w.goal.GoalCandidates()
+		cell := candidates[int(rng.Float64()*float64(len(candidates)))]
+		w.goal.SetGoal(cell[0], cell[1])
+		w.record(fmt.Sprintf("goal moved to (%d,%d)", cell[0], cell[1]))
+	}
+	w.Environment.NewEpisode(rng)
+}
+
+// Snapshot returns the schedule and the snapshot of the wrapped environment, which holds the goal.
+func (w *MovingGoal) Snapshot() ([]byte, error) {
+	return w.snapshotWith(w.schedule.snapshot())
+}
+
+// Restore sets the schedule and the wrapped environment to a snapshot.
+func (w *MovingGoal) Restore(data []byte) error {
+	snap := scheduleSnapshot{}
+	if err := w.restoreWith(data, &snap); err != nil {
+		return err
+	}
+	w.schedule.restore(snap)
+	return nil
+}
+
+// RewardDrift multiplies the rewards of the wrapped environment by a scale that takes a
+// log-normal random walk step every `every` episodes.
+type RewardDrift struct {
+	Wrapper
+	schedule
+	std   float64 // Standard deviation of each step of log(scale)
+	scale float64
+}
+
+// NewRewardDrift wraps env so that the magnitude of its rewards drifts every `every` episodes.
+func NewRewardDrift(env internal.Environment, every int, std float64) internal.Environment {
+	return &RewardDrift{Wrapper: Wrapper{env}, schedule: schedule{every: every}, std: std, scale: 1}
+}
+
+// NewEpisode changes the reward scale if it is time to, then starts a new episode.
+func (w *RewardDrift) NewEpisode(rng *mathlib.Random) {
+	if w.next() {
+		w.scale *= math.Exp(w.std * rng.NormFloat64())
+		w.record(fmt.Sprintf("reward scale %g", w.scale))
+	}
+	w.Environment.NewEpisode(rng)
+}
+
+// Transition returns the scaled reward.
+func (w *RewardDrift) Transition(a int, rng *mathlib.Random) float64 {
+	return w.scale * w.Environment.Transition(a, rng)
+}
+
+// rewardDriftSnapshot is the state of a RewardDrift.
+type rewardDriftSnapshot struct {
+	Schedule scheduleSnapshot `json:"schedule"`
+	Scale    float64          `json:"scale"`
+}
+
+// Snapshot returns the schedule, the reward scale and the snapshot of the wrapped environment.
+func (w *RewardDrift) Snapshot() ([]byte, error) {
+	return w.snapshotWith(rewardDriftSnapshot{Schedule: w.schedule.snapshot(), Scale: w.scale})
+}
+
+// Restore sets the schedule, the reward scale and the wrapped environment to a snapshot.
+func (w *RewardDrift) Restore(data []byte) error {
+	snap := rewardDriftSnapshot{}
+	if err := w.restoreWith(data, &snap); err != nil {
+		return err
+	}
+	w.schedule.restore(snap.Schedule)
+	w.scale = snap.Scale
+	return nil
+}
+
+// SlipSetter is implemented by environments whose transition noise can be changed, like internal.MapGridworld.
+type SlipSetter interface {
+	SetSlip(stay float64, veerRight float64, veerLeft float64)
+}
+
+// SlipSettings are the probabilities of staying, veering right and veering left.
+type SlipSettings struct {
+	Stay, VeerRight, VeerLeft float64
+}
+
+// SlipSwitch cycles the wrapped environment through a list of slip settings, switching every `every` episodes.
+type SlipSwitch struct {
+	Wrapper
+	schedule
+	slip     SlipSetter
+	settings []SlipSettings
+	current  int
+}
+
+// NewSlipSwitch wraps env so that it starts with settings[0] and moves to the next settings every
+// `every` episodes. Env, or an environment it wraps, must implement SlipSetter.
+func NewSlipSwitch(env internal.Environment, every int, settings []SlipSettings) internal.Environment {
+	inner := find(env, func(e internal.Environment) bool {
+		_, ok := e.(SlipSetter)
+		return ok
+	})
+	if inner == nil {
+		panic("SlipSwitch needs an environment that implements SlipSetter.")
+	}
+	slip := inner.(SlipSetter)
+	if len(settings) == 0 {
+		panic("SlipSwitch needs at least one setting.")
+	}
+	first := settings[0]
+	slip.SetSlip(first.Stay, first.VeerRight, first.VeerLeft)
+	return &SlipSwitch{Wrapper: Wrapper{env}, schedule: schedule{every: every}, slip: slip, settings: settings}
+}
+
+// NewEpisode switches the slip probabilities if it is time to, then starts a new episode.
+func (w *SlipSwitch) NewEpisode(rng *mathlib.Random) {
+	if w.next() {
+		w.current = (w.current + 1) % len(w.settings)
+		s := w.settings[w.current]
+		w.slip.SetSlip(s.Stay, s.VeerRight, s.VeerLeft)
+		w.record(fmt.Sprintf("slip stay=%g veer_right=%g veer_left=%g", s.Stay, s.VeerRight, s.VeerLeft))
+	}
+	w.Environment.NewEpisode(rng)
+}
+
+// slipSwitchSnapshot is the state of a SlipSwitch.
+type slipSwitchSnapshot struct {
+	Schedule scheduleSnapshot `json:"schedule"`
+	Current  int              `json:"current"`
+}
+
+// Snapshot returns the schedule, the current settings and the snapshot of the wrapped environment.
+func (w *SlipSwitch) Snapshot() ([]byte, error) {
+	return w.snapshotWith(slipSwitchSnapshot{Schedule: w.schedule.snapshot(), Current: w.current})
+}
+
+// Restore sets the schedule, the current settings and the wrapped environment to a snapshot.
+func (w *SlipSwitch) Restore(data []byte) error {
+	snap := slipSwitchSnapshot{}
+	if err := w.restoreWith(data, &snap); err != nil {
+		return err
+	}
+	if snap.Current < 0 || snap.Current >= len(w.settings) {
+		return fmt.Errorf("snapshot is of settings %d of %d", snap.Current, len(w.settings))
+	}
+	w.schedule.restore(snap.Schedule)
+	w.current = snap.Current
+	s := w.settings[w.current]
+	w.slip.SetSlip(s.Stay, s.VeerRight, s.VeerLeft)
+	return nil
+}
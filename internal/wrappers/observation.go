@@ -0,0 +1,203 @@
+package wrappers
+
+import (
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// GaussianObservationNoise adds independent N(0, std^2) noise to every component of the
+// observation. GetState has no random number generator argument, so the wrapper owns a stream
+// split off the generator it was constructed with.
+type GaussianObservationNoise struct {
+	Wrapper
+	std float64
+	rng *mathlib.Random
+}
+
+// NewGaussianObservationNoise wraps env so that observations are corrupted by Gaussian noise.
+func NewGaussianObservationNoise(env internal.Environment, std float64, rng *mathlib.Random) internal.Environment {
+	return &GaussianObservationNoise{Wrapper: Wrapper{env}, std: std, rng: rng.Split()}
+}
+
+// GetState returns the noisy observation.
+func (w *GaussianObservationNoise) GetState() []float64 {
+	s := w.Environment.GetState()
+	noisy := make([]float64, len(s))
+	for i := range s {
+		noisy[i] = s[i] + w.std*w.rng.NormFloat64()
+	}
+	return noisy
+}
+
+// Snapshot returns the state of the wrapper's stream and the snapshot of the wrapped environment.
+func (w *GaussianObservationNoise) Snapshot() ([]byte, error) {
+	return w.snapshotWith(w.rng)
+}
+
+// Restore sets the wrapper's stream and the wrapped environment to a snapshot.
+func (w *GaussianObservationNoise) Restore(data []byte) error {
+	return w.restoreWith(data, w.rng)
+}
+
+// ObservationSpace returns an unbounded box, since the noise is unbounded and breaks any encoding.
+func (w *GaussianObservationNoise) ObservationSpace() internal.Space {
+	return internal.NewUnboundedBoxSpace(w.Environment.GetStateDim())
+}
+
+// OneHotObservationNoise replaces the one-hot observation with a uniformly random one-hot vector
+// with probability p, so tabular agents see a corrupted but still valid state. Like
+// GaussianObservationNoise, it owns a stream split off the generator it was constructed with.
+type OneHotObservationNoise struct {
+	Wrapper
+	p   float64
+	rng *mathlib.Random
+}
+
+// NewOneHotObservationNoise wraps env so that observations are replaced at random with probability p.
+func NewOneHotObservationNoise(env internal.Environment, p float64, rng *mathlib.Random) internal.Environment {
+	if internal.ObservationSpaceOf(env).Kind != internal.OneHot {
+		panic("OneHotObservationNoise needs one-hot observations.")
+	}
+	return &OneHotObservationNoise{Wrapper: Wrapper{env}, p: p, rng: rng.Split()}
+}
+
+// GetState returns the observation, or a random one-hot vector with probability p.
+func (w *OneHotObservationNoise) GetState() []float64 {
+	s := w.Environment.GetState()
+	if w.rng.Float64() < w.p {
+		dim := w.Environment.GetStateDim()
+		return mathlib.ToOneHot(int(w.rng.Float64()*float64(dim)), dim)
+	}
+	return s
+}
+
+// Snapshot returns the state of the wrapper's stream and the snapshot of the wrapped environment.
+func (w *OneHotObservationNoise) Snapshot() ([]byte, error) {
+	return w.snapshotWith(w.rng)
+}
+
+// Restore sets the wrapper's stream and the wrapped environment to a snapshot.
+func (w *OneHotObservationNoise) Restore(data []byte) error {
+	return w.restoreWith(data, w.rng)
+}
+
+// history keeps the last k observations of the wrapped Environment, oldest first.
+type history struct {
+	Wrapper
+	k       int
+	frames  [][]float64
+	pending bool // Has the environment moved since the last observation was recorded?
+}
+
+// observe records the current observation if the environment has moved and returns the frames.
+// At the start of an episode the first observation fills every frame.
+func (h *history) observe() [][]float64 {
+	if h.pending {
+		s := h.Environment.GetState()
+		if len(h.frames) == 0 {
+			for i := 0; i < h.k; i++ {
+				h.frames = append(h.frames, s)
+			}
+		} else {
+			h.frames = append(h.frames[1:], s)
+		}
+		h.pending = false
+	}
+	return h.frames
+}
+
+// Transition applies action a in the wrapped environment. The observation before the
+// transition is recorded first, even if GetState was not called.
+func (h *history) Transition(a int, rng *mathlib.Random) float64 {
+	h.observe()
+	h.pending = true
+	return h.Environment.Transition(a, rng)
+}
+
+// NewEpisode starts a new episode with an empty history.
+func (h *history) NewEpisode(rng *mathlib.Random) {
+	h.Environment.NewEpisode(rng)
+	h.frames = nil
+	h.pending = true
+}
+
+// FrameStack observes the concatenation of the last k observations, oldest first.
+type FrameStack struct {
+	history
+}
+
+// NewFrameStack wraps env so that observations are its last k observations concatenated.
+func NewFrameStack(env internal.Environment, k int) internal.Environment {
+	if k < 1 {
+		panic("FrameStack needs at least one frame.")
+	}
+	return &FrameStack{history{Wrapper: Wrapper{env}, k: k, pending: true}}
+}
+
+// GetStateDim returns k times the dimension of the wrapped environment's states.
+func (w *FrameStack) GetStateDim() int {
+	return w.k * w.Environment.GetStateDim()
+}
+
+// GetState returns the last k observations concatenated.
+func (w *FrameStack) GetState() []float64 {
+	result := make([]float64, 0, w.GetStateDim())
+	for _, frame := range w.observe() {
+		result = append(result, frame...)
+	}
+	return result
+}
+
+// ObservationSpace returns a box with the bounds of the wrapped observations repeated k times.
+func (w *FrameStack) ObservationSpace() internal.Space {
+	low, high := internal.ObservationSpaceOf(w.Environment).Bounds()
+	stackedLow := make([]float64, 0, w.GetStateDim())
+	stackedHigh := make([]float64, 0, w.GetStateDim())
+	for i := 0; i < w.k; i++ {
+		stackedLow = append(stackedLow, low...)
+		stackedHigh = append(stackedHigh, high...)
+	}
+	return internal.NewBoxSpace(stackedLow, stackedHigh)
+}
+
+// OneHotHistory observes the one-hot encoding of the tuple of the last k one-hot observations,
+// so tabular agents can condition on the history. The dimension is the wrapped dimension to the power k.
+type OneHotHistory struct {
+	history
+	radices []int
+}
+
+// NewOneHotHistory wraps the one-hot env so that observations encode its last k observations.
+func NewOneHotHistory(env internal.Environment, k int) internal.Environment {
+	if k < 1 {
+		panic("OneHotHistory needs at least one frame.")
+	}
+	if internal.ObservationSpaceOf(env).Kind != internal.OneHot {
+		panic("OneHotHistory needs one-hot observations.")
+	}
+	radices := make([]int, k)
+	for i := range radices {
+		radices[i] = env.GetStateDim()
+	}
+	return &OneHotHistory{history: history{Wrapper: Wrapper{env}, k: k, pending: true}, radices: radices}
+}
+
+// GetStateDim returns the number of possible histories.
+func (w *OneHotHistory) GetStateDim() int {
+	return mathlib.MixedRadixCapacity(w.radices)
+}
+
+// GetState returns the one-hot encoding of the last k observations.
+func (w *OneHotHistory) GetState() []float64 {
+	frames := w.observe()
+	digits := make([]int, w.k)
+	for i, frame := range frames {
+		digits[i] = mathlib.FromOneHot(frame)
+	}
+	return mathlib.ToOneHotMixedRadix(digits, w.radices)
+}
+
+// ObservationSpace returns the one-hot space of histories.
+func (w *OneHotHistory) ObservationSpace() internal.Space {
+	return internal.NewOneHotSpace(w.GetStateDim())
+}
@@ -0,0 +1,74 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+)
+
+// Step is one transition of a recorded episode: the observation the action was chosen in, the
+// action and the reward that followed.
+type Step struct {
+	State  []float64 `json:"state"`
+	Action int       `json:"action"`
+	Reward float64   `json:"reward"`
+}
+
+// Episode is a recorded episode.
+type Episode struct {
+	Environment string  `json:"environment,omitempty"` // What was played, for whoever replays it
+	Steps       []Step  `json:"steps"`
+	Truncated   bool    `json:"truncated"`
+	Return      float64 `json:"return"` // Undiscounted sum of the rewards
+}
+
+// Recorder records the transitions of the current episode of the wrapped environment.
+type Recorder struct {
+	Wrapper
+	episode Episode
+}
+
+// NewRecorder wraps env so that its episodes are recorded.
+func NewRecorder(env internal.Environment) *Recorder {
+	return &Recorder{Wrapper: Wrapper{env}}
+}
+
+// Transition records the state the action was taken in, then the reward.
+func (w *Recorder) Transition(a int, rng *mathlib.Random) float64 {
+	s := w.Environment.GetState()
+	r := w.Environment.Transition(a, rng)
+	w.episode.Steps = append(w.episode.Steps, Step{State: s, Action: a, Reward: r})
+	w.episode.Return += r
+	w.episode.Truncated = w.Truncated()
+	return r
+}
+
+// NewEpisode forgets the previous recording and starts a new episode.
+func (w *Recorder) NewEpisode(rng *mathlib.Random) {
+	w.episode = Episode{}
+	w.Environment.NewEpisode(rng)
+}
+
+// Episode returns the recording of the current (or last) episode.
+func (w *Recorder) Episode() Episode {
+	return w.episode
+}
+
+// WriteEpisode writes the recording as JSON.
+func WriteEpisode(w io.Writer, ep Episode) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ep)
+}
+
+// ReadEpisode reads a recording written by WriteEpisode.
+func ReadEpisode(r io.Reader) (Episode, error) {
+	ep := Episode{}
+	if err := json.NewDecoder(r).Decode(&ep); err != nil {
+		return Episode{}, fmt.Errorf("reading episode: %v", err)
+	}
+	return ep, nil
+}
@@ -0,0 +1,93 @@
+// Package wrappers contains Environments that wrap another Environment and change some of its behaviour.
+package wrappers
+
+import (
+	"encoding/json"
+
+	"github.com/jackkenney/evolve-rl/internal"
+)
+
+// Wrapper forwards every Environment method to the wrapped Environment. Wrappers embed it and
+// override only the methods whose behaviour they change.
+type Wrapper struct {
+	internal.Environment
+}
+
+// Unwrap returns the wrapped Environment.
+func (w *Wrapper) Unwrap() internal.Environment {
+	return w.Environment
+}
+
+// ObservationSpace returns the observation space of the wrapped Environment.
+func (w *Wrapper) ObservationSpace() internal.Space {
+	return internal.ObservationSpaceOf(w.Environment)
+}
+
+// ActionSpace returns the action space of the wrapped Environment.
+func (w *Wrapper) ActionSpace() internal.Space {
+	return internal.ActionSpaceOf(w.Environment)
+}
+
+// Snapshot returns the snapshot of the wrapped Environment, or nil if it is not an internal.Snapshotter.
+// Wrappers with state that carries over between episodes override it with snapshotWith.
+func (w *Wrapper) Snapshot() ([]byte, error) {
+	return internal.SnapshotOf(w.Environment)
+}
+
+// Restore restores the snapshot of the wrapped Environment.
+func (w *Wrapper) Restore(data []byte) error {
+	return internal.RestoreSnapshot(w.Environment, data)
+}
+
+// wrapperSnapshot is the snapshot of a wrapper with state of its own.
+type wrapperSnapshot struct {
+	State json.RawMessage `json:"state"`
+	Inner json.RawMessage `json:"inner,omitempty"` // Snapshot of the wrapped Environment
+}
+
+// snapshotWith returns a snapshot of the wrapper's own state, which is marshalled as JSON, and of
+// the wrapped Environment.
+func (w *Wrapper) snapshotWith(state interface{}) ([]byte, error) {
+	own, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := w.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(wrapperSnapshot{State: own, Inner: inner})
+}
+
+// restoreWith restores a snapshot returned by snapshotWith, unmarshalling the wrapper's own state into state.
+func (w *Wrapper) restoreWith(data []byte, state interface{}) error {
+	snap := wrapperSnapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(snap.State, state); err != nil {
+		return err
+	}
+	return w.Restore(snap.Inner)
+}
+
+// unwrapper is implemented by every wrapper in this package.
+type unwrapper interface {
+	Unwrap() internal.Environment
+}
+
+// find returns the first environment in the chain of wrappers starting at env (env itself
+// included) for which match returns true, or nil if there is none.
+func find(env internal.Environment, match func(internal.Environment) bool) internal.Environment {
+	for env != nil {
+		if match(env) {
+			return env
+		}
+		w, ok := env.(unwrapper)
+		if !ok {
+			return nil
+		}
+		env = w.Unwrap()
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package wrappers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackkenney/evolve-rl/internal"
+	"github.com/jackkenney/evolve-rl/mathlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameStack(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewFrameStack(internal.NewCliffWalking(rng), 2)
+	assert.Equal(t, 96, env.GetStateDim())
+
+	env.NewEpisode(rng)
+	s := env.GetState()
+	assert.Len(t, s, 96)
+	assert.Equal(t, 1.0, s[36])
+	assert.Equal(t, 1.0, s[48+36])
+
+	// Calling GetState twice does not shift the history
+	assert.Equal(t, s, env.GetState())
+
+	env.Transition(0, rng)
+	s = env.GetState()
+	assert.Equal(t, 1.0, s[36])
+	assert.Equal(t, 1.0, s[48+24])
+}
+
+func TestOneHotHistory(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewOneHotHistory(internal.NewCliffWalking(rng), 2)
+	assert.Equal(t, 48*48, env.GetStateDim())
+
+	env.NewEpisode(rng)
+	env.Transition(0, rng)
+	digits := mathlib.FromOneHotMixedRadix(env.GetState(), []int{48, 48})
+	assert.Equal(t, []int{36, 24}, digits)
+
+	// A new episode forgets the history
+	env.NewEpisode(rng)
+	digits = mathlib.FromOneHotMixedRadix(env.GetState(), []int{48, 48})
+	assert.Equal(t, []int{36, 36}, digits)
+}
+
+func TestObservationNoise(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	noisy := NewGaussianObservationNoise(internal.NewCartPole(rng), 1, rng)
+	assert.NotEqual(t, noisy.(*GaussianObservationNoise).Unwrap().GetState(), noisy.GetState())
+
+	always := NewOneHotObservationNoise(internal.NewCliffWalking(rng), 1, rng)
+	seen := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		seen[mathlib.FromOneHot(always.GetState())] = true
+	}
+	assert.True(t, len(seen) > 1)
+
+	never := NewOneHotObservationNoise(internal.NewCliffWalking(rng), 0, rng)
+	assert.Equal(t, 36, mathlib.FromOneHot(never.GetState()))
+}
+
+func newDefaultMapGridworld(rng *mathlib.Random) internal.Environment {
+	env, err := internal.ParseMapGridworld(strings.NewReader(internal.DefaultGridworldMap), rng)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+func TestMovingGoal(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewMovingGoal(NewFrameStack(newDefaultMapGridworld(rng), 1), 3)
+	for ep := 0; ep < 10; ep++ {
+		env.NewEpisode(rng)
+	}
+	points := env.(internal.ChangeLogger).ChangePoints()
+	assert.Len(t, points, 3)
+	assert.Equal(t, 3, points[0].Episode)
+	assert.Equal(t, 9, points[2].Episode)
+
+	var b bytes.Buffer
+	assert.NoError(t, internal.WriteChangePoints(&b, [][]internal.ChangePoint{points}))
+	assert.Equal(t, 4, strings.Count(b.String(), "\n"))
+
+	// Runners find the change points through other wrappers
+	assert.Equal(t, points, internal.ChangePointsOf(NewTimeLimit(env, 10)))
+
+	// The goal only moves between open cells, so the water stays where it was
+	for ep := 0; ep < 100; ep++ {
+		env.NewEpisode(rng)
+	}
+	snap, err := internal.SnapshotOf(env)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(snap), "W"))
+}
+
+func TestRewardDrift(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewRewardDrift(internal.NewCliffWalking(rng), 1, 0.5).(*RewardDrift)
+	env.NewEpisode(rng)
+	assert.Equal(t, -1.0, env.Transition(0, rng))
+	env.NewEpisode(rng)
+	assert.NotEqual(t, -1.0, env.Transition(0, rng))
+	assert.Len(t, env.ChangePoints(), 1)
+}
+
+func TestSlipSwitch(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	settings := []SlipSettings{{Stay: 1}, {}}
+	env := NewSlipSwitch(newDefaultMapGridworld(rng), 2, settings)
+
+	// The agent can't move while stay is one
+	env.NewEpisode(rng)
+	env.Transition(1, rng)
+	assert.Equal(t, 0, mathlib.FromOneHot(env.GetState()))
+
+	env.NewEpisode(rng)
+	env.NewEpisode(rng)
+	env.Transition(1, rng)
+	assert.Equal(t, 1, mathlib.FromOneHot(env.GetState()))
+}
+
+func TestReturnsAfterChanges(t *testing.T) {
+	returns := []float64{0, 1, 2, 3, 4, 5}
+	points := []internal.ChangePoint{{Episode: 2}, {Episode: 5}}
+	assert.Equal(t, [][]float64{{2, 3}}, ReturnsAfterChanges(returns, points, 2))
+}
+
+func TestTimeLimit(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewTimeLimit(internal.NewPendulum(3, rng), 5)
+	env.NewEpisode(rng)
+	steps := 0
+	for !env.InTAS() {
+		env.Transition(1, rng)
+		steps++
+	}
+	assert.Equal(t, 5, steps)
+	assert.True(t, env.(internal.Truncator).Truncated())
+
+	// Outer wrappers still report the truncation
+	scaled := NewRewardScale(env, 2)
+	assert.True(t, scaled.(internal.Truncator).Truncated())
+
+	// Reaching a terminal state exactly at the limit is not a truncation
+	lock := internal.NewCombinationLock(1, 2, rng)
+	limited := NewTimeLimit(lock, 1)
+	limited.Transition(0, rng)
+	limited.NewEpisode(rng)
+	limited.Transition(1, rng)
+	assert.True(t, limited.InTAS())
+	assert.False(t, limited.(internal.Truncator).Truncated())
+
+	// The original gridworld's timeout: moving left never reaches the goal, so the 100th step
+	// is penalized and truncates the episode
+	grid := NewPenalizedTimeLimit(internal.NewGridworld(rng), 100, -100)
+	grid.NewEpisode(rng)
+	total := 0.0
+	for steps = 0; !grid.InTAS(); steps++ {
+		total += grid.Transition(3, rng)
+	}
+	assert.Equal(t, 100, steps)
+	assert.Equal(t, -100.0, total)
+	assert.True(t, grid.(internal.Truncator).Truncated())
+	assert.NotPanics(t, func() { grid.GetState() })
+}
+
+func TestRewardWrappers(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	cliff := func() internal.Environment { return internal.NewCliffWalking(rng) }
+
+	assert.Equal(t, -0.5, NewRewardScale(cliff(), 0.5).Transition(0, rng))
+	assert.Equal(t, -1.0, NewRewardClip(cliff(), -1, 1).Transition(1, rng))
+	assert.Equal(t, -3.0, NewActionRepeat(cliff(), 3).Transition(0, rng))
+}
+
+func TestStickyActions(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewStickyActions(internal.NewCliffWalking(rng), 1)
+	env.NewEpisode(rng)
+	env.Transition(0, rng)
+	// Every later action repeats the first one, so the agent never reaches the cliff
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, -1.0, env.Transition(1, rng))
+	}
+	assert.Equal(t, 0, mathlib.FromOneHot(env.GetState()))
+}
+
+func TestNormalizeObservation(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewNormalizeObservation(internal.NewCartPole(rng), 5).(*NormalizeObservation)
+	for ep := 0; ep < 20; ep++ {
+		env.NewEpisode(rng)
+		for !env.InTAS() {
+			s := env.GetState()
+			for _, x := range s {
+				assert.True(t, x >= -5 && x <= 5)
+			}
+			env.Transition(int(rng.Float64()*2), rng)
+		}
+	}
+	// The running mean of theta is close to zero and the statistics only count each state once
+	assert.InDelta(t, 0, env.mean[2], 0.05)
+	env.GetState()
+	count := env.count
+	env.GetState()
+	assert.Equal(t, count, env.count)
+}
+
+// failedEnvironment is an environment whose connection broke.
+type failedEnvironment struct {
+	internal.Environment
+}
+
+func (env *failedEnvironment) Err() error { return errors.New("connection reset") }
+
+func TestWrapperErr(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	failed := NewRewardScale(NewTimeLimit(&failedEnvironment{internal.NewGridworld(rng)}, 10), 2)
+	assert.EqualError(t, failed.(internal.ErrorReporter).Err(), "connection reset")
+	assert.NoError(t, NewTimeLimit(internal.NewGridworld(rng), 10).(internal.ErrorReporter).Err())
+}
+
+func TestWrapperSpaces(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	grid := internal.NewGridworld(rng)
+
+	// Wrappers that keep the observations forward the wrapped spaces
+	limited := NewTimeLimit(grid, 10)
+	assert.Equal(t, internal.NewOneHotSpace(23), internal.ObservationSpaceOf(limited))
+	assert.Equal(t, internal.NewDiscreteSpace(4), internal.ActionSpaceOf(limited))
+	assert.Equal(t, internal.NewOneHotSpace(23*23), internal.ObservationSpaceOf(NewOneHotHistory(limited, 2)))
+
+	stacked := internal.ObservationSpaceOf(NewFrameStack(limited, 2))
+	assert.Equal(t, internal.Box, stacked.Kind)
+	assert.Equal(t, 46, stacked.Dim())
+
+	car := NewNormalizeObservation(internal.NewMountainCar(rng), 5)
+	assert.Equal(t, internal.NewBoxSpace([]float64{-5, -5}, []float64{5, 5}), internal.ObservationSpaceOf(car))
+
+	assert.Panics(t, func() { NewOneHotHistory(internal.NewMountainCar(rng), 2) })
+}
+
+func TestRecorder(t *testing.T) {
+	rng := mathlib.NewRandom(0)
+	env := NewRecorder(NewTimeLimit(internal.NewCliffWalking(rng), 3))
+	env.NewEpisode(rng)
+	for !env.InTAS() {
+		env.Transition(0, rng)
+	}
+	ep := env.Episode()
+	assert.Len(t, ep.Steps, 3)
+	assert.True(t, ep.Truncated)
+	assert.Equal(t, -3.0, ep.Return)
+	assert.Equal(t, 0, ep.Steps[1].Action)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteEpisode(&buf, ep))
+	read, err := ReadEpisode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ep, read)
+
+	env.NewEpisode(rng)
+	assert.Empty(t, env.Episode().Steps)
+}
+
+func TestSnapshot(t *testing.T) {
+	newEnv := func(rng *mathlib.Random) internal.Environment {
+		env := NewSlipSwitch(newDefaultMapGridworld(rng), 2, []SlipSettings{{Stay: 0.5}, {VeerLeft: 0.3}})
+		env = NewMovingGoal(env, 3)
+		env = NewGaussianObservationNoise(env, 0.1, rng)
+		env = NewNormalizeObservation(env, 5)
+		return NewTimeLimit(NewRewardDrift(env, 2, 0.5), 20)
+	}
+	// play runs episodes of random actions and returns every observation and reward
+	play := func(env internal.Environment, episodes int, rng *mathlib.Random) [][]float64 {
+		var trace [][]float64
+		for ep := 0; ep < episodes; ep++ {
+			env.NewEpisode(rng)
+			for !env.InTAS() {
+				r := env.Transition(int(rng.Float64()*4), rng)
+				if env.InTAS() {
+					trace = append(trace, []float64{r})
+				} else {
+					trace = append(trace, append(env.GetState(), r))
+				}
+			}
+		}
+		return trace
+	}
+
+	// A fresh environment restored from a snapshot carries on exactly like the original
+	rng := mathlib.NewRandom(1)
+	env := newEnv(rng)
+	play(env, 7, rng)
+	snap, err := internal.SnapshotOf(env)
+	assert.NoError(t, err)
+	resumed := rng.Clone()
+	want := play(env, 5, rng)
+
+	restored := newEnv(mathlib.NewRandom(2))
+	assert.NoError(t, internal.RestoreSnapshot(restored, snap))
+	assert.Equal(t, want, play(restored, 5, resumed))
+
+	// A snapshot only fits an environment built with the same settings
+	assert.Error(t, internal.RestoreSnapshot(NewNormalizeObservation(internal.NewCliffWalking(rng), 5), snap))
+}
@@ -0,0 +1,72 @@
+package mathlib
+
+import (
+	"fmt"
+	"math"
+)
+
+// Cholesky returns the lower triangular matrix L with L L^T = a, for a symmetric positive definite
+// matrix a, or an error if a is not positive definite (to working precision). Only the lower
+// triangle of a is read.
+func Cholesky(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	l := Matrix(n, n, 0)
+	for j := 0; j < n; j++ {
+		if len(a[j]) != n {
+			panic("a is not square")
+		}
+		d := a[j][j]
+		for k := 0; k < j; k++ {
+			d -= l[j][k] * l[j][k]
+		}
+		if !(d > 0) {
+			return nil, fmt.Errorf("matrix is not positive definite (pivot %d is %g)", j, d)
+		}
+		l[j][j] = math.Sqrt(d)
+		for i := j + 1; i < n; i++ {
+			s := a[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k]
+			}
+			l[i][j] = s / l[j][j]
+		}
+	}
+	return l, nil
+}
+
+// ForwardSubstitute returns x with l x = b for a lower triangular matrix l.
+func ForwardSubstitute(l [][]float64, b []float64) []float64 {
+	if len(l) != len(b) {
+		panic("l and b have different sizes")
+	}
+	x := make([]float64, len(b))
+	for i := range b {
+		s := b[i]
+		for k := 0; k < i; k++ {
+			s -= l[i][k] * x[k]
+		}
+		x[i] = s / l[i][i]
+	}
+	return x
+}
+
+// BackSubstitute returns x with l^T x = b for a lower triangular matrix l.
+func BackSubstitute(l [][]float64, b []float64) []float64 {
+	if len(l) != len(b) {
+		panic("l and b have different sizes")
+	}
+	x := make([]float64, len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		s := b[i]
+		for k := i + 1; k < len(b); k++ {
+			s -= l[k][i] * x[k]
+		}
+		x[i] = s / l[i][i]
+	}
+	return x
+}
+
+// CholeskySolve returns x with a x = b, given the Cholesky factor l of a.
+func CholeskySolve(l [][]float64, b []float64) []float64 {
+	return BackSubstitute(l, ForwardSubstitute(l, b))
+}
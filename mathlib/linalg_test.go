@@ -0,0 +1,49 @@
+package mathlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCholesky(t *testing.T) {
+	a := [][]float64{{4, 12, -16}, {12, 37, -43}, {-16, -43, 98}}
+	l, err := Cholesky(a)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{2, 0, 0}, {6, 1, 0}, {-8, 5, 3}}, l)
+
+	// L L^T = A
+	for i := range a {
+		for j := range a {
+			assert.InDelta(t, a[i][j], Dot(l[i], l[j]), 1e-12)
+		}
+	}
+
+	// Only the lower triangle is read
+	a[0][2] = 100
+	upper, err := Cholesky(a)
+	assert.NoError(t, err)
+	assert.Equal(t, l, upper)
+
+	// Indefinite and singular matrices have no factor
+	_, err = Cholesky([][]float64{{1, 2}, {2, 1}})
+	assert.Error(t, err)
+	_, err = Cholesky([][]float64{{1, 1}, {1, 1}})
+	assert.Error(t, err)
+	assert.Panics(t, func() { Cholesky([][]float64{{1, 0}, {0}}) })
+}
+
+func TestCholeskySolve(t *testing.T) {
+	a := [][]float64{{4, 12, -16}, {12, 37, -43}, {-16, -43, 98}}
+	l, err := Cholesky(a)
+	assert.NoError(t, err)
+
+	// A (1, 2, 3) = (-20, -43, 192)
+	x := CholeskySolve(l, []float64{-20, -43, 192})
+	assert.InDeltaSlice(t, []float64{1, 2, 3}, x, 1e-12)
+
+	// The substitutions solve the triangular systems on their own
+	assert.InDeltaSlice(t, []float64{1, 1, 1}, ForwardSubstitute(l, []float64{2, 7, 0}), 1e-12)
+	assert.InDeltaSlice(t, []float64{1, 1, 1}, BackSubstitute(l, []float64{0, 6, 3}), 1e-12)
+	assert.Panics(t, func() { CholeskySolve(l, []float64{1, 2}) })
+}
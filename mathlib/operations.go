@@ -38,7 +38,7 @@ func AddMatrix(a [][]float64, b [][]float64) [][]float64 {
 			panic("a and b have different number of columns")
 		}
 		for j := 0; j < len(a[i]); j++ {
-			a[i][j] *= b[i][j]
+			a[i][j] += b[i][j]
 		}
 	}
 	return a
@@ -53,3 +53,24 @@ func ScalarMultiplyMat(mat [][]float64, c float64) [][]float64 {
 	}
 	return mat
 }
+
+// Dot returns the inner product of vectors a and b.
+func Dot(a []float64, b []float64) float64 {
+	if len(a) != len(b) {
+		panic("a and b have different lengths")
+	}
+	total := 0.0
+	for i := 0; i < len(a); i++ {
+		total += a[i] * b[i]
+	}
+	return total
+}
+
+// MatVec returns the matrix-vector product mat * v as a new vector.
+func MatVec(mat [][]float64, v []float64) []float64 {
+	result := make([]float64, len(mat))
+	for i := 0; i < len(mat); i++ {
+		result[i] = Dot(mat[i], v)
+	}
+	return result
+}
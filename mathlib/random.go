@@ -1,29 +1,187 @@
 package mathlib
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
 	"math/rand"
 	"sync"
 )
 
-// Random manages a concurrent-safe random number generator.
+// Random manages a concurrent-safe random number generator. The generator is xoshiro256**
+// (Blackman and Vigna, 2018), which can be split into independent streams, so that concurrent
+// users can each draw from their own stream and get the same numbers however they are scheduled.
 type Random struct {
 	mu     *sync.RWMutex
-	source rand.Source
+	source *xoshiro
 	rng    *rand.Rand
 }
 
 // NewRandom returns a new threadsafe random number generator.
 func NewRandom(seed int64) *Random {
+	source := xoshiro{}
+	source.Seed(seed)
+	return newRandom(source)
+}
+
+// newRandom returns a generator that starts from the state of source.
+func newRandom(source xoshiro) *Random {
 	r := Random{}
 	r.mu = &sync.RWMutex{}
-	r.source = rand.NewSource(seed)
+	r.source = &source
 	r.rng = rand.New(r.source)
 	return &r
 }
 
+// Split returns a new generator whose stream is independent of r's. The new generator takes over
+// r's current position and r jumps 2^128 draws ahead, so the two streams never overlap in practice.
+// Splitting in a fixed order gives the same streams every time, e.g. one per trial of a run.
+func (r *Random) Split() *Random {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	child := *r.source
+	r.source.jump()
+	return newRandom(child)
+}
+
+// Clone returns a new generator that draws the same numbers as r from now on.
+func (r *Random) Clone() *Random {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return newRandom(*r.source)
+}
+
+// MarshalJSON saves the state of the generator, so that a run can be checkpointed and continue
+// with exactly the numbers it would have drawn.
+func (r *Random) MarshalJSON() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return json.Marshal(r.source.s)
+}
+
+// UnmarshalJSON restores a state saved by MarshalJSON. It may be called on the zero Random.
+func (r *Random) UnmarshalJSON(data []byte) error {
+	var s [4]uint64
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == [4]uint64{} {
+		return fmt.Errorf("the all-zero state is not a valid generator state")
+	}
+	if r.mu == nil {
+		*r = *newRandom(xoshiro{s: s})
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.source.s = s
+	return nil
+}
+
 // Float64 generates threadsafe uniform random float from [0,1)
 func (r *Random) Float64() float64 {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	return r.rng.Float64()
 }
+
+// NormFloat64 generates threadsafe standard normal random float
+func (r *Random) NormFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.NormFloat64()
+}
+
+// Gamma generates a Gamma(shape, 1) random float using Marsaglia and Tsang's method.
+func (r *Random) Gamma(shape float64) float64 {
+	if shape <= 0 {
+		panic("Gamma shape must be positive.")
+	}
+	if shape < 1 {
+		// Boost the shape above one and correct with a uniform power
+		return r.Gamma(shape+1) * math.Pow(r.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3.0
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// Beta generates a Beta(alpha, beta) random float.
+func (r *Random) Beta(alpha float64, beta float64) float64 {
+	x := r.Gamma(alpha)
+	y := r.Gamma(beta)
+	return x / (x + y)
+}
+
+// xoshiro is the state of a xoshiro256** generator. It implements rand.Source64.
+type xoshiro struct {
+	s [4]uint64
+}
+
+// splitMix64 advances the SplitMix64 state x and returns its next output. It expands seeds into
+// xoshiro states, as recommended by the authors of xoshiro.
+func splitMix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Seed sets the state from the seed.
+func (x *xoshiro) Seed(seed int64) {
+	sm := uint64(seed)
+	for i := range x.s {
+		x.s[i] = splitMix64(&sm)
+	}
+}
+
+// Uint64 returns the next 64 random bits.
+func (x *xoshiro) Uint64() uint64 {
+	s := &x.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+// Int63 returns a non-negative random int64.
+func (x *xoshiro) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
+
+// xoshiroJump is the jump polynomial that advances xoshiro256** by 2^128 draws.
+var xoshiroJump = [4]uint64{0x180ec6d33cfd0aba, 0xd5a61266f0c9392c, 0xa9582618e03fc9aa, 0x39abdc4529b1661c}
+
+// jump advances the state by 2^128 draws.
+func (x *xoshiro) jump() {
+	var s [4]uint64
+	for _, j := range xoshiroJump {
+		for b := uint(0); b < 64; b++ {
+			if j&(1<<b) != 0 {
+				for i := range s {
+					s[i] ^= x.s[i]
+				}
+			}
+			x.Uint64()
+		}
+	}
+	x.s = s
+}
@@ -0,0 +1,87 @@
+package mathlib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXoshiro(t *testing.T) {
+	// The first outputs of the reference implementation from the state (1, 2, 3, 4)
+	x := xoshiro{s: [4]uint64{1, 2, 3, 4}}
+	for _, want := range []uint64{11520, 0, 1509978240, 1215971899390074240} {
+		assert.Equal(t, want, x.Uint64())
+	}
+
+	// A jump is a power of the step, so jumping and stepping commute
+	a, b := xoshiro{}, xoshiro{}
+	a.Seed(3)
+	b.Seed(3)
+	a.jump()
+	a.Uint64()
+	b.Uint64()
+	b.jump()
+	assert.Equal(t, a, b)
+
+	// and it is linear in the state, like every step
+	c, d, cd := xoshiro{s: [4]uint64{1, 2, 3, 4}}, xoshiro{s: [4]uint64{5, 6, 7, 8}}, xoshiro{s: [4]uint64{1 ^ 5, 2 ^ 6, 3 ^ 7, 4 ^ 8}}
+	c.jump()
+	d.jump()
+	cd.jump()
+	for i := range cd.s {
+		assert.Equal(t, c.s[i]^d.s[i], cd.s[i])
+	}
+	assert.NotEqual(t, [4]uint64{1, 2, 3, 4}, c.s)
+}
+
+func TestSplit(t *testing.T) {
+	r := NewRandom(1)
+	start := *r.source
+	child := r.Split()
+
+	// The child takes over the parent's position, and the parent jumps ahead
+	assert.Equal(t, start, *child.source)
+	start.jump()
+	assert.Equal(t, start, *r.source)
+	assert.NotEqual(t, child.Float64(), r.Float64())
+
+	// Splitting in the same order gives the same streams
+	a, b := NewRandom(1), NewRandom(1)
+	a1, a2, b1, b2 := a.Split(), a.Split(), b.Split(), b.Split()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a1.Float64(), b1.Float64())
+		assert.Equal(t, a2.Float64(), b2.Float64())
+	}
+}
+
+func TestRandomJSON(t *testing.T) {
+	a := NewRandom(5)
+	a.Float64()
+	data, err := json.Marshal(a)
+	assert.NoError(t, err)
+	var s [4]uint64
+	assert.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, a.source.s, s)
+	x := a.NormFloat64()
+
+	// Restoring into a new or a used generator continues from the saved state
+	b := &Random{}
+	assert.NoError(t, json.Unmarshal(data, b))
+	assert.Equal(t, x, b.NormFloat64())
+	assert.NoError(t, json.Unmarshal(data, a))
+	assert.Equal(t, x, a.NormFloat64())
+	c := a.Clone()
+	assert.Equal(t, a.Float64(), c.Float64())
+
+	// A generator inside a struct is saved and restored with it
+	saved, err := json.Marshal(struct{ Stream *Random }{a})
+	assert.NoError(t, err)
+	restored := struct{ Stream *Random }{}
+	assert.NoError(t, json.Unmarshal(saved, &restored))
+	assert.Equal(t, a.Float64(), restored.Stream.Float64())
+
+	// The all-zero state would only draw zeros
+	assert.Error(t, json.Unmarshal([]byte("[0,0,0,0]"), b))
+	assert.Error(t, json.Unmarshal([]byte(`"state"`), b))
+}
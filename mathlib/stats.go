@@ -29,3 +29,13 @@ func StdError(v []float64) float64 {
 	}
 	return math.Sqrt(temp/float64(len(v)-1.0)) / math.Sqrt(float64(len(v))) // Return the standard error. The returned object must match the return type in the function delaration.
 }
+
+// NormalPDF returns the density of the standard normal distribution at x.
+func NormalPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// NormalCDF returns the probability that a standard normal random variable is at most x.
+func NormalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -38,6 +38,15 @@ func Matrix(rows int, cols int, initialValue float64) [][]float64 {
 	return mat
 }
 
+// Identity returns a size x size identity matrix scaled by c.
+func Identity(size int, c float64) [][]float64 {
+	mat := Matrix(size, size, 0)
+	for i := 0; i < size; i++ {
+		mat[i][i] = c
+	}
+	return mat
+}
+
 // Column returns the k'th column of the passed Matrix mat.
 func Column(mat [][]float64, k int) []float64 {
 	vec := make([]float64, len(mat))
@@ -80,3 +89,51 @@ func ToOneHot(idx int, capacity int) []float64 {
 	v[idx] = 1
 	return v
 }
+
+// FromMixedRadix returns the flat index of the multi-dimensional discrete value digits, where
+// digits[i] is in [0, radices[i]). The first digit is the most significant, as in row-major order.
+func FromMixedRadix(digits []int, radices []int) int {
+	if len(digits) != len(radices) {
+		panic("digits and radices have different lengths")
+	}
+	idx := 0
+	for i := 0; i < len(radices); i++ {
+		if digits[i] < 0 || digits[i] >= radices[i] {
+			panic("Digit out of range of its radix. Arguments incorrect.")
+		}
+		idx = idx*radices[i] + digits[i]
+	}
+	return idx
+}
+
+// ToMixedRadix is the inverse of FromMixedRadix. It returns the digits of the flat index idx.
+func ToMixedRadix(idx int, radices []int) []int {
+	if idx < 0 || idx >= MixedRadixCapacity(radices) {
+		panic("Cannot index past capacity. Arguments incorrect.")
+	}
+	digits := make([]int, len(radices))
+	for i := len(radices) - 1; i >= 0; i-- {
+		digits[i] = idx % radices[i]
+		idx /= radices[i]
+	}
+	return digits
+}
+
+// MixedRadixCapacity returns how many values the passed radices can represent.
+func MixedRadixCapacity(radices []int) int {
+	capacity := 1
+	for _, radix := range radices {
+		capacity *= radix
+	}
+	return capacity
+}
+
+// ToOneHotMixedRadix returns the one-hot encoding of the multi-dimensional discrete value digits.
+func ToOneHotMixedRadix(digits []int, radices []int) []float64 {
+	return ToOneHot(FromMixedRadix(digits, radices), MixedRadixCapacity(radices))
+}
+
+// FromOneHotMixedRadix returns the digits of the one-hot vector v and panics if v is all zeros.
+func FromOneHotMixedRadix(v []float64, radices []int) []int {
+	return ToMixedRadix(FromOneHot(v), radices)
+}